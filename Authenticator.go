@@ -1,6 +1,7 @@
 package coinbase
 
 import (
+	"context"
 	"net/http"
 )
 
@@ -11,3 +12,9 @@ type authenticator interface {
 	getClient() *http.Client
 	authenticate(req *http.Request, endpoint string, params []byte) error
 }
+
+// tokenRefresher is implemented by authenticators able to renew credentials
+// that coinbase rejected with 401 Unauthorized
+type tokenRefresher interface {
+	refreshAfterUnauthorized(ctx context.Context, req *http.Request) (bool, error)
+}
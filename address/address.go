@@ -0,0 +1,382 @@
+// Package address validates bitcoin addresses and builds and parses BIP21
+// payment URIs. Legacy base58check addresses (P2PKH and P2SH) and segwit
+// addresses (bech32 for version 0, bech32m for taproot and later versions) are
+// supported on mainnet and testnet. Checksums are verified, so that a typo in
+// an address is caught before money is sent to it.
+package address
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// ErrInvalidAddress is returned when a string is not a bitcoin address
+var ErrInvalidAddress = errors.New("Not a bitcoin address")
+
+// ErrChecksum is returned when an address is well formed but its checksum does
+// not match, which usually means it contains a typo
+var ErrChecksum = errors.New("The address checksum does not match")
+
+// ErrWrongNetwork is returned by Validate when an address belongs to another
+// network than expected
+var ErrWrongNetwork = errors.New("The address belongs to another network")
+
+// Network is the bitcoin network an address belongs to
+type Network int
+
+const (
+	Mainnet Network = iota
+	Testnet
+)
+
+func (n Network) String() string {
+	if n == Testnet {
+		return "testnet"
+	}
+	return "mainnet"
+}
+
+// Type is the kind of script an address pays to
+type Type int
+
+const (
+	P2PKH   Type = iota // Pay to public key hash, i.e 1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2
+	P2SH                // Pay to script hash, i.e 3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy
+	P2WPKH              // Pay to witness public key hash, version 0
+	P2WSH               // Pay to witness script hash, version 0
+	P2TR                // Pay to taproot, version 1
+	Witness             // Any later witness version
+)
+
+func (t Type) String() string {
+	switch t {
+	case P2PKH:
+		return "p2pkh"
+	case P2SH:
+		return "p2sh"
+	case P2WPKH:
+		return "p2wpkh"
+	case P2WSH:
+		return "p2wsh"
+	case P2TR:
+		return "p2tr"
+	}
+	return "witness"
+}
+
+// Address is a parsed bitcoin address
+type Address struct {
+	Network Network
+	Type    Type
+	Version int    // Witness version of segwit addresses
+	Hash    []byte // Public key hash, script hash or witness program
+}
+
+// Version bytes of base58check addresses
+var base58Versions = map[byte]struct {
+	network Network
+	kind    Type
+}{
+	0x00: {Mainnet, P2PKH},
+	0x05: {Mainnet, P2SH},
+	0x6f: {Testnet, P2PKH},
+	0xc4: {Testnet, P2SH},
+}
+
+// Human readable parts of segwit addresses
+var segwitHrps = map[string]Network{
+	"bc": Mainnet,
+	"tb": Testnet,
+}
+
+// Parse parses a bitcoin address. The error wraps ErrChecksum if the checksum
+// does not match, or ErrInvalidAddress for any other defect
+func Parse(s string) (*Address, error) {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndexByte(s, '1'); i > 0 {
+		if _, ok := segwitHrps[strings.ToLower(s[:i])]; ok {
+			return parseSegwit(s)
+		}
+	}
+	return parseBase58(s)
+}
+
+// Validate checks that s is a bitcoin address of network
+func Validate(s string, network Network) error {
+	a, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	if a.Network != network {
+		return fmt.Errorf("%w: %s is a %s address", ErrWrongNetwork, s, a.Network)
+	}
+	return nil
+}
+
+// IsValid reports whether s is a bitcoin address of any network
+func IsValid(s string) bool {
+	_, err := Parse(s)
+	return err == nil
+}
+
+// IsEmail reports whether s is a bare email address, i.e user@example.com
+func IsEmail(s string) bool {
+	a, err := mail.ParseAddress(s)
+	return err == nil && a.Address == s
+}
+
+// ParseRecipient classifies the recipient of a send, which coinbase accepts as
+// an email or a bitcoin address. It returns the parsed address, or nil if to is
+// an email
+func ParseRecipient(to string) (*Address, error) {
+	if strings.Contains(to, "@") {
+		if !IsEmail(to) {
+			return nil, fmt.Errorf("%q is neither an email nor a bitcoin address", to)
+		}
+		return nil, nil
+	}
+	return Parse(to)
+}
+
+// String encodes the address
+func (a *Address) String() string {
+	if a.Type == P2PKH || a.Type == P2SH {
+		for version, v := range base58Versions {
+			if v.network == a.Network && v.kind == a.Type {
+				return encodeBase58Check(append([]byte{version}, a.Hash...))
+			}
+		}
+	}
+	hrp := "bc"
+	if a.Network == Testnet {
+		hrp = "tb"
+	}
+	spec := uint32(bech32Const)
+	if a.Version > 0 {
+		spec = bech32mConst
+	}
+	data := append([]byte{byte(a.Version)}, convertBits(a.Hash, 8, 5, true)...)
+	return encodeBech32(hrp, data, spec)
+}
+
+func parseBase58(s string) (*Address, error) {
+	decoded, err := decodeBase58(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) != 25 {
+		return nil, fmt.Errorf("%w: %q has the wrong length", ErrInvalidAddress, s)
+	}
+	payload, checksum := decoded[:21], decoded[21:]
+	if !bytes.Equal(checksum, doubleSha256(payload)[:4]) {
+		return nil, fmt.Errorf("%w: %s", ErrChecksum, s)
+	}
+	v, ok := base58Versions[payload[0]]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q has an unknown version", ErrInvalidAddress, s)
+	}
+	return &Address{Network: v.network, Type: v.kind, Hash: payload[1:]}, nil
+}
+
+func parseSegwit(s string) (*Address, error) {
+	hrp, data, spec, err := decodeBech32(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 || data[0] > 16 {
+		return nil, fmt.Errorf("%w: %q has an invalid witness version", ErrInvalidAddress, s)
+	}
+	version := int(data[0])
+	program := convertBits(data[1:], 5, 8, false)
+	switch {
+	case program == nil || len(program) < 2 || len(program) > 40:
+		return nil, fmt.Errorf("%w: %q has an invalid witness program", ErrInvalidAddress, s)
+	case version == 0 && len(program) != 20 && len(program) != 32:
+		return nil, fmt.Errorf("%w: %q has an invalid witness program", ErrInvalidAddress, s)
+	case (version == 0) != (spec == bech32Const):
+		// Version 0 uses bech32 and later versions bech32m (BIP350)
+		return nil, fmt.Errorf("%w: %s", ErrChecksum, s)
+	}
+	a := &Address{Network: segwitHrps[hrp], Type: Witness, Version: version, Hash: program}
+	switch {
+	case version == 0 && len(program) == 20:
+		a.Type = P2WPKH
+	case version == 0:
+		a.Type = P2WSH
+	case version == 1 && len(program) == 32:
+		a.Type = P2TR
+	}
+	return a, nil
+}
+
+func doubleSha256(b []byte) []byte {
+	first := sha256.Sum256(b)
+	second := sha256.Sum256(first[:])
+	return second[:]
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+func decodeBase58(s string) ([]byte, error) {
+	if s == "" {
+		return nil, fmt.Errorf("%w: the address is empty", ErrInvalidAddress)
+	}
+	// Little endian digits in base 256
+	digits := []byte{}
+	for _, c := range []byte(s) {
+		carry := strings.IndexByte(base58Alphabet, c)
+		if carry < 0 {
+			return nil, fmt.Errorf("%w: %q contains %q", ErrInvalidAddress, s, c)
+		}
+		for i := range digits {
+			carry += int(digits[i]) * 58
+			digits[i] = byte(carry)
+			carry >>= 8
+		}
+		for ; carry > 0; carry >>= 8 {
+			digits = append(digits, byte(carry))
+		}
+	}
+	// Each leading "1" encodes a leading zero byte
+	decoded := []byte{}
+	for i := 0; i < len(s) && s[i] == '1'; i++ {
+		decoded = append(decoded, 0)
+	}
+	for i := len(digits) - 1; i >= 0; i-- {
+		decoded = append(decoded, digits[i])
+	}
+	return decoded, nil
+}
+
+func encodeBase58Check(payload []byte) string {
+	b := append(append([]byte{}, payload...), doubleSha256(payload)[:4]...)
+	// Little endian digits in base 58
+	digits := []byte{}
+	for _, c := range b {
+		carry := int(c)
+		for i := range digits {
+			carry += int(digits[i]) << 8
+			digits[i] = byte(carry % 58)
+			carry /= 58
+		}
+		for ; carry > 0; carry /= 58 {
+			digits = append(digits, byte(carry%58))
+		}
+	}
+	encoded := []byte{}
+	for i := 0; i < len(b) && b[i] == 0; i++ {
+		encoded = append(encoded, '1')
+	}
+	for i := len(digits) - 1; i >= 0; i-- {
+		encoded = append(encoded, base58Alphabet[digits[i]])
+	}
+	return string(encoded)
+}
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// Checksum constants of bech32 (BIP173) and bech32m (BIP350)
+const (
+	bech32Const  = 1
+	bech32mConst = 0x2bc830a3
+)
+
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HrpExpand(hrp string) []byte {
+	expanded := []byte{}
+	for _, c := range []byte(hrp) {
+		expanded = append(expanded, c>>5)
+	}
+	expanded = append(expanded, 0)
+	for _, c := range []byte(hrp) {
+		expanded = append(expanded, c&31)
+	}
+	return expanded
+}
+
+// decodeBech32 returns the human readable part, the data without checksum and
+// the checksum constant of a bech32 or bech32m string
+func decodeBech32(s string) (string, []byte, uint32, error) {
+	if len(s) > 90 {
+		return "", nil, 0, fmt.Errorf("%w: %q is too long", ErrInvalidAddress, s)
+	}
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, 0, fmt.Errorf("%w: %q mixes upper and lower case", ErrInvalidAddress, s)
+	}
+	lower := strings.ToLower(s)
+	i := strings.LastIndexByte(lower, '1')
+	if i < 1 || i+7 > len(lower) {
+		return "", nil, 0, fmt.Errorf("%w: %q is too short", ErrInvalidAddress, s)
+	}
+	hrp := lower[:i]
+	data := []byte{}
+	for _, c := range []byte(lower[i+1:]) {
+		d := strings.IndexByte(bech32Charset, c)
+		if d < 0 {
+			return "", nil, 0, fmt.Errorf("%w: %q contains %q", ErrInvalidAddress, s, c)
+		}
+		data = append(data, byte(d))
+	}
+	spec := bech32Polymod(append(bech32HrpExpand(hrp), data...))
+	if spec != bech32Const && spec != bech32mConst {
+		return "", nil, 0, fmt.Errorf("%w: %s", ErrChecksum, s)
+	}
+	return hrp, data[:len(data)-6], spec, nil
+}
+
+func encodeBech32(hrp string, data []byte, spec uint32) string {
+	values := append(bech32HrpExpand(hrp), data...)
+	polymod := bech32Polymod(append(values, 0, 0, 0, 0, 0, 0)) ^ spec
+	encoded := []byte(hrp + "1")
+	for _, d := range data {
+		encoded = append(encoded, bech32Charset[d])
+	}
+	for i := 0; i < 6; i++ {
+		encoded = append(encoded, bech32Charset[(polymod>>(5*(5-i)))&31])
+	}
+	return string(encoded)
+}
+
+// convertBits regroups bits from groups of from bits into groups of to bits,
+// returning nil if the padding is invalid
+func convertBits(data []byte, from uint, to uint, pad bool) []byte {
+	acc, bits := 0, uint(0)
+	maxv := 1<<to - 1
+	maxAcc := 1<<(from+to-1) - 1
+	converted := []byte{}
+	for _, value := range data {
+		if int(value)>>from != 0 {
+			return nil
+		}
+		acc = (acc<<from | int(value)) & maxAcc
+		bits += from
+		for bits >= to {
+			bits -= to
+			converted = append(converted, byte(acc>>bits&maxv))
+		}
+	}
+	if pad && bits > 0 {
+		converted = append(converted, byte(acc<<(to-bits)&maxv))
+	} else if !pad && (bits >= from || acc<<(to-bits)&maxv != 0) {
+		return nil
+	}
+	return converted
+}
@@ -0,0 +1,130 @@
+package address
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseAddress(t *testing.T) {
+	valid := []struct {
+		address string
+		network Network
+		kind    Type
+	}{
+		{"1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2", Mainnet, P2PKH},
+		{"3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", Mainnet, P2SH},
+		{"37muSN5ZrukVTvyVh3mT5Zc5ew9L9CBare", Mainnet, P2SH},
+		{"muVu2JZo8PbewBHRp6bpqFvVD87qvqEHWA", Testnet, P2PKH},
+		{"2MzQwSSnBHWHqSAqtTVQ6v47XtaisrJa1Vc", Testnet, P2SH},
+		{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", Mainnet, P2WPKH},
+		{"tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7", Testnet, P2WSH},
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", Mainnet, P2TR},
+		{"bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7kt5nd6y", Mainnet, Witness},
+	}
+	for _, v := range valid {
+		a, err := Parse(v.address)
+		if err != nil {
+			t.Errorf("Parse Expected %s to be valid but got '%v'", v.address, err)
+			continue
+		}
+		if a.Network != v.network || a.Type != v.kind {
+			t.Errorf("Parse Expected %s to be a %s %s address but got %s %s", v.address, v.network, v.kind, a.Network, a.Type)
+		}
+		if a.String() != v.address {
+			t.Errorf("Parse Expected %s to encode back the same but got %s", v.address, a.String())
+		}
+	}
+
+	// Segwit addresses may be written in upper case, i.e in QR codes
+	if a, err := Parse("BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4"); err != nil || a.Type != P2WPKH {
+		t.Errorf("Parse Expected an upper case P2WPKH address to be valid but got '%v'", err)
+	}
+
+	typos := []string{
+		"1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN3",
+		"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t5",
+		"tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh", // Version 0 with a bech32m checksum
+	}
+	for _, typo := range typos {
+		if _, err := Parse(typo); !errors.Is(err, ErrChecksum) {
+			t.Errorf("Parse Expected a checksum error for %s but got '%v'", typo, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN0",
+		"1BvBMSEYstWetqTFn5Au4m4GFg7xJaNV",
+		"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7KV8F3T4",
+		"user1@example.com",
+	}
+	for _, s := range invalid {
+		if _, err := Parse(s); !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("Parse Expected %q to be invalid but got '%v'", s, err)
+		}
+	}
+
+	if err := Validate("muVu2JZo8PbewBHRp6bpqFvVD87qvqEHWA", Mainnet); !errors.Is(err, ErrWrongNetwork) {
+		t.Errorf("Validate Expected a wrong network error but got '%v'", err)
+	}
+}
+
+func TestParseRecipient(t *testing.T) {
+	if a, err := ParseRecipient("user1@example.com"); a != nil || err != nil {
+		t.Errorf("ParseRecipient Expected an email but got %v, '%v'", a, err)
+	}
+	if a, err := ParseRecipient("1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2"); a == nil || err != nil {
+		t.Errorf("ParseRecipient Expected an address but got '%v'", err)
+	}
+	for _, to := range []string{"user1@", "Bob <user1@example.com>", "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN3"} {
+		if _, err := ParseRecipient(to); err == nil {
+			t.Errorf("ParseRecipient Expected %q to be rejected", to)
+		}
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := &URI{
+		Address: "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2",
+		Amount:  "0.5",
+		Label:   "Luke-Jr",
+		Message: "Donation for project xyz & co",
+	}
+	encoded := uri.String()
+	expected := "bitcoin:1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2?amount=0.5&label=Luke-Jr&message=Donation%20for%20project%20xyz%20%26%20co"
+	if encoded != expected {
+		t.Errorf("URI Expected '%s' but got '%s'", expected, encoded)
+	}
+	parsed, err := ParseURI(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Address != uri.Address || parsed.Amount != uri.Amount || parsed.Label != uri.Label || parsed.Message != uri.Message {
+		t.Errorf("URI Expected %+v but got %+v", uri, parsed)
+	}
+
+	parsed, err = ParseURI("BITCOIN:bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4?somethingyoudontunderstand=50")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Amount != "" || parsed.Params["somethingyoudontunderstand"] != "50" {
+		t.Errorf("URI Expected the unknown parameter to be kept but got %+v", parsed)
+	}
+	if parsed, err := ParseURI("bitcoin:1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2"); err != nil || parsed.String() != "bitcoin:1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2" {
+		t.Errorf("URI Expected a bare address to be valid but got '%v'", err)
+	}
+
+	invalid := map[string]string{
+		"bitcoin:1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN3":                         "checksum",
+		"bitcoin:1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2?amount=1,5":              "amount",
+		"bitcoin:1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2?amount=0.000000001":      "amount",
+		"bitcoin:1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2?req-somethingyoudont=50": "required",
+		"litecoin:1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2":                        "bitcoin:",
+	}
+	for s, reason := range invalid {
+		if _, err := ParseURI(s); err == nil || !strings.Contains(err.Error(), reason) {
+			t.Errorf("URI Expected %q to be rejected for its %s but got '%v'", s, reason, err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package address
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// URI is a BIP21 payment URI, i.e
+// bitcoin:1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2?amount=0.5&label=Shop&message=Order%2042
+type URI struct {
+	Address string
+	Amount  string            // Amount requested in BTC as a decimal string, i.e "0.5", optional
+	Label   string            // Name of the recipient, optional
+	Message string            // Description of the payment, optional
+	Params  map[string]string // Any other parameters
+}
+
+// ParseURI parses a BIP21 payment URI. The address and amount are validated,
+// and URIs carrying a required parameter (req-*) this package does not know
+// are rejected as BIP21 mandates
+func ParseURI(s string) (*URI, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 8 || !strings.EqualFold(s[:8], "bitcoin:") {
+		return nil, fmt.Errorf("%q is not a bitcoin: URI", s)
+	}
+	rest := s[8:]
+	query := ""
+	if i := strings.IndexByte(rest, '?'); i >= 0 {
+		rest, query = rest[:i], rest[i+1:]
+	}
+	if _, err := Parse(rest); err != nil {
+		return nil, err
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid parameters in %q: %v", s, err)
+	}
+	uri := &URI{Address: rest}
+	for key, value := range values {
+		if len(value) > 1 {
+			return nil, fmt.Errorf("The %s parameter is repeated in %q", key, s)
+		}
+		switch key {
+		case "amount":
+			if !isBtcAmount(value[0]) {
+				return nil, fmt.Errorf("Invalid amount %q in %q", value[0], s)
+			}
+			uri.Amount = value[0]
+		case "label":
+			uri.Label = value[0]
+		case "message":
+			uri.Message = value[0]
+		default:
+			if strings.HasPrefix(key, "req-") {
+				return nil, fmt.Errorf("Unsupported required parameter %s in %q", key, s)
+			}
+			if uri.Params == nil {
+				uri.Params = map[string]string{}
+			}
+			uri.Params[key] = value[0]
+		}
+	}
+	return uri, nil
+}
+
+// String encodes the URI. Parameters are percent-encoded, spaces as %20
+// since wallets do not all decode "+"
+func (u *URI) String() string {
+	params := []string{}
+	add := func(key string, value string) {
+		if value != "" {
+			params = append(params, key+"="+strings.Replace(url.QueryEscape(value), "+", "%20", -1))
+		}
+	}
+	add("amount", u.Amount)
+	add("label", u.Label)
+	add("message", u.Message)
+	keys := []string{}
+	for key := range u.Params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		add(url.QueryEscape(key), u.Params[key])
+	}
+	s := "bitcoin:" + u.Address
+	if len(params) > 0 {
+		s += "?" + strings.Join(params, "&")
+	}
+	return s
+}
+
+// isBtcAmount reports whether s is a decimal amount of BTC with at
+// most 8 decimals
+func isBtcAmount(s string) bool {
+	units, decimals := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		units, decimals = s[:i], s[i+1:]
+	}
+	if units == "" && decimals == "" || len(decimals) > 8 {
+		return false
+	}
+	for _, c := range units + decimals {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -7,8 +7,6 @@ import (
 	"net/http"
 	"strconv"
 	"time"
-
-	"github.com/fabioberger/coinbase-go/config"
 )
 
 // ApiKeyAuthentication Struct implements the Authentication interface and takes
@@ -21,16 +19,12 @@ type apiKeyAuthentication struct {
 }
 
 // ApiKeyAuth instantiates ApiKeyAuthentication with the API key & secret
-func apiKeyAuth(key string, secret string) *apiKeyAuthentication {
+func apiKeyAuth(key string, secret string, cfg clientConfig) *apiKeyAuthentication {
 	a := apiKeyAuthentication{
 		Key:     key,
 		Secret:  secret,
-		BaseUrl: config.BaseUrl,
-		Client: http.Client{
-			Transport: &http.Transport{
-				Dial: dialTimeout,
-			},
-		},
+		BaseUrl: cfg.baseUrlOr(cfg.env.BaseUrl),
+		Client:  newHTTPClient(cfg, nil),
 	}
 	return &a
 }
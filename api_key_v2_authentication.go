@@ -0,0 +1,57 @@
+package coinbase
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ApiKeyV2Authentication Struct implements the Authentication interface and
+// takes care of authenticating API v2 requests with a Key & Secret pair
+type apiKeyV2Authentication struct {
+	Key     string
+	Secret  string
+	BaseUrl string
+	Client  http.Client
+}
+
+// ApiKeyV2Auth instantiates ApiKeyV2Authentication with the API key & secret
+func apiKeyV2Auth(key string, secret string, cfg clientConfig) *apiKeyV2Authentication {
+	a := apiKeyV2Authentication{
+		Key:     key,
+		Secret:  secret,
+		BaseUrl: cfg.baseUrlOr(cfg.env.v2BaseUrl()),
+		Client:  newHTTPClient(cfg, nil),
+	}
+	return &a
+}
+
+// API v2 Key + Secret authentication requires the CB-ACCESS-SIGN header, the
+// HMAC SHA-256 signature of the timestamp, method, path and body of the
+// request, along with the timestamp and the API key. Coinbase rejects
+// timestamps more than 30 seconds away from its clock
+func (a apiKeyV2Authentication) authenticate(req *http.Request, endpoint string, params []byte) error {
+
+	timestamp := strconv.FormatInt(time.Now().UTC().Unix(), 10)
+	message := timestamp + req.Method + req.URL.RequestURI() + string(params) //As per Coinbase Documentation
+
+	h := hmac.New(sha256.New, []byte(a.Secret))
+	h.Write([]byte(message))
+
+	req.Header.Set("CB-ACCESS-KEY", a.Key)
+	req.Header.Set("CB-ACCESS-SIGN", hex.EncodeToString(h.Sum(nil)))
+	req.Header.Set("CB-ACCESS-TIMESTAMP", timestamp)
+
+	return nil
+}
+
+func (a apiKeyV2Authentication) getBaseUrl() string {
+	return a.BaseUrl
+}
+
+func (a apiKeyV2Authentication) getClient() *http.Client {
+	return &a.Client
+}
@@ -0,0 +1,573 @@
+package coinbase
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrProposalNotFound is returned by ProposalStore.Load when no proposal has
+// the given ID
+var ErrProposalNotFound = errors.New("No proposal has this ID")
+
+// ErrProposalClosed is returned when approving, rejecting or executing a
+// proposal which was already executed, rejected or expired
+var ErrProposalClosed = errors.New("The proposal is closed")
+
+// ErrNotAnApprover is returned when someone not listed in
+// ApprovalPolicy.Approvers approves or rejects a proposal
+var ErrNotAnApprover = errors.New("Not an approver of proposals")
+
+// Statuses of a Proposal
+const (
+	ProposalPending  = "pending"
+	ProposalExecuted = "executed"
+	ProposalRejected = "rejected"
+	ProposalExpired  = "expired"
+)
+
+// Maximum size of the body of a request to ApprovalHandler
+const maxApprovalRequestSize = 1 << 16
+
+// ApprovalClient wraps a Client so that sends above a threshold are only made
+// once a quorum of approvers signed off on them, i.e
+//
+//	a, err := coinbase.NewApprovalClient(c, coinbase.ApprovalPolicy{
+//		Threshold: coinbase.MustParseMoney("10000", "USD"),
+//		Quorum:    2,
+//		Approvers: []string{"alice", "bob", "carol"},
+//		TTL:       48 * time.Hour,
+//	}, store)
+//	confirmation, err := a.SendMoney(params)
+//	var pending *coinbase.PendingApproval
+//	if errors.As(err, &pending) {
+//		// Nothing was sent yet, pending.Proposal awaits approval
+//	}
+//	...
+//	proposal, err := a.Approve(id, "alice")
+//
+// The idempotency key of a send is set when it is proposed and persisted with
+// it, so that the money is sent once even if the proposal is executed again,
+// i.e after a failure or by another process sharing the store
+type ApprovalClient struct {
+	client Client
+	policy ApprovalPolicy
+	store  ProposalStore
+	mu     sync.Mutex
+	now    func() time.Time
+}
+
+// ApprovalPolicy configures an ApprovalClient
+type ApprovalPolicy struct {
+	Threshold Money         // Sends of larger amounts require approval, all sends if zero
+	Quorum    int           // Number of distinct approvers required
+	Approvers []string      // Who may approve or reject proposals, at least Quorum of them
+	TTL       time.Duration // How long proposals may await approval, forever if zero
+}
+
+// Proposal is a send awaiting approval, or the record of its outcome
+type Proposal struct {
+	Id            string            `json:"id"`
+	Params        TransactionParams `json:"params"` // Params.Idem is set when proposed
+	Status        string            `json:"status"` // One of the Proposal* statuses
+	Approvals     []Approval        `json:"approvals"`
+	CreatedAt     time.Time         `json:"created_at"`
+	ExpiresAt     time.Time         `json:"expires_at"` // Zero if the proposal never expires
+	RejectedBy    string            `json:"rejected_by,omitempty"`
+	Reason        string            `json:"reason,omitempty"`         // Reason of the rejection
+	TransactionId string            `json:"transaction_id,omitempty"` // Set once executed
+	Error         string            `json:"error,omitempty"`          // Error of the last failed execution
+}
+
+// Approval is the sign off of an approver on a proposal
+type Approval struct {
+	Approver string    `json:"approver"`
+	At       time.Time `json:"at"`
+}
+
+// approved reports whether approver signed off on the proposal
+func (p *Proposal) approved(approver string) bool {
+	for _, a := range p.Approvals {
+		if a.Approver == approver {
+			return true
+		}
+	}
+	return false
+}
+
+// PendingApproval is the error returned by ApprovalClient.SendMoney when the
+// send requires approval. Nothing is sent until the proposal is approved
+type PendingApproval struct {
+	Proposal Proposal
+}
+
+func (e *PendingApproval) Error() string {
+	return fmt.Sprintf("The send of %s to %s awaits approval (proposal %s)", e.Proposal.Params.Amount, e.Proposal.Params.To, e.Proposal.Id)
+}
+
+// IsPendingApproval reports whether err is a PendingApproval
+func IsPendingApproval(err error) bool {
+	var pending *PendingApproval
+	return errors.As(err, &pending)
+}
+
+// ProposalStore persists proposals, keyed by ID. Stores must be safe for
+// concurrent use
+type ProposalStore interface {
+	Load(id string) (*Proposal, error) // Returns ErrProposalNotFound if absent
+	Save(proposal *Proposal) error
+	List() ([]Proposal, error)
+}
+
+// NewApprovalClient wraps c with policy, keeping proposals in store
+func NewApprovalClient(c Client, policy ApprovalPolicy, store ProposalStore) (*ApprovalClient, error) {
+	if policy.Quorum < 1 {
+		return nil, errors.New("The quorum must be at least 1")
+	}
+	if policy.Quorum > len(policy.Approvers) {
+		return nil, fmt.Errorf("A quorum of %d cannot be reached with %d approvers", policy.Quorum, len(policy.Approvers))
+	}
+	if store == nil {
+		return nil, errors.New("A proposal store is required")
+	}
+	return &ApprovalClient{client: c, policy: policy, store: store, now: time.Now}, nil
+}
+
+// Client returns the wrapped client, i.e to make calls not requiring approval
+func (a *ApprovalClient) Client() Client {
+	return a.client
+}
+
+// SendMoney sends money right away if the amount does not exceed the
+// threshold. Otherwise it records a proposal and returns a *PendingApproval
+func (a *ApprovalClient) SendMoney(params *TransactionParams) (*TransactionConfirmation, error) {
+	finalParams := TransactionParams{}
+	if params != nil {
+		finalParams = *params
+	}
+	if err := validateRecipient(finalParams.To, a.client.env); err != nil {
+		return nil, err // Before approvers spend time on it
+	}
+	if a.policy.Threshold.Currency != "" {
+		amount, err := convertAt(a.client, finalParams.Amount.Abs(), a.policy.Threshold.Currency)
+		if err != nil {
+			return nil, err
+		}
+		if cmp, _ := amount.Cmp(a.policy.Threshold); cmp <= 0 {
+			return a.client.SendMoney(&finalParams)
+		}
+	}
+	if finalParams.Idem == "" {
+		finalParams.Idem = NewIdempotencyKey()
+	}
+	now := a.now()
+	proposal := &Proposal{
+		Id:        NewIdempotencyKey(),
+		Params:    finalParams,
+		Status:    ProposalPending,
+		Approvals: []Approval{},
+		CreatedAt: now,
+	}
+	if a.policy.TTL > 0 {
+		proposal.ExpiresAt = now.Add(a.policy.TTL)
+	}
+	if err := a.store.Save(proposal); err != nil {
+		return nil, err
+	}
+	return nil, &PendingApproval{Proposal: *proposal}
+}
+
+// GetProposal returns the proposal with the given ID
+func (a *ApprovalClient) GetProposal(id string) (*Proposal, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	proposal, err := a.store.Load(id)
+	if err != nil {
+		return nil, err
+	}
+	return proposal, a.expire(proposal)
+}
+
+// PendingProposals returns the proposals awaiting approval, oldest first
+func (a *ApprovalClient) PendingProposals() ([]Proposal, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	proposals, err := a.store.List()
+	if err != nil {
+		return nil, err
+	}
+	pending := []Proposal{}
+	for i := range proposals {
+		if err := a.expire(&proposals[i]); err != nil {
+			return nil, err
+		}
+		if proposals[i].Status == ProposalPending {
+			pending = append(pending, proposals[i])
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].CreatedAt.Before(pending[j].CreatedAt)
+	})
+	return pending, nil
+}
+
+// Approve records the approval of the proposal by approver, and sends the
+// money once the quorum is reached. Approving twice counts once. If the send
+// fails, the error is returned and recorded in the proposal, which stays
+// pending: call Approve or Execute again to retry before the proposal expires
+func (a *ApprovalClient) Approve(id string, approver string) (*Proposal, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.isApprover(approver) {
+		return nil, ErrNotAnApprover
+	}
+	proposal, err := a.open(id)
+	if err != nil {
+		return nil, err
+	}
+	if !proposal.approved(approver) {
+		proposal.Approvals = append(proposal.Approvals, Approval{Approver: approver, At: a.now()})
+		if err := a.store.Save(proposal); err != nil {
+			return nil, err
+		}
+	}
+	if a.approvals(proposal) < a.policy.Quorum {
+		return proposal, nil
+	}
+	return proposal, a.execute(proposal)
+}
+
+// Reject closes the proposal without sending the money
+func (a *ApprovalClient) Reject(id string, approver string, reason string) (*Proposal, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.isApprover(approver) {
+		return nil, ErrNotAnApprover
+	}
+	proposal, err := a.open(id)
+	if err != nil {
+		return nil, err
+	}
+	proposal.Status = ProposalRejected
+	proposal.RejectedBy = approver
+	proposal.Reason = reason
+	return proposal, a.store.Save(proposal)
+}
+
+// Execute sends the money of a proposal which reached the quorum, i.e to
+// retry after a failure
+func (a *ApprovalClient) Execute(id string) (*Proposal, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	proposal, err := a.open(id)
+	if err != nil {
+		return nil, err
+	}
+	if approvals := a.approvals(proposal); approvals < a.policy.Quorum {
+		return proposal, fmt.Errorf("The proposal has %d of the %d approvals required", approvals, a.policy.Quorum)
+	}
+	return proposal, a.execute(proposal)
+}
+
+// open loads the proposal with the given ID and checks that it is pending
+func (a *ApprovalClient) open(id string) (*Proposal, error) {
+	proposal, err := a.store.Load(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.expire(proposal); err != nil {
+		return nil, err
+	}
+	if proposal.Status != ProposalPending {
+		return proposal, fmt.Errorf("%w: it was %s", ErrProposalClosed, proposal.Status)
+	}
+	return proposal, nil
+}
+
+// expire marks the proposal as expired if its TTL elapsed before it was
+// executed, even if it reached the quorum and its send failed
+func (a *ApprovalClient) expire(proposal *Proposal) error {
+	if proposal.Status != ProposalPending || proposal.ExpiresAt.IsZero() || a.now().Before(proposal.ExpiresAt) {
+		return nil
+	}
+	proposal.Status = ProposalExpired
+	return a.store.Save(proposal)
+}
+
+// execute sends the money of the proposal and records the outcome
+func (a *ApprovalClient) execute(proposal *Proposal) error {
+	params := proposal.Params
+	confirmation, err := a.client.SendMoney(&params)
+	if err != nil {
+		proposal.Error = err.Error()
+		if saveErr := a.store.Save(proposal); saveErr != nil {
+			return saveErr
+		}
+		return err
+	}
+	proposal.Status = ProposalExecuted
+	proposal.TransactionId = confirmation.Transaction.Id
+	proposal.Error = ""
+	return a.store.Save(proposal)
+}
+
+// approvals counts the approvals of the proposal by current approvers, so that
+// the approvals of someone removed from the policy no longer count
+func (a *ApprovalClient) approvals(proposal *Proposal) int {
+	count := 0
+	for _, approval := range proposal.Approvals {
+		if a.isApprover(approval.Approver) {
+			count++
+		}
+	}
+	return count
+}
+
+func (a *ApprovalClient) isApprover(approver string) bool {
+	if approver == "" {
+		return false
+	}
+	for _, allowed := range a.policy.Approvers {
+		if allowed == approver {
+			return true
+		}
+	}
+	return false
+}
+
+// ApprovalHandler is an http.Handler letting approvers list, approve and
+// reject proposals. Mount it with http.StripPrefix, i.e
+//
+//	h := coinbase.NewApprovalHandler(a, func(req *http.Request) (string, error) {
+//		return approverOf(req) // Authenticate the approver, i.e from a session
+//	})
+//	http.Handle("/approvals/", http.StripPrefix("/approvals", h))
+//
+// It serves
+//
+//	GET  /             the pending proposals
+//	GET  /{id}         a proposal
+//	POST /{id}/approve approves a proposal
+//	POST /{id}/reject  rejects a proposal, with an optional {"reason": "..."} body
+//
+// and replies with the proposals in JSON. Every request must be authenticated
+// by Authenticate, which returns the name of the approver
+type ApprovalHandler struct {
+	Approvals    *ApprovalClient
+	Authenticate func(*http.Request) (string, error)
+}
+
+// NewApprovalHandler instantiates an ApprovalHandler serving the proposals of
+// a, authenticating approvers with authenticate
+func NewApprovalHandler(a *ApprovalClient, authenticate func(*http.Request) (string, error)) *ApprovalHandler {
+	return &ApprovalHandler{Approvals: a, Authenticate: authenticate}
+}
+
+// ServeHTTP authenticates the approver and serves the request
+func (h *ApprovalHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if h.Authenticate == nil {
+		http.Error(w, "Approvers cannot be authenticated", http.StatusInternalServerError)
+		return
+	}
+	approver, err := h.Authenticate(req)
+	if err != nil || approver == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	var result interface{}
+	switch {
+	case req.Method == "GET" && parts[0] == "":
+		result, err = h.Approvals.PendingProposals()
+	case req.Method == "GET" && len(parts) == 1:
+		result, err = h.Approvals.GetProposal(parts[0])
+	case req.Method == "POST" && len(parts) == 2 && parts[1] == "approve":
+		result, err = h.Approvals.Approve(parts[0], approver)
+	case req.Method == "POST" && len(parts) == 2 && parts[1] == "reject":
+		body := struct {
+			Reason string `json:"reason"`
+		}{}
+		data, readErr := io.ReadAll(http.MaxBytesReader(w, req.Body, maxApprovalRequestSize))
+		if readErr == nil && len(data) > 0 {
+			readErr = json.Unmarshal(data, &body)
+		}
+		if readErr != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		result, err = h.Approvals.Reject(parts[0], approver, body.Reason)
+	case len(parts) <= 2:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	default:
+		http.NotFound(w, req)
+		return
+	}
+
+	switch {
+	case errors.Is(err, ErrProposalNotFound):
+		http.Error(w, "Proposal not found", http.StatusNotFound)
+	case errors.Is(err, ErrNotAnApprover):
+		http.Error(w, "Not an approver", http.StatusForbidden)
+	case errors.Is(err, ErrProposalClosed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		// The send failed and can be retried by approving again
+		http.Error(w, "The proposal could not be executed", http.StatusBadGateway)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// MemoryProposalStore is a ProposalStore keeping proposals in memory
+type MemoryProposalStore struct {
+	mu        sync.RWMutex
+	proposals map[string]Proposal
+}
+
+// NewMemoryProposalStore instantiates an empty MemoryProposalStore
+func NewMemoryProposalStore() *MemoryProposalStore {
+	return &MemoryProposalStore{proposals: map[string]Proposal{}}
+}
+
+// Load returns a copy of the proposal with the given ID
+func (s *MemoryProposalStore) Load(id string) (*Proposal, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	proposal, ok := s.proposals[id]
+	if !ok {
+		return nil, ErrProposalNotFound
+	}
+	proposal.Approvals = append([]Approval{}, proposal.Approvals...)
+	return &proposal, nil
+}
+
+// Save stores a copy of proposal
+func (s *MemoryProposalStore) Save(proposal *Proposal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := *proposal
+	stored.Approvals = append([]Approval{}, proposal.Approvals...)
+	s.proposals[proposal.Id] = stored
+	return nil
+}
+
+// List returns copies of all the proposals
+func (s *MemoryProposalStore) List() ([]Proposal, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	proposals := []Proposal{}
+	for _, proposal := range s.proposals {
+		proposal.Approvals = append([]Approval{}, proposal.Approvals...)
+		proposals = append(proposals, proposal)
+	}
+	return proposals, nil
+}
+
+// FileProposalStore is a ProposalStore keeping each proposal in a JSON file of
+// its own
+type FileProposalStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileProposalStore instantiates a FileProposalStore saving files in dir,
+// which is created if needed
+func NewFileProposalStore(dir string) (*FileProposalStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileProposalStore{dir: dir}, nil
+}
+
+// path returns the file of the proposal with the given ID, or "" if the ID
+// could not have been generated by ApprovalClient
+func (s *FileProposalStore) path(id string) string {
+	if id == "" || strings.Trim(id, "0123456789abcdef-") != "" {
+		return ""
+	}
+	return filepath.Join(s.dir, id+".proposal")
+}
+
+// Load reads the proposal with the given ID
+func (s *FileProposalStore) Load(id string) (*Proposal, error) {
+	path := s.path(id)
+	if path == "" {
+		return nil, ErrProposalNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read(path)
+}
+
+func (s *FileProposalStore) read(path string) (*Proposal, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrProposalNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	proposal := Proposal{}
+	if err := json.Unmarshal(data, &proposal); err != nil {
+		return nil, err
+	}
+	return &proposal, nil
+}
+
+// Save writes proposal atomically to its file
+func (s *FileProposalStore) Save(proposal *Proposal) error {
+	path := s.path(proposal.Id)
+	if path == "" {
+		return fmt.Errorf("Invalid proposal ID %q", proposal.Id)
+	}
+	data, err := json.Marshal(proposal)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tmp, err := os.CreateTemp(s.dir, ".proposal-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// List reads all the proposals
+func (s *FileProposalStore) List() ([]Proposal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.proposal"))
+	if err != nil {
+		return nil, err
+	}
+	proposals := []Proposal{}
+	for _, path := range paths {
+		proposal, err := s.read(path)
+		if err != nil {
+			return nil, err
+		}
+		proposals = append(proposals, *proposal)
+	}
+	return proposals, nil
+}
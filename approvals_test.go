@@ -0,0 +1,225 @@
+package coinbase
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestApprovalClient(t *testing.T, store ProposalStore) *ApprovalClient {
+	a, err := NewApprovalClient(initTestClient(), ApprovalPolicy{
+		Threshold: MustParseMoney("500", "USD"),
+		Quorum:    2,
+		Approvers: []string{"alice", "bob", "carol"},
+		TTL:       time.Hour,
+	}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestApprovalPolicyApprovers(t *testing.T) {
+	// Without approvers, anyone could approve a send on their own
+	for _, approvers := range [][]string{nil, {"alice"}} {
+		if _, err := NewApprovalClient(initTestClient(), ApprovalPolicy{Quorum: 2, Approvers: approvers}, NewMemoryProposalStore()); err == nil {
+			t.Errorf("ApprovalPolicyApprovers Expected an error with approvers %v", approvers)
+		}
+	}
+}
+
+func TestApprovalQuorum(t *testing.T) {
+	a := newTestApprovalClient(t, NewMemoryProposalStore())
+
+	// 1 BTC is worth 386.53 USD at the fixture exchange rate
+	if _, err := a.SendMoney(&TransactionParams{To: "user1@example.com", Amount: MustParseMoney("1", "BTC")}); err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "ApprovalQuorum", "transactions/send_money", testServer.LastRequest().Path)
+
+	_, err := a.SendMoney(&TransactionParams{To: "user1@example.com", Amount: MustParseMoney("2", "BTC")})
+	var pending *PendingApproval
+	if !errors.As(err, &pending) {
+		t.Fatalf("ApprovalQuorum Expected a pending approval but got '%v'", err)
+	}
+	id := pending.Proposal.Id
+	idem := pending.Proposal.Params.Idem
+	compareBool(t, "ApprovalQuorum", true, idem != "")
+
+	if _, err := a.Approve(id, "mallory"); err != ErrNotAnApprover {
+		t.Errorf("ApprovalQuorum Expected ErrNotAnApprover but got '%v'", err)
+	}
+	for i := 0; i < 2; i++ {
+		proposal, err := a.Approve(id, "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		compareString(t, "ApprovalQuorum", ProposalPending, proposal.Status)
+		compareInt(t, "ApprovalQuorum", 1, int64(len(proposal.Approvals)))
+	}
+
+	proposal, err := a.Approve(id, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "ApprovalQuorum", ProposalExecuted, proposal.Status)
+	compareString(t, "ApprovalQuorum", "501a1791f8182b2071000087", proposal.TransactionId)
+	body := struct {
+		Transaction struct {
+			Idem   string `json:"idem"`
+			Amount string `json:"amount"`
+		} `json:"transaction"`
+	}{}
+	if err := json.Unmarshal(testServer.LastRequest().Body, &body); err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "ApprovalQuorum", idem, body.Transaction.Idem)
+	compareString(t, "ApprovalQuorum", "2.00000000", body.Transaction.Amount)
+
+	if _, err := a.Approve(id, "carol"); !errors.Is(err, ErrProposalClosed) {
+		t.Errorf("ApprovalQuorum Expected ErrProposalClosed but got '%v'", err)
+	}
+	if _, err := a.GetProposal("unknown"); err != ErrProposalNotFound {
+		t.Errorf("ApprovalQuorum Expected ErrProposalNotFound but got '%v'", err)
+	}
+}
+
+func TestApprovalExpiry(t *testing.T) {
+	store, err := NewFileProposalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newTestApprovalClient(t, store)
+	now := time.Date(2014, 5, 12, 10, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+
+	_, err = a.SendMoney(&TransactionParams{To: "user1@example.com", Amount: MustParseMoney("1000", "USD"), Notes: "Payout"})
+	if !IsPendingApproval(err) {
+		t.Fatalf("ApprovalExpiry Expected a pending approval but got '%v'", err)
+	}
+
+	// Proposals are read back from the store
+	a = newTestApprovalClient(t, store)
+	a.now = func() time.Time { return now }
+	pending, err := a.PendingProposals()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 {
+		t.Fatalf("ApprovalExpiry Expected 1 pending proposal but got %d", len(pending))
+	}
+	id := pending[0].Id
+	compareString(t, "ApprovalExpiry", "1000.00 USD", pending[0].Params.Amount.String())
+	compareString(t, "ApprovalExpiry", "Payout", pending[0].Params.Notes)
+
+	now = now.Add(2 * time.Hour)
+	if _, err := a.Approve(id, "alice"); !errors.Is(err, ErrProposalClosed) {
+		t.Errorf("ApprovalExpiry Expected ErrProposalClosed but got '%v'", err)
+	}
+	proposal, err := store.Load(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "ApprovalExpiry", ProposalExpired, proposal.Status)
+
+	if _, err := store.Load("../" + id); err != ErrProposalNotFound {
+		t.Errorf("ApprovalExpiry Expected ErrProposalNotFound but got '%v'", err)
+	}
+}
+
+func TestApprovalPolicyChanges(t *testing.T) {
+	store := NewMemoryProposalStore()
+	a := newTestApprovalClient(t, store)
+	now := time.Date(2014, 5, 12, 10, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+	_, err := a.SendMoney(&TransactionParams{To: "user1@example.com", Amount: MustParseMoney("1000", "USD")})
+	var pending *PendingApproval
+	if !errors.As(err, &pending) {
+		t.Fatalf("ApprovalPolicyChanges Expected a pending approval but got '%v'", err)
+	}
+	id := pending.Proposal.Id
+	if _, err := a.Approve(id, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	// alice was removed from the approvers, so her approval no longer counts
+	a, err = NewApprovalClient(initTestClient(), ApprovalPolicy{Quorum: 2, Approvers: []string{"bob", "carol"}, TTL: time.Hour}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.now = func() time.Time { return now }
+	proposal, err := a.Approve(id, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "ApprovalPolicyChanges", ProposalPending, proposal.Status)
+	if _, err := a.Execute(id); err == nil || errors.Is(err, ErrProposalClosed) {
+		t.Errorf("ApprovalPolicyChanges Expected a missing approval error but got '%v'", err)
+	}
+
+	// Proposals which reached the quorum expire too if they were not executed
+	proposal.Approvals = append(proposal.Approvals, Approval{Approver: "carol", At: now})
+	if err := store.Save(proposal); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(2 * time.Hour)
+	if _, err := a.Execute(id); !errors.Is(err, ErrProposalClosed) {
+		t.Errorf("ApprovalPolicyChanges Expected ErrProposalClosed but got '%v'", err)
+	}
+	proposal, _ = store.Load(id)
+	compareString(t, "ApprovalPolicyChanges", ProposalExpired, proposal.Status)
+}
+
+func TestApprovalHandler(t *testing.T) {
+	a := newTestApprovalClient(t, NewMemoryProposalStore())
+	_, err := a.SendMoney(&TransactionParams{To: "user1@example.com", Amount: MustParseMoney("1000", "USD")})
+	var pending *PendingApproval
+	if !errors.As(err, &pending) {
+		t.Fatalf("ApprovalHandler Expected a pending approval but got '%v'", err)
+	}
+	h := NewApprovalHandler(a, func(req *http.Request) (string, error) {
+		if user := req.Header.Get("X-User"); user != "" {
+			return user, nil
+		}
+		return "", errors.New("No session")
+	})
+	serve := func(method string, path string, user string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	compareInt(t, "ApprovalHandler", http.StatusUnauthorized, int64(serve("GET", "/", "", "").Code))
+	w := serve("GET", "/", "alice", "")
+	compareInt(t, "ApprovalHandler", http.StatusOK, int64(w.Code))
+	proposals := []Proposal{}
+	if err := json.Unmarshal(w.Body.Bytes(), &proposals); err != nil {
+		t.Fatal(err)
+	}
+	if len(proposals) != 1 {
+		t.Fatalf("ApprovalHandler Expected 1 pending proposal but got %d", len(proposals))
+	}
+	compareString(t, "ApprovalHandler", pending.Proposal.Id, proposals[0].Id)
+
+	id := pending.Proposal.Id
+	compareInt(t, "ApprovalHandler", http.StatusForbidden, int64(serve("POST", "/"+id+"/approve", "mallory", "").Code))
+	compareInt(t, "ApprovalHandler", http.StatusMethodNotAllowed, int64(serve("GET", "/"+id+"/approve", "alice", "").Code))
+	compareInt(t, "ApprovalHandler", http.StatusOK, int64(serve("POST", "/"+id+"/approve", "alice", "").Code))
+	compareInt(t, "ApprovalHandler", http.StatusOK, int64(serve("POST", "/"+id+"/reject", "bob", `{"reason":"Unknown recipient"}`).Code))
+	compareInt(t, "ApprovalHandler", http.StatusConflict, int64(serve("POST", "/"+id+"/approve", "carol", "").Code))
+	compareInt(t, "ApprovalHandler", http.StatusNotFound, int64(serve("GET", "/unknown", "alice", "").Code))
+
+	proposal := Proposal{}
+	if err := json.Unmarshal(serve("GET", "/"+id, "alice", "").Body.Bytes(), &proposal); err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "ApprovalHandler", ProposalRejected, proposal.Status)
+	compareString(t, "ApprovalHandler", "bob", proposal.RejectedBy)
+	compareString(t, "ApprovalHandler", "Unknown recipient", proposal.Reason)
+}
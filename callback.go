@@ -0,0 +1,329 @@
+package coinbase
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// How long MemoryCallbackStore remembers callbacks to discard the ones
+// coinbase resends
+const callbackDedupWindow = 24 * time.Hour
+
+// errForgedCallback is returned when an address callback does not match the
+// transaction fetched from the API
+var errForgedCallback = errors.New("The callback does not match its transaction")
+
+// Maximum size of a callback payload
+const maxCallbackSize = 1 << 20
+
+// OrderCallback is the notification coinbase sends to the CallbackUrl of a
+// button when the status of one of its orders changes
+type OrderCallback struct {
+	Order Order `json:"order"`
+}
+
+// AddressCallback is the notification coinbase sends to the CallbackUrl of a
+// receive address when it receives a payment
+type AddressCallback struct {
+	Address     string      // Receive address paid
+	Amount      Money       // Amount received, in BTC
+	Transaction Transaction // Transaction of the payment
+}
+
+// CallbackHandler is an http.Handler receiving the callbacks of coinbase, to be
+// mounted at the CallbackUrl of buttons and receive addresses. Callbacks are
+// authenticated, deduplicated by ID and dispatched to the On* funcs:
+//
+//	h := coinbase.NewCallbackHandler(publicKey)
+//	h.OnOrderCompleted = func(cb *coinbase.OrderCallback) error { ... }
+//	http.Handle("/coinbase/callback", h)
+//
+// A callback is authentic when its X-Signature header holds a valid signature
+// of the payload by PublicKey, or, when PublicKey is nil, once it was fetched
+// again from the API through Client. Setting both checks the signature and
+// dispatches the fetched order or transaction, rejecting address callbacks
+// whose transaction did not pay the address. A func returning an error makes
+// the handler reply with status 500 so that coinbase sends the callback again.
+// Callbacks without a func to dispatch them to are acknowledged and dropped.
+//
+// Handled callbacks are remembered in Store, in memory for 24 hours if nil. Use
+// a FileCallbackStore to keep discarding them across restarts
+type CallbackHandler struct {
+	PublicKey *rsa.PublicKey
+	Client    *Client
+	Store     CallbackStore
+
+	OnOrderCompleted func(*OrderCallback) error
+	OnOrderMispaid   func(*OrderCallback) error
+	OnOrder          func(*OrderCallback) error // Orders of any other status, i.e expired
+	OnAddressPayment func(*AddressCallback) error
+
+	mu     sync.Mutex
+	memory *MemoryCallbackStore // Store used when Store is nil
+}
+
+// NewCallbackHandler instantiates a CallbackHandler verifying callback
+// signatures with publicKey
+func NewCallbackHandler(publicKey *rsa.PublicKey) *CallbackHandler {
+	return &CallbackHandler{PublicKey: publicKey}
+}
+
+// ParseCallbackPublicKey parses the PEM encoded public key coinbase signs
+// callbacks with
+func ParseCallbackPublicKey(pemData []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("No PEM encoded public key found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("The callback public key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// ServeHTTP authenticates and dispatches a callback
+func (h *CallbackHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxCallbackSize))
+	if err != nil {
+		http.Error(w, "Invalid callback", http.StatusBadRequest)
+		return
+	}
+	if h.PublicKey == nil && h.Client == nil {
+		http.Error(w, "Callbacks cannot be authenticated", http.StatusInternalServerError)
+		return
+	}
+	if h.PublicKey != nil && !h.verify(body, req.Header.Get("X-Signature")) {
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	payload := struct {
+		Order       *Order          `json:"order"`
+		Address     string          `json:"address"`
+		Amount      json.Number     `json:"amount"`
+		Transaction json.RawMessage `json:"transaction"`
+	}{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, "Invalid callback", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case payload.Order != nil:
+		err = h.dispatchOrder(req, &OrderCallback{Order: *payload.Order})
+	case payload.Address != "":
+		cb := &AddressCallback{Address: payload.Address}
+		if cb.Amount, err = ParseMoney(payload.Amount.String(), "BTC"); err != nil {
+			http.Error(w, "Invalid callback amount", http.StatusBadRequest)
+			return
+		}
+		if err := json.Unmarshal(payload.Transaction, &cb.Transaction); err != nil || cb.Transaction.Id == "" {
+			http.Error(w, "Invalid callback transaction", http.StatusBadRequest)
+			return
+		}
+		err = h.dispatchAddress(req, cb)
+	default:
+		http.Error(w, "Unknown callback", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, errForgedCallback) {
+		http.Error(w, "The callback does not match its transaction", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "The callback could not be handled", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// verify checks signature, the base64 encoded RSA signature of the SHA256 of
+// body
+func (h *CallbackHandler) verify(body []byte, signature string) bool {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || len(sig) == 0 {
+		return false
+	}
+	hashed := sha256.Sum256(body)
+	return rsa.VerifyPKCS1v15(h.PublicKey, crypto.SHA256, hashed[:], sig) == nil
+}
+
+func (h *CallbackHandler) dispatchOrder(req *http.Request, cb *OrderCallback) error {
+	if h.Client != nil {
+		o, err := h.Client.WithContext(req.Context()).GetOrder(cb.Order.Id)
+		if err != nil {
+			return err
+		}
+		cb.Order = *o
+	}
+	handler := h.OnOrder
+	switch cb.Order.Status {
+	case "completed":
+		handler = h.OnOrderCompleted
+	case "mispaid":
+		handler = h.OnOrderMispaid
+	}
+	// The status is part of the key since an order may be notified once per status
+	return h.dispatch("order:"+cb.Order.Id+":"+cb.Order.Status, handler != nil, func() error {
+		return handler(cb)
+	})
+}
+
+func (h *CallbackHandler) dispatchAddress(req *http.Request, cb *AddressCallback) error {
+	if h.Client != nil {
+		tx, err := h.Client.WithContext(req.Context()).GetTransaction(cb.Transaction.Id)
+		if err != nil {
+			return err
+		}
+		// The payload is only trusted for the ID of the transaction
+		if tx.RecipientAddress != cb.Address || tx.Amount.Sign() <= 0 {
+			return errForgedCallback
+		}
+		cb.Transaction = *tx
+		cb.Amount = tx.Amount
+	}
+	return h.dispatch("transaction:"+cb.Transaction.Id, h.OnAddressPayment != nil, func() error {
+		return h.OnAddressPayment(cb)
+	})
+}
+
+// dispatch calls handle unless the callback identified by key was already
+// handled. The key is reserved while handle runs so that concurrent deliveries
+// of a callback are handled once, and released if handle fails
+func (h *CallbackHandler) dispatch(key string, ok bool, handle func() error) error {
+	if !ok {
+		return nil
+	}
+	store := h.store()
+	reserved, err := store.Reserve(key)
+	if err != nil || !reserved {
+		return err
+	}
+	if err := handle(); err != nil {
+		if releaseErr := store.Release(key); releaseErr != nil {
+			return releaseErr
+		}
+		return err
+	}
+	return nil
+}
+
+func (h *CallbackHandler) store() CallbackStore {
+	if h.Store != nil {
+		return h.Store
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.memory == nil {
+		h.memory = NewMemoryCallbackStore()
+	}
+	return h.memory
+}
+
+// CallbackStore remembers the callbacks a CallbackHandler handled, keyed by
+// order or transaction. Stores must be safe for concurrent use
+type CallbackStore interface {
+	Reserve(key string) (bool, error) // Records key, returns false if it already was
+	Release(key string) error         // Forgets key, i.e when handling failed
+}
+
+// MemoryCallbackStore is a CallbackStore remembering callbacks in memory for
+// 24 hours
+type MemoryCallbackStore struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+	now  func() time.Time
+}
+
+// NewMemoryCallbackStore instantiates an empty MemoryCallbackStore
+func NewMemoryCallbackStore() *MemoryCallbackStore {
+	return &MemoryCallbackStore{seen: map[string]time.Time{}, now: time.Now}
+}
+
+// Reserve records key unless it was recorded less than 24 hours ago
+func (s *MemoryCallbackStore) Reserve(key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for k, t := range s.seen {
+		if now.Sub(t) > callbackDedupWindow {
+			delete(s.seen, k)
+		}
+	}
+	if _, dup := s.seen[key]; dup {
+		return false, nil
+	}
+	s.seen[key] = now
+	return true, nil
+}
+
+// Release forgets key
+func (s *MemoryCallbackStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.seen, key)
+	return nil
+}
+
+// FileCallbackStore is a CallbackStore remembering each callback with an empty
+// file of its own, named after the SHA256 of its key. Processes sharing the
+// directory handle each callback once. Callbacks are remembered until their
+// file is removed
+type FileCallbackStore struct {
+	dir string
+}
+
+// NewFileCallbackStore instantiates a FileCallbackStore saving files in dir,
+// which is created if needed
+func NewFileCallbackStore(dir string) (*FileCallbackStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileCallbackStore{dir: dir}, nil
+}
+
+func (s *FileCallbackStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".callback")
+}
+
+// Reserve creates the file of key, failing if it exists
+func (s *FileCallbackStore) Reserve(key string) (bool, error) {
+	f, err := os.OpenFile(s.path(key), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, os.ErrExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, f.Close()
+}
+
+// Release removes the file of key
+func (s *FileCallbackStore) Release(key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package coinbase
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fabioberger/coinbase-go/coinbasetest"
+)
+
+const orderCallback = `{"order":{"id":"5RTQNACF","created_at":"2012-12-09T21:23:41-08:00","status":"completed","total_btc":{"cents":100000000,"currency_iso":"BTC"},"custom":"order1234"}}`
+
+func signCallback(t *testing.T, key *rsa.PrivateKey, body string) string {
+	hashed := sha256.Sum256([]byte(body))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+func postCallback(h http.Handler, body string, signature string) int {
+	req := httptest.NewRequest("POST", "/callback", strings.NewReader(body))
+	req.Header.Set("X-Signature", signature)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestCallbackHandlerSignature(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	publicKey, err := ParseCallbackPublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	completed := 0
+	h := NewCallbackHandler(publicKey)
+	h.OnOrderCompleted = func(cb *OrderCallback) error {
+		completed++
+		compareString(t, "CallbackHandler", "order1234", cb.Order.Custom)
+		compareString(t, "CallbackHandler", "1.00000000 BTC", cb.Order.TotalBtc.String())
+		return nil
+	}
+	signature := signCallback(t, key, orderCallback)
+	compareInt(t, "CallbackHandler", http.StatusUnauthorized, int64(postCallback(h, orderCallback, "")))
+	compareInt(t, "CallbackHandler", http.StatusUnauthorized, int64(postCallback(h, strings.Replace(orderCallback, "order1234", "order9999", 1), signature)))
+	compareInt(t, "CallbackHandler", 0, int64(completed))
+
+	// Callbacks sent again are acknowledged without being dispatched twice
+	compareInt(t, "CallbackHandler", http.StatusOK, int64(postCallback(h, orderCallback, signature)))
+	compareInt(t, "CallbackHandler", http.StatusOK, int64(postCallback(h, orderCallback, signature)))
+	compareInt(t, "CallbackHandler", 1, int64(completed))
+
+	// A failing handler gets the callback again
+	payments := 0
+	h.OnAddressPayment = func(cb *AddressCallback) error {
+		payments++
+		compareString(t, "CallbackHandler", "0.00500000 BTC", cb.Amount.String())
+		if payments == 1 {
+			return errors.New("database unavailable")
+		}
+		return nil
+	}
+	addressCallback := `{"address":"1NhwPYPgoPwr5hynRAsto5ZgEcw1LzM3My","amount":0.005,"transaction":{"id":"514f18b7a5ea3d630a00000f","created_at":"2013-03-24T08:07:35-07:00","hash":"4cc5eec20cd692f3cdb7fc264a0e1d78b9a7e3d7b862dec1e39cf7e37ababc14"}}`
+	signature = signCallback(t, key, addressCallback)
+	compareInt(t, "CallbackHandler", http.StatusInternalServerError, int64(postCallback(h, addressCallback, signature)))
+	compareInt(t, "CallbackHandler", http.StatusOK, int64(postCallback(h, addressCallback, signature)))
+	compareInt(t, "CallbackHandler", http.StatusOK, int64(postCallback(h, addressCallback, signature)))
+	compareInt(t, "CallbackHandler", 2, int64(payments))
+}
+
+func TestCallbackHandlerRefetch(t *testing.T) {
+	c := initTestClient()
+	h := &CallbackHandler{Client: &c}
+	var got *OrderCallback
+	h.OnOrderCompleted = func(cb *OrderCallback) error {
+		got = cb
+		return nil
+	}
+	// The payload is replaced with the order fetched from the API
+	mispaid := strings.Replace(orderCallback, `"completed"`, `"mispaid"`, 1)
+	compareInt(t, "CallbackHandlerRefetch", http.StatusOK, int64(postCallback(h, mispaid, "")))
+	if got == nil {
+		t.Fatal("CallbackHandlerRefetch Expected the completed order to be dispatched")
+	}
+	compareString(t, "CallbackHandlerRefetch", "A7C52JQT", got.Order.Id)
+
+	compareInt(t, "CallbackHandlerRefetch", http.StatusBadRequest, int64(postCallback(h, `{"unknown":true}`, "")))
+	compareInt(t, "CallbackHandlerRefetch", http.StatusInternalServerError, int64(postCallback(&CallbackHandler{}, orderCallback, "")))
+}
+
+func TestCallbackHandlerRefetchAddress(t *testing.T) {
+	s := coinbasetest.NewServer()
+	defer s.Close()
+	s.Respond("GET", "transactions/:id", 200, `{"transaction":{"id":"514f18b7a5ea3d630a00000f","amount":{"amount":"0.00500000","currency":"BTC"},"recipient_address":"1NhwPYPgoPwr5hynRAsto5ZgEcw1LzM3My"}}`)
+	c := ApiKeyClient(s.Key, s.Secret, WithBaseURL(s.BaseURL()))
+	store, err := NewFileCallbackStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	payments := 0
+	newHandler := func() *CallbackHandler {
+		h := &CallbackHandler{Client: &c, Store: store}
+		h.OnAddressPayment = func(cb *AddressCallback) error {
+			payments++
+			compareString(t, "CallbackHandlerRefetchAddress", "0.00500000 BTC", cb.Amount.String())
+			return nil
+		}
+		return h
+	}
+
+	// The transaction paid another address than the one claimed
+	forged := `{"address":"1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2","amount":50,"transaction":{"id":"514f18b7a5ea3d630a00000f"}}`
+	compareInt(t, "CallbackHandlerRefetchAddress", http.StatusUnauthorized, int64(postCallback(newHandler(), forged, "")))
+	compareInt(t, "CallbackHandlerRefetchAddress", 0, int64(payments))
+
+	// The amount is taken from the transaction, and a callback sent again after
+	// a restart is not dispatched twice
+	callback := `{"address":"1NhwPYPgoPwr5hynRAsto5ZgEcw1LzM3My","amount":50,"transaction":{"id":"514f18b7a5ea3d630a00000f"}}`
+	compareInt(t, "CallbackHandlerRefetchAddress", http.StatusOK, int64(postCallback(newHandler(), callback, "")))
+	compareInt(t, "CallbackHandlerRefetchAddress", http.StatusOK, int64(postCallback(newHandler(), callback, "")))
+	compareInt(t, "CallbackHandlerRefetchAddress", 1, int64(payments))
+}
@@ -0,0 +1,391 @@
+// Package cassette records the HTTP interactions of a client with the coinbase
+// API into a file, the cassette, and replays them later without network
+// access. Credentials are never written to a cassette: request headers are not
+// recorded, and tokens, passwords and email addresses found in URLs and bodies
+// are redacted.
+package cassette
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Mode selects whether a Recorder records or replays interactions
+type Mode int
+
+const (
+	// Replay answers requests with the recorded responses. A request matching
+	// none of the recorded interactions fails with an error
+	Replay Mode = iota
+	// Record sends requests to the server and records every interaction,
+	// replacing the previous content of the cassette
+	Record
+)
+
+// Cassette is the content of a cassette file
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a request and the response the server sent back
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a recorded request. Query is encoded with its keys sorted, and
+// Body holds the JSON payload, if any
+type Request struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Query  string          `json:"query,omitempty"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// Response is a recorded response. The body is held in Body when it is JSON
+// and in Text otherwise
+type Response struct {
+	Status int               `json:"status"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   json.RawMessage   `json:"body,omitempty"`
+	Text   string            `json:"text,omitempty"`
+}
+
+// Load reads the cassette file at path
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse decodes a cassette
+func Parse(data []byte) (*Cassette, error) {
+	c := Cassette{}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Save writes the cassette to path. It is written to a temporary file renamed
+// over path, so that path holds either the previous or the new cassette, never
+// a partial one
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".cassette-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ResponseBody returns the body of the response as sent by the server
+func (r Response) ResponseBody() []byte {
+	if r.Body != nil {
+		buf := bytes.Buffer{}
+		if err := json.Compact(&buf, r.Body); err == nil {
+			return buf.Bytes()
+		}
+		return r.Body
+	}
+	return []byte(r.Text)
+}
+
+// Recorder records interactions into a cassette file or replays them, through
+// the http.RoundTripper returned by Transport. It is safe for concurrent use
+type Recorder struct {
+	path     string
+	mode     Mode
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool // Interactions already replayed
+}
+
+// New instantiates a Recorder for the cassette file at path. In Replay mode the
+// cassette is loaded immediately, and an error is returned if it cannot be
+func New(path string, mode Mode) (*Recorder, error) {
+	r := &Recorder{path: path, mode: mode, cassette: &Cassette{}}
+	if mode == Replay {
+		c, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		r.cassette = c
+		r.used = make([]bool, len(c.Interactions))
+	}
+	return r, nil
+}
+
+// Transport returns an http.RoundTripper recording the interactions sent
+// through next, or replaying them without using next. next defaults to
+// http.DefaultTransport
+func (r *Recorder) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{recorder: r, next: next}
+}
+
+type transport struct {
+	recorder *Recorder
+	next     http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	recorded := newRequest(req, body)
+	if t.recorder.mode == Replay {
+		return t.recorder.replay(req, recorded)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	if err := t.recorder.record(Interaction{Request: recorded, Response: newResponse(resp, data)}); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// replay returns the response of the first interaction matching recorded that
+// was not replayed yet
+func (r *Recorder) replay(req *http.Request, recorded Request) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] || !interaction.Request.matches(recorded) {
+			continue
+		}
+		r.used[i] = true
+		header := http.Header{}
+		for k, v := range interaction.Response.Header {
+			header.Set(k, v)
+		}
+		body := interaction.Response.ResponseBody()
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Response.Status, http.StatusText(interaction.Response.Status)),
+			StatusCode:    interaction.Response.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+	message := fmt.Sprintf("cassette: no interaction of %s matches %s %s", r.path, recorded.Method, recorded.Path)
+	if recorded.Query != "" {
+		message += "?" + recorded.Query
+	}
+	if recorded.Body != nil {
+		message += " with body " + string(recorded.Body)
+	}
+	return nil, errors.New(message)
+}
+
+// record appends an interaction and saves the cassette, so that interactions
+// are kept even if the program does not exit cleanly
+func (r *Recorder) record(interaction Interaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	return r.cassette.Save(r.path)
+}
+
+// matches compares a recorded request with other. Bodies are compared in
+// their canonical encoding since cassette files are indented, and without the
+// values generated for every request
+func (r Request) matches(other Request) bool {
+	return r.Method == other.Method && r.Path == other.Path && r.Query == other.Query &&
+		bytes.Equal(withoutGenerated(redactJSON(r.Body)), withoutGenerated(other.Body))
+}
+
+// Keys whose values are generated anew for every request, i.e the idempotency
+// keys the client adds to the requests moving money
+var generatedKeys = map[string]bool{
+	"idem": true,
+}
+
+// withoutGenerated returns the canonical JSON body with the values of
+// generatedKeys blanked out
+func withoutGenerated(body json.RawMessage) json.RawMessage {
+	if body == nil {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return body
+	}
+	blankGenerated(v)
+	canonical, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return canonical
+}
+
+func blankGenerated(v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, value := range v {
+			if _, ok := value.(string); ok && generatedKeys[k] {
+				v[k] = ""
+			} else {
+				blankGenerated(value)
+			}
+		}
+	case []interface{}:
+		for _, value := range v {
+			blankGenerated(value)
+		}
+	}
+}
+
+func newRequest(req *http.Request, body []byte) Request {
+	return Request{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  redactQuery(req.URL.Query()),
+		Body:   redactJSON(body),
+	}
+}
+
+// Response headers worth recording, the others vary with every response
+var recordedHeaders = []string{"Content-Type", "Retry-After", "X-Request-Id", "CB-Request-Id", "CB-Version"}
+
+func newResponse(resp *http.Response, body []byte) Response {
+	r := Response{Status: resp.StatusCode, Header: map[string]string{}}
+	for _, k := range recordedHeaders {
+		if v := resp.Header.Get(k); v != "" {
+			r.Header[k] = v
+		}
+	}
+	if r.Body = redactJSON(body); r.Body == nil {
+		r.Text = redactEmails(string(body))
+	}
+	return r
+}
+
+// Keys whose values are credentials
+var secretKeys = map[string]bool{
+	"access_token":   true,
+	"refresh_token":  true,
+	"client_secret":  true,
+	"password":       true,
+	"user[password]": true,
+	"api_key":        true,
+	"api_secret":     true,
+}
+
+const redacted = "REDACTED"
+
+func redactQuery(query url.Values) string {
+	for k := range query {
+		if secretKeys[k] {
+			query[k] = []string{redacted}
+		}
+		for i, v := range query[k] {
+			query[k][i] = redactEmails(v)
+		}
+	}
+	return query.Encode()
+}
+
+// redactJSON returns data with credentials and emails redacted, in a canonical
+// encoding so that payloads can be compared. It returns nil if data is empty,
+// null or not JSON
+func redactJSON(data []byte) json.RawMessage {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil || v == nil {
+		return nil
+	}
+	canonical, err := json.Marshal(redactValue("", v))
+	if err != nil {
+		return nil
+	}
+	return canonical
+}
+
+func redactValue(key string, v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, value := range v {
+			v[k] = redactValue(k, value)
+		}
+		return v
+	case []interface{}:
+		for i, value := range v {
+			v[i] = redactValue(key, value)
+		}
+		return v
+	case string:
+		if secretKeys[key] {
+			return redacted
+		}
+		return redactEmails(v)
+	}
+	return v
+}
+
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// redactEmails replaces email addresses with placeholders derived from a hash
+// of the address, so that distinct addresses stay distinct
+func redactEmails(s string) string {
+	return emailPattern.ReplaceAllStringFunc(s, func(email string) string {
+		if strings.HasPrefix(email, "redacted-") && strings.HasSuffix(email, "@example.com") {
+			return email // Already redacted
+		}
+		sum := sha256.Sum256([]byte(email))
+		return "redacted-" + hex.EncodeToString(sum[:4]) + "@example.com"
+	})
+}
@@ -0,0 +1,114 @@
+package cassette
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRedactJSON(t *testing.T) {
+	got := string(redactJSON([]byte(`{"to":"user1@example.com","access_token":"abc","nested":[{"refresh_token":"def","amount":1.50}]}`)))
+	expected := `{"access_token":"REDACTED","nested":[{"amount":1.50,"refresh_token":"REDACTED"}],"to":"redacted-` + redactEmails("user1@example.com")[9:17] + `@example.com"}`
+	if got != expected {
+		t.Errorf("redactJSON Expected %s but got %s", expected, got)
+	}
+	if redactEmails("a@example.com") == redactEmails("b@example.com") {
+		t.Error("redactEmails Expected distinct addresses to stay distinct")
+	}
+	if redactJSON([]byte("null")) != nil || redactJSON([]byte("<html>")) != nil {
+		t.Error("redactJSON Expected nil for null and non JSON bodies")
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "req-1")
+		w.Write([]byte(`{"echo":` + string(body) + `,"email":"user2@example.com"}`))
+	}))
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	recorder, err := New(path, Record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := http.Client{Transport: recorder.Transport(nil)}
+	req, _ := http.NewRequest("POST", srv.URL+"/api/v1/buys?access_token=secret", strings.NewReader(`{"qty":"1", "currency":"USD"}`))
+	req.Header.Set("ACCESS_KEY", "my-key")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	srv.Close()
+
+	data, _ := os.ReadFile(path)
+	for _, secret := range []string{"my-key", "secret", "user2@example.com"} {
+		if bytes.Contains(data, []byte(secret)) {
+			t.Errorf("Record Expected %q to be redacted from %s", secret, data)
+		}
+	}
+	// The cassette is renamed into place, no temporary file is left behind
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("Record Expected only the cassette in its directory but got %d files", len(entries))
+	}
+
+	recorder, err = New(path, Replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = http.Client{Transport: recorder.Transport(nil)}
+	// Matching ignores the order of the keys of JSON bodies
+	resp, err = client.Post(srv.URL+"/api/v1/buys?access_token=other", "application/json", strings.NewReader(`{"currency":"USD","qty":"1"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayedBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || resp.Header.Get("X-Request-Id") != "req-1" {
+		t.Errorf("Replay Unexpected response %d %v", resp.StatusCode, resp.Header)
+	}
+	if !bytes.Contains(replayedBody, []byte(`"echo":{"currency":"USD","qty":"1"}`)) || bytes.Contains(replayedBody, []byte("user2@example.com")) {
+		t.Errorf("Replay Unexpected body %s", replayedBody)
+	}
+
+	_, err = client.Post(srv.URL+"/api/v1/buys", "application/json", strings.NewReader(`{"qty":"2"}`))
+	if err == nil || !strings.Contains(err.Error(), "no interaction") {
+		t.Errorf("Replay Expected an unmatched request error but got '%v'", err)
+	}
+}
+
+func TestReplayIgnoresIdem(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"success":true}`))
+	}))
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	recorder, err := New(path, Record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := http.Client{Transport: recorder.Transport(nil)}
+	if _, err := client.Post(srv.URL+"/api/v1/buys", "application/json", strings.NewReader(`{"qty":"1","idem":"a4c1"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder, err = New(path, Replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = http.Client{Transport: recorder.Transport(nil)}
+	// Idempotency keys are generated for every request
+	if _, err := client.Post(srv.URL+"/api/v1/buys", "application/json", strings.NewReader(`{"qty":"1","idem":"77f0"}`)); err != nil {
+		t.Errorf("Replay Expected a request with another Idem to match but got '%v'", err)
+	}
+	_, err = client.Post(srv.URL+"/api/v1/buys", "application/json", strings.NewReader(`{"qty":"2","idem":"a4c1"}`))
+	if err == nil || !strings.Contains(err.Error(), "no interaction") {
+		t.Errorf("Replay Expected an unmatched request error but got '%v'", err)
+	}
+}
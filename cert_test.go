@@ -0,0 +1,94 @@
+package coinbase
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEmbeddedCertificates(t *testing.T) {
+	if _, err := coinbaseCertPool(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OAuthService("id", "secret", "https://example.com/tokens"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPinnedCertificates(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"amount":"1.00000000","currency":"BTC"}`))
+	}))
+	defer srv.Close()
+
+	// The test server certificate is not signed by the coinbase certificate authorities
+	c := ApiKeyClient("key", "secret", WithBaseURL(srv.URL), WithPinnedCA(), WithRetries(NoRetries))
+	if _, err := c.GetBalance(); err == nil {
+		t.Error("PinnedCertificates Expected a certificate error")
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	c = ApiKeyClient("key", "secret", WithBaseURL(srv.URL), WithCertPool(pool))
+	if _, err := c.GetBalance(); err != nil {
+		t.Fatal(err)
+	}
+
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	c = ApiKeyClient("key", "secret", WithBaseURL(srv.URL), WithCertPEM(pemData))
+	if _, err := c.GetBalance(); err != nil {
+		t.Fatal(err)
+	}
+
+	c = ApiKeyClient("key", "secret", WithCertPEM([]byte("not a certificate")))
+	if _, err := c.GetBalance(); err == nil {
+		t.Error("PinnedCertificates Expected an error for invalid PEM data")
+	}
+	if _, err := OAuthService("id", "secret", "https://example.com/tokens", WithCertPEM(nil)); err == nil {
+		t.Error("PinnedCertificates Expected an error for invalid PEM data")
+	}
+}
+
+// roundTripFunc is an http.RoundTripper other than *http.Transport
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestPinnedCertificatesWithHTTPClient(t *testing.T) {
+	hits := 0
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		hits++
+		w.Write([]byte(`{"amount":"1.00000000","currency":"BTC"}`))
+	}))
+	defer srv.Close()
+
+	// srv.Client() trusts the test server certificate, which the pin still rejects
+	c := ApiKeyClient("key", "secret", WithBaseURL(srv.URL), WithHTTPClient(srv.Client()), WithPinnedCA(), WithRetries(NoRetries))
+	if _, err := c.GetBalance(); err == nil {
+		t.Error("PinnedCertificatesWithHTTPClient Expected a certificate error")
+	}
+	compareInt(t, "PinnedCertificatesWithHTTPClient", 0, int64(hits))
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	c = ApiKeyClient("key", "secret", WithBaseURL(srv.URL), WithHTTPClient(&http.Client{}), WithCertPool(pool))
+	if _, err := c.GetBalance(); err != nil {
+		t.Fatal(err)
+	}
+
+	custom := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return srv.Client().Transport.RoundTrip(req)
+	})}
+	c = ApiKeyClient("key", "secret", WithBaseURL(srv.URL), WithHTTPClient(custom), WithPinnedCA())
+	if _, err := c.GetBalance(); err != errUnpinnable {
+		t.Errorf("PinnedCertificatesWithHTTPClient Expected errUnpinnable but got '%v'", err)
+	}
+	if _, err := OAuthService("id", "secret", "https://example.com/tokens", WithHTTPClient(custom)); err != errUnpinnable {
+		t.Errorf("PinnedCertificatesWithHTTPClient Expected errUnpinnable but got '%v'", err)
+	}
+	compareInt(t, "PinnedCertificatesWithHTTPClient", 1, int64(hits))
+}
@@ -1,51 +1,120 @@
 package coinbase
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"sync"
 	"time"
-
-	"github.com/fabioberger/coinbase-go/config"
 )
 
+// Tokens are refreshed this many seconds before they expire so that requests
+// in flight do not use a token expiring under them
+const tokenRefreshMargin = 60
+
 // ClientOAuthAuthentication Struct implements the Authentication interface
 // and takes care of authenticating OAuth RPC requests on behalf of a client
-// (i.e GetBalance())
+// (i.e GetBalance()). When service is set, tokens are refreshed automatically
 type clientOAuthAuthentication struct {
-	Tokens  *oauthTokens
-	BaseUrl string
-	Client  http.Client
+	Tokens    *Tokens
+	BaseUrl   string
+	Client    http.Client
+	service   *OAuth
+	onRefresh func(*Tokens)
+	persist   func(*Tokens) error // Saves refreshed tokens to a TokenStore
+	mu        sync.Mutex
 }
 
 // ClientOAuth instantiates ClientOAuthAuthentication with the client OAuth tokens
-func clientOAuth(tokens *oauthTokens) *clientOAuthAuthentication {
+func clientOAuth(tokens *Tokens, cfg clientConfig) *clientOAuthAuthentication {
 	a := clientOAuthAuthentication{
 		Tokens:  tokens,
-		BaseUrl: config.BaseUrl,
-		Client: http.Client{
-			Transport: &http.Transport{
-				Dial: dialTimeout,
-			},
-		},
+		BaseUrl: cfg.baseUrlOr(cfg.env.BaseUrl),
+		Client:  newHTTPClient(cfg, nil),
 	}
 	return &a
 }
 
 // Client OAuth authentication requires us to attach an unexpired OAuth token to
-// the request header
-func (a clientOAuthAuthentication) authenticate(req *http.Request, endpoint string, params []byte) error {
+// the request header. Tokens about to expire are refreshed first when possible
+func (a *clientOAuthAuthentication) authenticate(req *http.Request, endpoint string, params []byte) error {
+	a.mu.Lock()
+	var refreshed *Tokens
+	if a.service != nil && time.Now().UTC().Unix() > a.Tokens.ExpireTime-tokenRefreshMargin {
+		tokens, err := a.refresh(req.Context())
+		if err != nil {
+			a.mu.Unlock()
+			return err
+		}
+		refreshed = tokens
+	}
+	tokens := a.Tokens
+	a.mu.Unlock()
+	if refreshed != nil {
+		if err := a.notify(refreshed); err != nil {
+			return err
+		}
+	}
 	// Ensure tokens havent expired
-	if time.Now().UTC().Unix() > a.Tokens.ExpireTime {
+	if time.Now().UTC().Unix() > tokens.ExpireTime {
 		return errors.New("The OAuth tokens are expired. Use refreshTokens to refresh them")
 	}
-	req.Header.Set("Authorization", "Bearer "+a.Tokens.AccessToken)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	return nil
+}
+
+// refreshAfterUnauthorized refreshes the tokens after coinbase rejected them
+// for the given request. Nothing is done if the tokens were already refreshed
+// since the request was sent. It reports whether the request should be resent
+func (a *clientOAuthAuthentication) refreshAfterUnauthorized(ctx context.Context, req *http.Request) (bool, error) {
+	if a.service == nil {
+		return false, nil
+	}
+	a.mu.Lock()
+	if req.Header.Get("Authorization") != "Bearer "+a.Tokens.AccessToken {
+		a.mu.Unlock()
+		return true, nil
+	}
+	tokens, err := a.refresh(ctx)
+	a.mu.Unlock()
+	if err != nil {
+		return false, err
+	}
+	if err := a.notify(tokens); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// refresh exchanges the refresh token for new tokens. It must be called with
+// mu held
+func (a *clientOAuthAuthentication) refresh(ctx context.Context) (*Tokens, error) {
+	tokens, err := a.service.WithContext(ctx).RefreshTokens(a.Tokens)
+	if err != nil {
+		return nil, err
+	}
+	a.Tokens = tokens
+	return tokens, nil
+}
+
+// notify hands refreshed tokens to the refresh callback and the token store
+func (a *clientOAuthAuthentication) notify(tokens *Tokens) error {
+	if a.onRefresh != nil {
+		a.onRefresh(tokens)
+	}
+	if a.persist != nil {
+		if err := a.persist(tokens); err != nil {
+			return fmt.Errorf("The OAuth tokens were refreshed but could not be saved: %v", err)
+		}
+	}
 	return nil
 }
 
-func (a clientOAuthAuthentication) getBaseUrl() string {
+func (a *clientOAuthAuthentication) getBaseUrl() string {
 	return a.BaseUrl
 }
 
-func (a clientOAuthAuthentication) getClient() *http.Client {
+func (a *clientOAuthAuthentication) getClient() *http.Client {
 	return &a.Client
 }
@@ -0,0 +1,81 @@
+package coinbase
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Serves oauth/token, issuing a new access token on every refresh, and
+// account/balance, accepting only the latest access token
+func oauthServer(refreshes *int) *httptest.Server {
+	var mu sync.Mutex
+	current := "access0"
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch req.URL.Path {
+		case "/oauth/token":
+			*refreshes++
+			current = "access" + strconv.Itoa(*refreshes)
+			w.Write([]byte(`{"access_token":"` + current + `","refresh_token":"refresh","expires_in":7200}`))
+		case "/account/balance":
+			if req.Header.Get("Authorization") != "Bearer "+current {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"amount":"1.00000000","currency":"BTC"}`))
+		}
+	}))
+}
+
+func testRefreshingClient(url string, tokens *Tokens, onRefresh func(*Tokens)) Client {
+	service := &OAuth{Rpc: rpc{auth: &serviceOAuthAuthentication{BaseUrl: url + "/"}}}
+	return RefreshingOAuthClient(tokens, service, onRefresh, WithBaseURL(url))
+}
+
+func TestOAuthRefreshBeforeExpiry(t *testing.T) {
+	refreshes := 0
+	srv := oauthServer(&refreshes)
+	defer srv.Close()
+
+	var saved *Tokens
+	expired := &Tokens{AccessToken: "access0", RefreshToken: "refresh", ExpireTime: time.Now().Unix() - 10}
+	c := testRefreshingClient(srv.URL, expired, func(tokens *Tokens) { saved = tokens })
+	if _, err := c.GetBalance(); err != nil {
+		t.Fatal(err)
+	}
+	compareInt(t, "OAuthRefreshBeforeExpiry", 1, int64(refreshes))
+	compareString(t, "OAuthRefreshBeforeExpiry", "access1", saved.AccessToken)
+}
+
+func TestOAuthRefreshOnUnauthorized(t *testing.T) {
+	refreshes := 0
+	srv := oauthServer(&refreshes)
+	defer srv.Close()
+
+	revoked := &Tokens{AccessToken: "revoked", RefreshToken: "refresh", ExpireTime: time.Now().Unix() + 3600}
+	c := testRefreshingClient(srv.URL, revoked, nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.GetBalance(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	compareInt(t, "OAuthRefreshOnUnauthorized", 1, int64(refreshes))
+}
+
+func TestOAuthExpiredWithoutService(t *testing.T) {
+	c := OAuthClient(&Tokens{AccessToken: "access", ExpireTime: time.Now().Unix() - 10})
+	if _, err := c.GetBalance(); err == nil {
+		t.Error("OAuthExpiredWithoutService Expected an error for expired tokens")
+	}
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"flag"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fabioberger/coinbase-go"
+)
+
+func runBalance(c *cli, args []string) error {
+	fs := c.flags("balance")
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+	client, err := c.client()
+	if err != nil {
+		return err
+	}
+	balance, err := client.GetBalance()
+	if err != nil {
+		return err
+	}
+	t := &table{header: []string{"amount", "currency"}, value: balance}
+	t.add(balance.Amount(), balance.Currency)
+	return c.print(t)
+}
+
+func runAddress(c *cli, args []string) error {
+	if len(args) == 0 {
+		return usagef("missing address subcommand")
+	}
+	switch args[0] {
+	case "new":
+		fs := c.flags("address new")
+		params := coinbase.AddressParams{}
+		fs.StringVar(&params.Label, "label", "", "label of the address")
+		fs.StringVar(&params.CallbackUrl, "callback-url", "", "`URL` notified of the payments to the address")
+		if err := c.parse(fs, args[1:]); err != nil {
+			return err
+		}
+		client, err := c.client()
+		if err != nil {
+			return err
+		}
+		address, err := client.GenerateReceiveAddress(&params)
+		if err != nil {
+			return err
+		}
+		t := &table{header: []string{"address"}, value: map[string]string{"address": address}}
+		t.add(address)
+		return c.print(t)
+	case "list":
+		fs := c.flags("address list")
+		params := coinbase.AddressesParams{}
+		fs.Int64Var(&params.Page, "page", 1, "page to list")
+		fs.Int64Var(&params.Limit, "limit", 0, "number of addresses per page")
+		fs.StringVar(&params.Query, "query", "", "only list the addresses matching the `query`")
+		if err := c.parse(fs, args[1:]); err != nil {
+			return err
+		}
+		client, err := c.client()
+		if err != nil {
+			return err
+		}
+		addresses, err := client.GetAllAddresses(&params)
+		if err != nil {
+			return err
+		}
+		t := &table{header: []string{"address", "label", "callback_url", "created_at"}, value: addresses}
+		for _, a := range addresses.Addresses {
+			t.add(a.Address, a.Label, a.CallbackUrl, formatTime(a.CreatedAt))
+		}
+		return c.print(t)
+	}
+	return usagef("unknown address subcommand %q", args[0])
+}
+
+// transactionFlags registers the flags of send and request on fs
+func transactionFlags(fs *flag.FlagSet, params *coinbase.TransactionParams) {
+	fs.StringVar(&params.Notes, "notes", "", "notes shown to the recipient")
+	fs.StringVar(&params.Idem, "idem", "", "idempotency `key`, set to retry safely")
+}
+
+func runSend(c *cli, args []string) error {
+	fs := c.flags("send")
+	params := coinbase.TransactionParams{}
+	fs.StringVar(&params.To, "to", "", "bitcoin `address` or email of the recipient")
+	amount, currency := amountFlags(fs, "BTC")
+	fee := fs.String("fee", "", "miner fee in BTC")
+	transactionFlags(fs, &params)
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+	if params.To == "" {
+		return usagef("-to is required")
+	}
+	var err error
+	if params.Amount, err = parseAmount(*amount, *currency); err != nil {
+		return err
+	}
+	if *fee != "" {
+		if params.UserFee, err = coinbase.ParseMoney(*fee, "BTC"); err != nil {
+			return usagef("invalid -fee: %v", err)
+		}
+	}
+	client, err := c.client()
+	if err != nil {
+		return err
+	}
+	if c.dryRun {
+		return dryRunTransaction(client, "send_money", &params)
+	}
+	confirmation, err := client.SendMoney(&params)
+	if err != nil {
+		return err
+	}
+	return c.printTransactions(confirmation, confirmation.Transaction)
+}
+
+func runRequest(c *cli, args []string) error {
+	fs := c.flags("request")
+	params := coinbase.TransactionParams{}
+	fs.StringVar(&params.From, "from", "", "`email` of the user requested to pay")
+	amount, currency := amountFlags(fs, "BTC")
+	transactionFlags(fs, &params)
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+	if params.From == "" {
+		return usagef("-from is required")
+	}
+	var err error
+	if params.Amount, err = parseAmount(*amount, *currency); err != nil {
+		return err
+	}
+	client, err := c.client()
+	if err != nil {
+		return err
+	}
+	if c.dryRun {
+		return dryRunTransaction(client, "request_money", &params)
+	}
+	confirmation, err := client.RequestMoney(&params)
+	if err != nil {
+		return err
+	}
+	return c.printTransactions(confirmation, confirmation.Transaction)
+}
+
+func runTx(c *cli, args []string) error {
+	if len(args) == 0 {
+		return usagef("missing tx subcommand")
+	}
+	switch args[0] {
+	case "list":
+		fs := c.flags("tx list")
+		page := fs.Int("page", 1, "page to list")
+		if err := c.parse(fs, args[1:]); err != nil {
+			return err
+		}
+		client, err := c.client()
+		if err != nil {
+			return err
+		}
+		transactions, err := client.GetTransactions(*page)
+		if err != nil {
+			return err
+		}
+		return c.printTransactions(transactions, transactions.Transactions...)
+	case "show":
+		fs := c.flags("tx show")
+		if err := c.parse(fs, args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return usagef("tx show expects the ID of a transaction")
+		}
+		client, err := c.client()
+		if err != nil {
+			return err
+		}
+		transaction, err := client.GetTransaction(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		return c.printTransactions(transaction, *transaction)
+	}
+	return usagef("unknown tx subcommand %q", args[0])
+}
+
+func (c *cli) printTransactions(value interface{}, transactions ...coinbase.Transaction) error {
+	t := &table{header: []string{"id", "created_at", "amount", "status", "request", "sender", "recipient", "notes"}, value: value}
+	for _, tx := range transactions {
+		recipient := tx.Recipient.Email
+		if recipient == "" {
+			recipient = tx.RecipientAddress
+		}
+		t.add(tx.Id, formatTime(tx.CreatedAt), formatMoney(tx.Amount), tx.Status, strconv.FormatBool(tx.Request), tx.Sender.Email, recipient, tx.Notes)
+	}
+	return c.print(t)
+}
+
+func runTransfers(c *cli, args []string) error {
+	fs := c.flags("transfers")
+	page := fs.Int("page", 1, "page to list")
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+	client, err := c.client()
+	if err != nil {
+		return err
+	}
+	transfers, err := client.GetTransfers(*page)
+	if err != nil {
+		return err
+	}
+	return c.printTransfers(transfers, transfers.Transfers...)
+}
+
+func (c *cli) printTransfers(value interface{}, transfers ...coinbase.Transfer) error {
+	t := &table{header: []string{"id", "type", "created_at", "status", "btc", "subtotal", "fees", "total", "payout_date"}, value: value}
+	for _, tr := range transfers {
+		t.add(tr.Id, tr.Type, formatTime(tr.CreatedAt), tr.Status, formatMoney(tr.Btc), formatMoney(tr.Subtotal), formatFees(tr.Fees), formatMoney(tr.Total), formatTime(tr.PayoutDate))
+	}
+	return c.print(t)
+}
+
+// formatFees formats the sum of the coinbase and bank fees when they are in
+// the same currency
+func formatFees(fees coinbase.Fees) string {
+	if fees.Bank.Currency == "" {
+		return formatMoney(fees.Coinbase)
+	} else if fees.Coinbase.Currency == "" {
+		return formatMoney(fees.Bank)
+	}
+	total, err := fees.Coinbase.Add(fees.Bank)
+	if err != nil {
+		return fees.Coinbase.String() + " + " + fees.Bank.String()
+	}
+	return total.String()
+}
+
+func runOrders(c *cli, args []string) error {
+	fs := c.flags("orders")
+	page := fs.Int("page", 1, "page to list")
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+	client, err := c.client()
+	if err != nil {
+		return err
+	}
+	orders, err := client.GetOrders(*page)
+	if err != nil {
+		return err
+	}
+	t := &table{header: []string{"id", "created_at", "status", "total_btc", "total_native", "button", "custom", "receive_address"}, value: orders}
+	for _, o := range orders.Orders {
+		t.add(o.Id, formatTime(o.CreatedAt), o.Status, formatMoney(o.TotalBtc), formatMoney(o.TotalNative), o.Button.Name, o.Custom, o.ReceiveAddress)
+	}
+	return c.print(t)
+}
+
+func runPrices(c *cli, args []string) error {
+	if len(args) == 0 || (args[0] != "buy" && args[0] != "sell") {
+		return usagef("prices expects buy or sell")
+	}
+	fs := c.flags("prices " + args[0])
+	qty := fs.Int("qty", 1, "number of bitcoins to price")
+	if err := c.parse(fs, args[1:]); err != nil {
+		return err
+	}
+	client, err := c.client()
+	if err != nil {
+		return err
+	}
+	var prices *coinbase.Prices
+	if args[0] == "buy" {
+		prices, err = client.GetBuyPrice(*qty)
+	} else {
+		prices, err = client.GetSellPrice(*qty)
+	}
+	if err != nil {
+		return err
+	}
+	t := &table{header: []string{"qty", "subtotal", "coinbase_fee", "bank_fee", "total"}, value: prices}
+	coinbaseFee, bankFee := "", ""
+	for _, fees := range prices.Fees {
+		if fees.Coinbase.Currency != "" {
+			coinbaseFee = formatMoney(fees.Coinbase)
+		}
+		if fees.Bank.Currency != "" {
+			bankFee = formatMoney(fees.Bank)
+		}
+	}
+	t.add(strconv.Itoa(*qty), formatMoney(prices.Subtotal), coinbaseFee, bankFee, formatMoney(prices.Total))
+	return c.print(t)
+}
+
+func runRates(c *cli, args []string) error {
+	fs := c.flags("rates")
+	from := fs.String("from", "", "only print the rate from this `currency`")
+	to := fs.String("to", "", "only print the rate to this `currency`")
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+	client, err := c.client()
+	if err != nil {
+		return err
+	}
+	rates, err := client.GetExchangeRates()
+	if err != nil {
+		return err
+	}
+	selected := map[string]string{}
+	for key, rate := range rates {
+		pair := strings.SplitN(key, "_to_", 2)
+		if len(pair) != 2 {
+			continue
+		}
+		if (*from == "" || strings.EqualFold(pair[0], *from)) && (*to == "" || strings.EqualFold(pair[1], *to)) {
+			selected[key] = rate
+		}
+	}
+	keys := make([]string, 0, len(selected))
+	for key := range selected {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	t := &table{header: []string{"from", "to", "rate"}, value: selected}
+	for _, key := range keys {
+		pair := strings.SplitN(key, "_to_", 2)
+		t.add(strings.ToUpper(pair[0]), strings.ToUpper(pair[1]), selected[key])
+	}
+	return c.print(t)
+}
+
+func runBuy(c *cli, args []string) error {
+	fs := c.flags("buy")
+	params := coinbase.BuyParams{}
+	amount, currency := amountFlags(fs, "BTC")
+	fs.StringVar(&params.PaymentMethodId, "payment-method", "", "`ID` of the payment method, the default one if empty")
+	fs.BoolVar(&params.AgreeBtcAmountVaries, "agree-btc-amount-varies", false, "buy even if the price changes before the buy completes")
+	quote := fs.Bool("quote", false, "only create a quote of the fees and total, without executing it")
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+	var err error
+	if params.Amount, err = parseAmount(*amount, *currency); err != nil {
+		return err
+	}
+	params.Commit = !*quote
+	client, err := c.client()
+	if err != nil {
+		return err
+	}
+	if c.dryRun {
+		return dryRunPost(client, "buys", &params, &params.Idem)
+	}
+	transfer, err := client.CreateBuy(&params)
+	if err != nil {
+		return err
+	}
+	return c.printTransfers(transfer, *transfer)
+}
+
+func runSell(c *cli, args []string) error {
+	fs := c.flags("sell")
+	params := coinbase.SellParams{}
+	amount, currency := amountFlags(fs, "BTC")
+	fs.StringVar(&params.PaymentMethodId, "payment-method", "", "`ID` of the payment method, the default one if empty")
+	quote := fs.Bool("quote", false, "only create a quote of the fees and total, without executing it")
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+	var err error
+	if params.Amount, err = parseAmount(*amount, *currency); err != nil {
+		return err
+	}
+	params.Commit = !*quote
+	client, err := c.client()
+	if err != nil {
+		return err
+	}
+	if c.dryRun {
+		return dryRunPost(client, "sells", &params, &params.Idem)
+	}
+	transfer, err := client.CreateSell(&params)
+	if err != nil {
+		return err
+	}
+	return c.printTransfers(transfer, *transfer)
+}
+
+func runButton(c *cli, args []string) error {
+	if len(args) == 0 || args[0] != "create" {
+		return usagef("button expects create")
+	}
+	fs := c.flags("button create")
+	button := coinbase.Button{}
+	fs.StringVar(&button.Name, "name", "", "name of the item")
+	price, currency := fs.String("price", "", "price of the item"), fs.String("currency", "USD", "`currency` of the price")
+	fs.StringVar(&button.Type, "type", "buy_now", "buy_now, donation or subscription")
+	fs.StringVar(&button.Description, "description", "", "description of the item")
+	fs.StringVar(&button.Custom, "custom", "", "custom value passed back in the callbacks, i.e an order ID")
+	fs.StringVar(&button.CallbackUrl, "callback-url", "", "`URL` notified of the payments")
+	fs.StringVar(&button.SuccessUrl, "success-url", "", "`URL` the customer is redirected to after paying")
+	fs.StringVar(&button.CancelUrl, "cancel-url", "", "`URL` the customer is redirected to after cancelling")
+	if err := c.parse(fs, args[1:]); err != nil {
+		return err
+	}
+	if button.Name == "" {
+		return usagef("-name is required")
+	}
+	amount, err := parseAmount(*price, *currency)
+	if err != nil {
+		return err
+	}
+	button.PriceString = amount.Amount()
+	button.PriceCurrencyIso = amount.Currency
+	client, err := c.client()
+	if err != nil {
+		return err
+	}
+	created, err := client.CreateButton(&button)
+	if err != nil {
+		return err
+	}
+	t := &table{header: []string{"code", "name", "price", "type", "url"}, value: created}
+	t.add(created.Code, created.Name, formatMoney(created.Price), created.Type, client.Environment().SiteUrl+"checkouts/"+created.Code)
+	return c.print(t)
+}
+
+// amountFlags registers the -amount and -currency flags on fs
+func amountFlags(fs *flag.FlagSet, currency string) (*string, *string) {
+	return fs.String("amount", "", "amount, i.e 0.01"), fs.String("currency", currency, "`currency` of the amount")
+}
+
+func parseAmount(amount string, currency string) (coinbase.Money, error) {
+	if amount == "" {
+		return coinbase.Money{}, usagef("-amount is required")
+	}
+	money, err := coinbase.ParseMoney(amount, strings.ToUpper(currency))
+	if err != nil {
+		return coinbase.Money{}, usagef("invalid -amount: %v", err)
+	}
+	return money, nil
+}
+
+// formatMoney formats m, or returns "" for amounts missing from the response
+func formatMoney(m coinbase.Money) string {
+	if m.Currency == "" {
+		return ""
+	}
+	return m.String()
+}
+
+func formatTime(t coinbase.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
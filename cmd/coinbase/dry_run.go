@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/fabioberger/coinbase-go"
+	"github.com/fabioberger/coinbase-go/address"
+)
+
+// errDryRun stops a command once its first request was printed
+var errDryRun = errors.New("dry run")
+
+// dryRunTransport prints the requests instead of sending them: the method and
+// URL followed by the body, exactly as it would have been sent. The
+// authentication headers are left out. Nothing reaches the network, read
+// requests included, so a dry run never depends on the state of the account
+type dryRunTransport struct {
+	out io.Writer
+}
+
+func dryRunClient(out io.Writer) *http.Client {
+	return &http.Client{Transport: dryRunTransport{out: out}}
+}
+
+func (t dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+	fmt.Fprintf(t.out, "%s %s\n", req.Method, req.URL)
+	if len(body) > 0 {
+		fmt.Fprintf(t.out, "\n%s\n", body)
+	}
+	return nil, errDryRun
+}
+
+// dryRunPost prints the write request carrying an idempotency key that a
+// command would send. The client would first look up a previous request with
+// the same key, so the request is posted directly instead. A key is generated
+// into idem if empty, as the client does
+func dryRunPost(client coinbase.Client, path string, params interface{}, idem *string) error {
+	if *idem == "" {
+		*idem = coinbase.NewIdempotencyKey()
+	}
+	return client.Post(path, params, nil)
+}
+
+// dryRunTransaction prints the send_money or request_money request of params
+func dryRunTransaction(client coinbase.Client, kind string, params *coinbase.TransactionParams) error {
+	if params.To != "" {
+		if _, err := address.ParseRecipient(params.To); err != nil {
+			return fmt.Errorf("%w: %v", coinbase.ErrInvalidRecipient, err)
+		}
+	}
+	return dryRunPost(client, "transactions/"+kind, map[string]interface{}{"transaction": params}, &params.Idem)
+}
@@ -0,0 +1,196 @@
+// Command coinbase makes coinbase API requests from the command line, i.e to
+// send money or refund a customer by hand without writing Go.
+//
+//	coinbase [flags] <command> [command flags] [arguments]
+//
+// Credentials are read from the COINBASE_KEY and COINBASE_SECRET environment
+// variables, or else from a profile of the profiles file (see profiles.go).
+// Results are printed as a table, JSON or CSV (-output). With -dry-run the
+// first request of a command is printed instead of being sent to coinbase.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fabioberger/coinbase-go"
+)
+
+// command is a subcommand of the tool. run receives the arguments following
+// the name of the command
+type command struct {
+	usage string
+	run   func(cli *cli, args []string) error
+}
+
+var commands = map[string]command{
+	"balance":   {"balance", runBalance},
+	"address":   {"address new [-label L] [-callback-url URL] | address list [-page N] [-limit N] [-query Q]", runAddress},
+	"send":      {"send -to ADDRESS|EMAIL -amount N [-currency BTC] [-notes N] [-fee N] [-idem KEY]", runSend},
+	"request":   {"request -from EMAIL -amount N [-currency BTC] [-notes N]", runRequest},
+	"tx":        {"tx list [-page N] | tx show ID", runTx},
+	"transfers": {"transfers [-page N]", runTransfers},
+	"orders":    {"orders [-page N]", runOrders},
+	"prices":    {"prices buy|sell [-qty N]", runPrices},
+	"rates":     {"rates [-from BTC -to USD]", runRates},
+	"buy":       {"buy -amount N [-currency BTC] [-payment-method ID] [-quote]", runBuy},
+	"sell":      {"sell -amount N [-currency BTC] [-payment-method ID] [-quote]", runSell},
+	"button":    {"button create -name NAME -price N [-currency USD] [-description D] [-type buy_now] [-callback-url URL] [-custom C]", runButton},
+}
+
+// cli holds the global flags and the streams commands write to
+type cli struct {
+	profile      string
+	profilesPath string
+	account      string
+	baseUrl      string
+	sandbox      bool
+	output       string
+	dryRun       bool
+	stdout       io.Writer
+	stderr       io.Writer
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command line args and returns the exit status
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	c := &cli{
+		profile:      os.Getenv("COINBASE_PROFILE"),
+		profilesPath: os.Getenv("COINBASE_PROFILES"),
+		output:       "table",
+		stdout:       stdout,
+		stderr:       stderr,
+	}
+	fs := flag.NewFlagSet("coinbase", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { c.usage(fs) }
+	c.globalFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := c.checkOutput(); err != nil {
+		fmt.Fprintf(stderr, "coinbase: %v\n", err)
+		c.usage(fs)
+		return 2
+	}
+	if fs.NArg() == 0 {
+		c.usage(fs)
+		return 2
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "coinbase: unknown command %q\n", fs.Arg(0))
+		c.usage(fs)
+		return 2
+	}
+	if err := cmd.run(c, fs.Args()[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 2
+		}
+		if errors.Is(err, errDryRun) {
+			return 0
+		}
+		var usageErr usageError
+		if errors.As(err, &usageErr) {
+			fmt.Fprintf(stderr, "coinbase: %v\nusage: coinbase %s\n", err, cmd.usage)
+			return 2
+		}
+		fmt.Fprintf(stderr, "coinbase: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// globalFlags registers the flags shared by every command on fs. Their
+// defaults are the current values, so that flags given before the name of
+// the command are kept
+func (c *cli) globalFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.profile, "profile", c.profile, "`name` of the profile holding the credentials, $COINBASE_PROFILE or \"default\"")
+	fs.StringVar(&c.profilesPath, "profiles", c.profilesPath, "`path` of the profiles file, $COINBASE_PROFILES or ~/.coinbase/profiles.json")
+	fs.StringVar(&c.account, "account", c.account, "`ID` of the account to act on instead of the primary account")
+	fs.StringVar(&c.baseUrl, "base-url", c.baseUrl, "`URL` of the API, i.e to go through a proxy")
+	fs.BoolVar(&c.sandbox, "sandbox", c.sandbox, "use the coinbase sandbox")
+	fs.StringVar(&c.output, "output", c.output, "output `format`: table, json or csv")
+	fs.BoolVar(&c.dryRun, "dry-run", c.dryRun, "print the request of the command instead of sending it")
+}
+
+func (c *cli) usage(fs *flag.FlagSet) {
+	fmt.Fprintf(c.stderr, "usage: coinbase [flags] <command> [command flags] [arguments]\n\ncommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, usage := range strings.Split(commands[name].usage, " | ") {
+			fmt.Fprintf(c.stderr, "  %s\n", usage)
+		}
+	}
+	fmt.Fprintf(c.stderr, "\nflags:\n")
+	fs.PrintDefaults()
+}
+
+// flags returns the flag set of the command name. The global flags are
+// registered again so that they can follow the name of the command
+func (c *cli) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("coinbase "+name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	c.globalFlags(fs)
+	return fs
+}
+
+// parse parses the flags of a command. The output format is checked before
+// the command sends any request
+func (c *cli) parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return c.checkOutput()
+}
+
+// client instantiates the API key client described by the credentials and the
+// global flags
+func (c *cli) client() (coinbase.Client, error) {
+	creds, err := loadCredentials(c.profilesPath, c.profile)
+	if err != nil {
+		return coinbase.Client{}, err
+	}
+	opts := []coinbase.ClientOption{}
+	if c.sandbox || creds.Environment == "sandbox" {
+		opts = append(opts, coinbase.WithEnvironment(coinbase.Sandbox))
+	}
+	if c.baseUrl != "" {
+		opts = append(opts, coinbase.WithBaseURL(c.baseUrl))
+	} else if creds.BaseUrl != "" {
+		opts = append(opts, coinbase.WithBaseURL(creds.BaseUrl))
+	}
+	if c.dryRun {
+		opts = append(opts, coinbase.WithHTTPClient(dryRunClient(c.stdout)), coinbase.WithRetries(coinbase.NoRetries))
+	}
+	client := coinbase.ApiKeyClient(creds.Key, creds.Secret, opts...)
+	if c.account != "" {
+		client = client.Account(c.account)
+	}
+	return client, nil
+}
+
+// usageError reports invalid command line arguments
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
+
+func usagef(format string, args ...interface{}) error {
+	return usageError{fmt.Sprintf(format, args...)}
+}
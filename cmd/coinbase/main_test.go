@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fabioberger/coinbase-go/coinbasetest"
+)
+
+// runSimulated runs the command line args against a fresh simulator holding 2
+// BTC and returns the exit status and output
+func runSimulated(t *testing.T, args ...string) (int, string, string) {
+	s := coinbasetest.NewSimulator()
+	t.Cleanup(s.Close)
+	if err := s.SetBalance("2"); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("COINBASE_KEY", s.Key)
+	t.Setenv("COINBASE_SECRET", s.Secret)
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	status := run(append([]string{"-base-url", s.BaseURL()}, args...), stdout, stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func expect(t *testing.T, prefix string, expected string, got string) {
+	if expected != got {
+		t.Errorf("%s Expected '%s' but got '%s'", prefix, expected, got)
+	}
+}
+
+func TestCommandBalanceOutputs(t *testing.T) {
+	status, stdout, stderr := runSimulated(t, "balance")
+	if status != 0 {
+		t.Fatalf("Balance Expected status 0 but got %d: %s", status, stderr)
+	}
+	expect(t, "BalanceTable", "AMOUNT      CURRENCY\n2.00000000  BTC\n", stdout)
+
+	_, stdout, _ = runSimulated(t, "balance", "-output", "csv")
+	expect(t, "BalanceCSV", "amount,currency\n2.00000000,BTC\n", stdout)
+
+	_, stdout, _ = runSimulated(t, "-output", "json", "balance")
+	expect(t, "BalanceJSON", "{\n  \"amount\": \"2.00000000\",\n  \"currency\": \"BTC\"\n}\n", stdout)
+}
+
+func TestCommandSend(t *testing.T) {
+	status, stdout, stderr := runSimulated(t, "send", "-to", "user1@example.com", "-amount", "0.5", "-notes", "Payout", "-output", "csv")
+	if status != 0 {
+		t.Fatalf("Send Expected status 0 but got %d: %s", status, stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Send Expected a header and a row but got '%s'", stdout)
+	}
+	fields := strings.Split(lines[1], ",")
+	expect(t, "Send", "-0.50000000 BTC", fields[2])
+	expect(t, "Send", "user1@example.com", fields[6])
+	expect(t, "Send", "Payout", fields[7])
+
+	status, _, stderr = runSimulated(t, "send", "-amount", "0.5")
+	if status != 2 || !strings.Contains(stderr, "-to is required") {
+		t.Errorf("Send Expected a usage error but got %d '%s'", status, stderr)
+	}
+}
+
+func TestCommandUnknownOutput(t *testing.T) {
+	s := coinbasetest.NewSimulator()
+	t.Cleanup(s.Close)
+	if err := s.SetBalance("2"); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("COINBASE_KEY", s.Key)
+	t.Setenv("COINBASE_SECRET", s.Secret)
+	for _, args := range [][]string{
+		{"send", "-to", "user1@example.com", "-amount", "0.5", "-output", "yaml"},
+		{"-output", "yaml", "send", "-to", "user1@example.com", "-amount", "0.5"},
+	} {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		status := run(append([]string{"-base-url", s.BaseURL()}, args...), stdout, stderr)
+		if status != 2 || !strings.Contains(stderr.String(), `unknown output format "yaml"`) {
+			t.Errorf("UnknownOutput Expected a usage error but got %d '%s'", status, stderr)
+		}
+	}
+	if requests := s.Requests(); len(requests) != 0 {
+		t.Errorf("UnknownOutput Expected no request but got %d", len(requests))
+	}
+}
+
+func TestCommandDryRun(t *testing.T) {
+	status, stdout, stderr := runSimulated(t, "-dry-run", "send", "-to", "user1@example.com", "-amount", "10", "-currency", "usd", "-idem", "abc")
+	if status != 0 {
+		t.Fatalf("DryRun Expected status 0 but got %d: %s", status, stderr)
+	}
+	lines := strings.Split(stdout, "\n")
+	if !strings.HasPrefix(lines[0], "POST ") || !strings.HasSuffix(lines[0], "/api/v1/transactions/send_money") {
+		t.Errorf("DryRun Expected the send_money request but got '%s'", lines[0])
+	}
+	expect(t, "DryRun", `{"transaction":{"to":"user1@example.com","idem":"abc","amount_string":"10.00","amount_currency_iso":"USD"}}`, lines[2])
+
+	// Neither the lookup of the idempotency key nor the buy reach the API
+	s := coinbasetest.NewSimulator()
+	t.Cleanup(s.Close)
+	t.Setenv("COINBASE_KEY", s.Key)
+	t.Setenv("COINBASE_SECRET", s.Secret)
+	for _, args := range [][]string{
+		{"send", "-to", "user1@example.com", "-amount", "1", "-idem", "abc"},
+		{"buy", "-amount", "1"},
+		{"balance"},
+	} {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		if status := run(append([]string{"-base-url", s.BaseURL(), "-dry-run"}, args...), stdout, stderr); status != 0 {
+			t.Errorf("DryRun Expected status 0 for %s but got %d: %s", args[0], status, stderr)
+		}
+	}
+	if requests := s.Requests(); len(requests) != 0 {
+		t.Errorf("DryRun Expected no request but got %d", len(requests))
+	}
+}
+
+func TestCommandProfiles(t *testing.T) {
+	s := coinbasetest.NewSimulator()
+	t.Cleanup(s.Close)
+	t.Setenv("COINBASE_KEY", "")
+	path := filepath.Join(t.TempDir(), "profiles.json")
+	profiles := `{"ops": {"key": "` + s.Key + `", "secret": "` + s.Secret + `", "base_url": "` + s.BaseURL() + `"}}`
+	if err := os.WriteFile(path, []byte(profiles), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	if status := run([]string{"-profiles", path, "-profile", "ops", "balance"}, stdout, stderr); status != 1 {
+		t.Errorf("Profiles Expected a readable profiles file to be rejected but got %d", status)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	stderr.Reset()
+	if status := run([]string{"-profiles", path, "balance", "-profile", "ops", "-output", "csv"}, stdout, stderr); status != 0 {
+		t.Fatalf("Profiles Expected status 0 but got %d: %s", status, stderr)
+	}
+	expect(t, "Profiles", "amount,currency\n0.00000000,BTC\n", stdout.String())
+
+	stderr.Reset()
+	if status := run([]string{"-profiles", path, "-profile", "missing", "balance"}, stdout, stderr); status != 1 || !strings.Contains(stderr.String(), `No profile "missing"`) {
+		t.Errorf("Profiles Expected a missing profile error but got %d '%s'", status, stderr)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// table is the result of a command. value is printed by the json output, the
+// header and rows by the table and csv outputs
+type table struct {
+	header []string
+	rows   [][]string
+	value  interface{}
+}
+
+func (t *table) add(row ...string) {
+	t.rows = append(t.rows, row)
+}
+
+// print writes t to the standard output in the format selected with -output
+func (c *cli) print(t *table) error {
+	switch c.output {
+	case "json":
+		return writeJSON(c.stdout, t.value)
+	case "csv":
+		return writeCSV(c.stdout, t)
+	case "table", "":
+		return writeTable(c.stdout, t)
+	}
+	return c.checkOutput()
+}
+
+// checkOutput returns a usage error if -output names an unknown format
+func (c *cli) checkOutput() error {
+	switch c.output {
+	case "table", "json", "csv", "":
+		return nil
+	}
+	return usagef("unknown output format %q", c.output)
+}
+
+func writeJSON(w io.Writer, value interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+func writeCSV(w io.Writer, t *table) error {
+	writer := csv.NewWriter(w)
+	writer.Write(t.header)
+	writer.WriteAll(t.rows)
+	return writer.Error()
+}
+
+func writeTable(w io.Writer, t *table) error {
+	writer := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, strings.ToUpper(strings.Join(t.header, "\t")))
+	for _, row := range t.rows {
+		fmt.Fprintln(writer, strings.Join(row, "\t"))
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// credentials are the API key a command authenticates with and the servers
+// it talks to. The profiles file maps profile names to credentials, i.e
+//
+//	{
+//		"default": {"key": "...", "secret": "..."},
+//		"staging": {"key": "...", "secret": "...", "environment": "sandbox"}
+//	}
+//
+// The file holds secrets and must not be readable by other users
+type credentials struct {
+	Key         string `json:"key"`
+	Secret      string `json:"secret"`
+	Environment string `json:"environment,omitempty"` // "production" (default) or "sandbox"
+	BaseUrl     string `json:"base_url,omitempty"`
+}
+
+const defaultProfile = "default"
+
+// defaultProfilesPath returns ~/.coinbase/profiles.json
+func defaultProfilesPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".coinbase", "profiles.json"), nil
+}
+
+// loadCredentials returns the credentials set with the COINBASE_KEY and
+// COINBASE_SECRET environment variables, or else those of the profile of the
+// profiles file at path
+func loadCredentials(path string, profile string) (*credentials, error) {
+	if key := os.Getenv("COINBASE_KEY"); key != "" {
+		return &credentials{
+			Key:         key,
+			Secret:      os.Getenv("COINBASE_SECRET"),
+			Environment: os.Getenv("COINBASE_ENVIRONMENT"),
+		}, nil
+	}
+	if profile == "" {
+		profile = defaultProfile
+	}
+	if path == "" {
+		var err error
+		if path, err = defaultProfilesPath(); err != nil {
+			return nil, err
+		}
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("No credentials: set COINBASE_KEY and COINBASE_SECRET or create %s", path)
+	} else if err != nil {
+		return nil, err
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("%s is accessible by other users, restrict it with chmod 600", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	profiles := map[string]*credentials{}
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("Invalid profiles file %s: %v", path, err)
+	}
+	creds, ok := profiles[profile]
+	if !ok || creds == nil {
+		return nil, fmt.Errorf("No profile %q in %s", profile, path)
+	}
+	if creds.Key == "" || creds.Secret == "" {
+		return nil, fmt.Errorf("The profile %q has no key or secret", profile)
+	}
+	return creds, nil
+}
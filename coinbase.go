@@ -2,77 +2,237 @@
 package coinbase
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
-	"strconv"
+	"fmt"
+	"math/big"
+	"net/http"
 	"strings"
+
+	"github.com/fabioberger/coinbase-go/address"
 )
 
 // Client is the struct from which all API requests are made
 type Client struct {
-	rpc rpc
+	rpc       rpc
+	ctx       context.Context
+	env       Environment
+	accountId string // Account requests act on, the primary account if empty
 }
 
 // ApiKeyClient instantiates the client with ApiKey Authentication
-func ApiKeyClient(key string, secret string) Client {
+func ApiKeyClient(key string, secret string, opts ...ClientOption) Client {
+	cfg := newClientConfig(opts)
 	c := Client{
 		rpc: rpc{
-			auth: apiKeyAuth(key, secret),
-			mock: false,
+			auth:      apiKeyAuth(key, secret, cfg),
+			retry:     cfg.retry,
+			userAgent: cfg.userAgent,
+			err:       cfg.err,
 		},
+		env: cfg.env,
 	}
 	return c
 }
 
 // OAuthClient instantiates the client with OAuth Authentication
-func OAuthClient(tokens *oauthTokens) Client {
+func OAuthClient(tokens *Tokens, opts ...ClientOption) Client {
+	cfg := newClientConfig(opts)
 	c := Client{
 		rpc: rpc{
-			auth: clientOAuth(tokens),
-			mock: false,
+			auth:      clientOAuth(tokens, cfg),
+			retry:     cfg.retry,
+			userAgent: cfg.userAgent,
+			err:       cfg.err,
 		},
+		env: cfg.env,
 	}
 	return c
 }
 
-// ApiKeyClientTest instantiates Testing ApiKeyClient. All client methods execute
-// normally except responses are returned from a test_data/ file instead of the coinbase API
-func apiKeyClientTest(key string, secret string) Client {
-	c := ApiKeyClient(key, secret)
-	c.rpc.mock = true
+// RefreshingOAuthClient instantiates the client with OAuth Authentication and
+// refreshes the tokens through service shortly before they expire, or when
+// coinbase rejects them. onRefresh, if not nil, is called with the new tokens
+// after every refresh so that they can be persisted. The client is safe for
+// concurrent use
+func RefreshingOAuthClient(tokens *Tokens, service *OAuth, onRefresh func(*Tokens), opts ...ClientOption) Client {
+	c := OAuthClient(tokens, opts...)
+	auth := c.rpc.auth.(*clientOAuthAuthentication)
+	auth.service = service
+	auth.onRefresh = onRefresh
+	return c
+}
+
+// WithContext returns a copy of the client whose requests are bound to ctx.
+// Canceling ctx or exceeding its deadline aborts any request in flight, i.e
+// c.WithContext(req.Context()).GetBalance()
+func (c Client) WithContext(ctx context.Context) Client {
+	if ctx == nil {
+		panic("nil context")
+	}
+	c.ctx = ctx
+	return c
+}
+
+// Context returns the context requests made by the client are bound to. It
+// defaults to context.Background()
+func (c Client) Context() context.Context {
+	if c.ctx != nil {
+		return c.ctx
+	}
+	return context.Background()
+}
+
+// Account returns a copy of the client whose requests act on the account
+// referenced by id instead of the primary account, i.e
+// c.Account(id).SendMoney(params). Every request of the copy carries the
+// account_id param, unless its params set one already
+func (c Client) Account(id string) Client {
+	c.accountId = id
 	return c
 }
 
+// AccountId returns the ID of the account the client acts on, or "" for the
+// primary account
+func (c Client) AccountId() string {
+	return c.accountId
+}
+
 // Get sends a GET request and marshals response data into holder
 func (c Client) Get(path string, params interface{}, holder interface{}) error {
-	return c.rpc.Request("GET", path, params, &holder)
+	_, err := c.request("GET", path, params, holder)
+	return err
 }
 
 // Post sends a POST request and marshals response data into holder
 func (c Client) Post(path string, params interface{}, holder interface{}) error {
-	return c.rpc.Request("POST", path, params, &holder)
+	_, err := c.request("POST", path, params, holder)
+	return err
 }
 
 // Delete sends a DELETE request and marshals response data into holder
 func (c Client) Delete(path string, params interface{}, holder interface{}) error {
-	return c.rpc.Request("DELETE", path, params, &holder)
+	_, err := c.request("DELETE", path, params, holder)
+	return err
 }
 
 // Put sends a PUT request and marshals response data into holder
 func (c Client) Put(path string, params interface{}, holder interface{}) error {
-	return c.rpc.Request("PUT", path, params, &holder)
+	_, err := c.request("PUT", path, params, holder)
+	return err
 }
 
-// GetBalance returns current balance in BTC
-func (c Client) GetBalance() (float64, error) {
-	balance := map[string]string{}
-	if err := c.Get("account/balance", nil, &balance); err != nil {
-		return 0.0, err
+// request sends a request scoped to the account of the client and returns the
+// description of the request that checkApiErrors needs
+func (c Client) request(method string, path string, params interface{}, holder interface{}) (requestInfo, error) {
+	return c.rpc.do(c.Context(), method, path, c.scoped(params), holder)
+}
+
+// scoped adds the account of an account-scoped client to params
+func (c Client) scoped(params interface{}) interface{} {
+	if c.accountId == "" {
+		return params
 	}
-	balanceFloat, err := strconv.ParseFloat(balance["amount"], 64)
+	return accountParams{params: params, accountId: c.accountId}
+}
+
+// accountParams are the params of a request of an account-scoped client. They
+// are marshaled as params with an additional account_id field
+type accountParams struct {
+	params    interface{}
+	accountId string
+}
+
+func (p accountParams) MarshalJSON() ([]byte, error) {
+	fields := map[string]json.RawMessage{}
+	data, err := json.Marshal(p.params)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	return balanceFloat, nil
+	if !bytes.Equal(data, []byte("null")) {
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, fmt.Errorf("The params of an account-scoped request must be a JSON object: %v", err)
+		}
+	}
+	if _, ok := fields["account_id"]; !ok {
+		fields["account_id"], _ = json.Marshal(p.accountId)
+	}
+	return json.Marshal(fields)
+}
+
+// idempotencyKey keeps requests with an Idem key retryable once scoped
+func (p accountParams) idempotencyKey() string {
+	if ip, ok := p.params.(idempotentParams); ok {
+		return ip.idempotencyKey()
+	}
+	return ""
+}
+
+// GetAccounts returns the accounts of the user, i.e a wallet per business unit
+func (c Client) GetAccounts(params *AccountsParams) (*Accounts, error) {
+	holder := Accounts{}
+	if err := c.Get("accounts", params, &holder); err != nil {
+		return nil, err
+	}
+	return &holder, nil
+}
+
+// CreateAccount creates a new account with the given name
+func (c Client) CreateAccount(name string) (*Account, error) {
+	return c.accountRequest("POST", "accounts", name, "CreateAccount")
+}
+
+// RenameAccount renames the account referenced by id
+func (c Client) RenameAccount(id string, name string) (*Account, error) {
+	return c.accountRequest("PUT", "accounts/"+id, name, "RenameAccount")
+}
+
+func (c Client) accountRequest(method string, path string, name string, caller string) (*Account, error) {
+	params := map[string]interface{}{
+		"account": map[string]string{"name": name},
+	}
+	holder := accountHolder{}
+	source, err := c.request(method, path, params, &holder)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkApiErrors(holder.response, source, caller); err != nil {
+		return nil, err
+	}
+	return &holder.Account, nil
+}
+
+// DeleteAccount deletes the account referenced by id. The primary account
+// cannot be deleted
+func (c Client) DeleteAccount(id string) error {
+	holder := response{}
+	source, err := c.request("DELETE", "accounts/"+id, nil, &holder)
+	if err != nil {
+		return err
+	}
+	return checkApiErrors(holder, source, "DeleteAccount")
+}
+
+// SetPrimaryAccount makes the account referenced by id the primary account,
+// the one requests act on by default
+func (c Client) SetPrimaryAccount(id string) error {
+	holder := response{}
+	source, err := c.request("POST", "accounts/"+id+"/primary", nil, &holder)
+	if err != nil {
+		return err
+	}
+	return checkApiErrors(holder, source, "SetPrimaryAccount")
+}
+
+// GetBalance returns current balance in BTC of the account the client acts on
+func (c Client) GetBalance() (Money, error) {
+	balance := Money{}
+	if err := c.Get("account/balance", nil, &balance); err != nil {
+		return Money{}, err
+	}
+	return balance, nil
 }
 
 // GetReceiveAddress returns clients current bitcoin receive address
@@ -85,13 +245,13 @@ func (c Client) GetReceiveAddress() (string, error) {
 }
 
 // GetAllAddresses returns bitcoin addresses associated with client account
-func (c Client) GetAllAddresses(params *AddressesParams) (*addresses, error) {
+func (c Client) GetAllAddresses(params *AddressesParams) (*Addresses, error) {
 	holder := addressesHolder{}
 	if err := c.Get("addresses", params, &holder); err != nil {
 		return nil, err
 	}
-	addresses := addresses{
-		paginationStats: holder.paginationStats,
+	addresses := Addresses{
+		Page: holder.Page,
 	}
 	// Remove one layer of nesting
 	for _, addr := range holder.Addresses {
@@ -109,36 +269,122 @@ func (c Client) GenerateReceiveAddress(params *AddressParams) (string, error) {
 	return holder["address"].(string), nil
 }
 
-// SendMoney to either a bitcoin or email address
-func (c Client) SendMoney(params *TransactionParams) (*transactionConfirmation, error) {
-	return c.transactionRequest("POST", "send_money", params)
+// GenerateReceiveURI generates a new bitcoin receive address and returns it as
+// a BIP21 payment URI, i.e to display as a QR code. The URI requests amount,
+// which must be in BTC or zero to let the payer choose, and carries
+// params.Label and message
+func (c Client) GenerateReceiveURI(params *AddressParams, amount Money, message string) (string, error) {
+	if amount.Currency != "" && amount.Currency != "BTC" {
+		return "", fmt.Errorf("Payment URIs request amounts in BTC, not %s", amount.Currency)
+	}
+	addr, err := c.GenerateReceiveAddress(params)
+	if err != nil {
+		return "", err
+	}
+	if _, err := address.Parse(addr); err != nil {
+		return "", err
+	}
+	uri := &address.URI{Address: addr, Message: message}
+	if params != nil {
+		uri.Label = params.Label
+	}
+	if amount.Sign() > 0 {
+		uri.Amount = amount.Amount()
+	}
+	return uri.String(), nil
+}
+
+// ErrInvalidRecipient is returned by SendMoney when the recipient is neither
+// an email nor a valid bitcoin address, i.e because of a typo. Nothing is sent
+var ErrInvalidRecipient = errors.New("Invalid recipient")
+
+// SendMoney to either a bitcoin or email address. An idempotency key is
+// generated unless params.Idem is set (see NewIdempotencyKey)
+func (c Client) SendMoney(params *TransactionParams) (*TransactionConfirmation, error) {
+	if params != nil {
+		if err := validateRecipient(params.To, c.env); err != nil {
+			return nil, err
+		}
+	}
+	return c.createTransaction("send_money", params)
 }
 
-// RequestMoney from either a bitcoin or email address
-func (c Client) RequestMoney(params *TransactionParams) (*transactionConfirmation, error) {
-	return c.transactionRequest("POST", "request_money", params)
+// validateRecipient checks that to is an email or a bitcoin address of the
+// network of env, unless it is empty
+func validateRecipient(to string, env Environment) error {
+	if to == "" {
+		return nil
+	}
+	a, err := address.ParseRecipient(to)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRecipient, err)
+	}
+	if network, ok := env.network(); ok && a != nil {
+		if err := address.Validate(to, network); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRecipient, err)
+		}
+	}
+	return nil
 }
 
-func (c Client) transactionRequest(method string, kind string, params *TransactionParams) (*transactionConfirmation, error) {
-	finalParams := &struct {
-		Transaction *TransactionParams `json:"transaction"`
-	}{
+// RequestMoney from either a bitcoin or email address. An idempotency key is
+// generated unless params.Idem is set (see NewIdempotencyKey)
+func (c Client) RequestMoney(params *TransactionParams) (*TransactionConfirmation, error) {
+	return c.createTransaction("request_money", params)
+}
+
+// createTransaction sends or requests money with an idempotency key. params
+// is left untouched, the key is returned in the confirmation
+func (c Client) createTransaction(kind string, params *TransactionParams) (*TransactionConfirmation, error) {
+	finalParams := TransactionParams{}
+	if params != nil {
+		finalParams = *params
+	}
+	var confirmation *TransactionConfirmation
+	err := c.submitIdempotent(&finalParams.Idem, func(single Client) error {
+		var err error
+		confirmation, err = single.transactionRequest("POST", kind, &finalParams)
+		return err
+	}, func() error {
+		tx, err := c.FindTransactionByIdem(finalParams.Idem)
+		if err == nil {
+			confirmation = &TransactionConfirmation{Transaction: *tx}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	confirmation.Idem = finalParams.Idem
+	return confirmation, nil
+}
+
+// transactionRequestParams wraps TransactionParams as expected by the
+// transactions endpoints. Requests carrying an Idem key are safe to retry
+type transactionRequestParams struct {
+	Transaction *TransactionParams `json:"transaction"`
+}
+
+func (p *transactionRequestParams) idempotencyKey() string {
+	if p.Transaction == nil {
+		return ""
+	}
+	return p.Transaction.Idem
+}
+
+func (c Client) transactionRequest(method string, kind string, params *TransactionParams) (*TransactionConfirmation, error) {
+	finalParams := &transactionRequestParams{
 		Transaction: params,
 	}
 	holder := transactionHolder{}
-	var err error
-	if method == "POST" {
-		err = c.Post("transactions/"+kind, finalParams, &holder)
-	} else if method == "PUT" {
-		err = c.Put("transactions/"+kind, finalParams, &holder)
-	}
+	source, err := c.request(method, "transactions/"+kind, finalParams, &holder)
 	if err != nil {
 		return nil, err
 	}
-	if err := checkApiErrors(holder.response, kind); err != nil {
+	if err := checkApiErrors(holder.response, source, kind); err != nil {
 		return nil, err
 	}
-	confirmation := transactionConfirmation{
+	confirmation := TransactionConfirmation{
 		Transaction: holder.Transaction,
 		Transfer:    holder.Transfer,
 	}
@@ -147,30 +393,32 @@ func (c Client) transactionRequest(method string, kind string, params *Transacti
 
 // ResendRequest resends a transaction request referenced by id
 func (c Client) ResendRequest(id string) (bool, error) {
-	holder := map[string]interface{}{}
-	if err := c.Put("transactions/"+id+"/resend_request", nil, &holder); err != nil {
+	holder := response{}
+	source, err := c.request("PUT", "transactions/"+id+"/resend_request", nil, &holder)
+	if err != nil {
 		return false, err
 	}
-	if holder["success"].(bool) {
-		return true, nil
+	if err := checkApiErrors(holder, source, "ResendRequest"); err != nil {
+		return false, err
 	}
-	return false, nil
+	return holder.Success, nil
 }
 
 // CancelRequest cancels a transaction request referenced by id
 func (c Client) CancelRequest(id string) (bool, error) {
-	holder := map[string]interface{}{}
-	if err := c.Delete("transactions/"+id+"/cancel_request", nil, &holder); err != nil {
+	holder := response{}
+	source, err := c.request("DELETE", "transactions/"+id+"/cancel_request", nil, &holder)
+	if err != nil {
 		return false, err
 	}
-	if holder["success"].(bool) {
-		return true, nil
+	if err := checkApiErrors(holder, source, "CancelRequest"); err != nil {
+		return false, err
 	}
-	return false, nil
+	return holder.Success, nil
 }
 
 // CompleteRequest completes a money request referenced by id
-func (c Client) CompleteRequest(id string) (*transactionConfirmation, error) {
+func (c Client) CompleteRequest(id string) (*TransactionConfirmation, error) {
 	return c.transactionRequest("PUT", id+"/complete_request", nil)
 }
 
@@ -182,79 +430,232 @@ func (c Client) CreateButton(params *Button) (*Button, error) {
 		Button: params,
 	}
 	holder := buttonHolder{}
-	if err := c.Post("buttons", finalParams, &holder); err != nil {
+	source, err := c.request("POST", "buttons", finalParams, &holder)
+	if err != nil {
 		return nil, err
 	}
-	if err := checkApiErrors(holder.response, "CreateButton"); err != nil {
+	if err := checkApiErrors(holder.response, source, "CreateButton"); err != nil {
 		return nil, err
 	}
 	button := holder.Button
-	button.EmbedHtml = "<div class=\"coinbase-button\" data-code=\"" + button.Code + "\"></div><script src=\"https://coinbase.com/assets/button.js\" type=\"text/javascript\"></script>"
+	button.EmbedHtml = "<div class=\"coinbase-button\" data-code=\"" + button.Code + "\"></div><script src=\"" + c.env.SiteUrl + "assets/button.js\" type=\"text/javascript\"></script>"
 	return &button, nil
 }
 
 // CreateOrderFromButtonCode creates an order for a given button code
-func (c Client) CreateOrderFromButtonCode(buttonCode string) (*order, error) {
+func (c Client) CreateOrderFromButtonCode(buttonCode string) (*Order, error) {
 	holder := orderHolder{}
-	if err := c.Post("buttons/"+buttonCode+"/create_order", nil, &holder); err != nil {
+	source, err := c.request("POST", "buttons/"+buttonCode+"/create_order", nil, &holder)
+	if err != nil {
 		return nil, err
 	}
-	if err := checkApiErrors(holder.response, "CreateOrderFromButtonCode"); err != nil {
+	if err := checkApiErrors(holder.response, source, "CreateOrderFromButtonCode"); err != nil {
 		return nil, err
 	}
 	return &holder.Order, nil
 }
 
+// CreateOrder creates an order without a pre-made button
+func (c Client) CreateOrder(params *OrderParams) (*Order, error) {
+	finalParams := &struct {
+		Button *OrderParams `json:"button"`
+	}{
+		Button: params,
+	}
+	holder := orderHolder{}
+	source, err := c.request("POST", "orders", finalParams, &holder)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkApiErrors(holder.response, source, "CreateOrder"); err != nil {
+		return nil, err
+	}
+	return &holder.Order, nil
+}
+
+// RefundOrder refunds a completed or mispaid order, or one of its mispayments
+// when mispaymentId is not empty. refundIsoCode is "BTC" to refund the amount
+// of bitcoin paid, or the native currency of the order to refund its value.
+// The refund is sent to the refund address of the customer unless
+// externalRefundAddress is not empty. The returned order holds the refund
+// transaction
+func (c Client) RefundOrder(id string, refundIsoCode string, mispaymentId string, externalRefundAddress string) (*Order, error) {
+	finalParams := &struct {
+		Order RefundOrderParams `json:"order"`
+	}{
+		Order: RefundOrderParams{
+			RefundIsoCode:         refundIsoCode,
+			MispaymentId:          mispaymentId,
+			ExternalRefundAddress: externalRefundAddress,
+		},
+	}
+	holder := orderHolder{}
+	source, err := c.request("POST", "orders/"+id+"/refund", finalParams, &holder)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkApiErrors(holder.response, source, "RefundOrder"); err != nil {
+		return nil, err
+	}
+	return &holder.Order, nil
+}
+
+// GetButton gets the payment button referenced by code
+func (c Client) GetButton(code string) (*Button, error) {
+	holder := buttonHolder{}
+	source, err := c.request("GET", "buttons/"+code, nil, &holder)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkApiErrors(holder.response, source, "GetButton"); err != nil {
+		return nil, err
+	}
+	return &holder.Button, nil
+}
+
+// GetButtonOrders gets the orders created from the button referenced by code,
+// newest first. Coinbase does not filter orders by button, so every page of
+// orders is fetched
+func (c Client) GetButtonOrders(code string) ([]Order, error) {
+	orders := []Order{}
+	it := c.OrdersIter(0)
+	for it.Next() {
+		if o := it.Value(); o.Button.Id == code {
+			orders = append(orders, o)
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
 // CreateUser creates a new user given an email and password
-func (c Client) CreateUser(email string, password string) (*user, error) {
+func (c Client) CreateUser(email string, password string) (*User, error) {
 	params := map[string]interface{}{
 		"user[email]":    email,
 		"user[password]": password,
 	}
 	holder := userHolder{}
-	if err := c.Post("users", params, &holder); err != nil {
+	source, err := c.request("POST", "users", params, &holder)
+	if err != nil {
 		return nil, err
 	}
-	if err := checkApiErrors(holder.response, "CreateUser"); err != nil {
+	if err := checkApiErrors(holder.response, source, "CreateUser"); err != nil {
 		return nil, err
 	}
 	return &holder.User, nil
 }
 
 // Buy an amount of BTC and bypass rate limits by setting agreeBtcAmountVaries to true
-func (c Client) Buy(amount float64, agreeBtcAmountVaries bool) (*transfer, error) {
-	params := map[string]interface{}{
-		"qty": amount,
-		"agree_btc_amount_varies": agreeBtcAmountVaries,
+// The amount may also be given in the native currency of the account, i.e USD
+// The buy is executed immediately with the default payment method (see CreateBuy)
+func (c Client) Buy(amount Money, agreeBtcAmountVaries bool) (*Transfer, error) {
+	params := &BuyParams{
+		Amount:               amount,
+		AgreeBtcAmountVaries: agreeBtcAmountVaries,
+		Commit:               true,
 	}
-	holder := transferHolder{}
-	if err := c.Post("buys", params, &holder); err != nil {
+	return c.createTransfer("buys", params, &params.Idem, "Buy")
+}
+
+// Sell an amount of BTC
+// The amount may also be given in the native currency of the account, i.e USD
+// The sell is executed immediately with the default payment method (see CreateSell)
+func (c Client) Sell(amount Money) (*Transfer, error) {
+	params := &SellParams{
+		Amount: amount,
+		Commit: true,
+	}
+	return c.createTransfer("sells", params, &params.Idem, "Sell")
+}
+
+// CreateBuy creates a buy with the given payment method. Unless params.Commit
+// is set, it returns a quote whose fees and total can be reviewed before
+// executing it with CommitTransfer, i.e
+//
+//	quote, err := c.CreateBuy(&BuyParams{Amount: amount, PaymentMethodId: id})
+//	// Review quote.Fees and quote.Total
+//	transfer, err := c.CommitTransfer(quote.Id)
+func (c Client) CreateBuy(params *BuyParams) (*Transfer, error) {
+	finalParams := BuyParams{}
+	if params != nil {
+		finalParams = *params
+	}
+	return c.createTransfer("buys", &finalParams, &finalParams.Idem, "CreateBuy")
+}
+
+// CreateSell creates a sell with the given payment method. Unless
+// params.Commit is set, it returns a quote to execute with CommitTransfer
+func (c Client) CreateSell(params *SellParams) (*Transfer, error) {
+	finalParams := SellParams{}
+	if params != nil {
+		finalParams = *params
+	}
+	return c.createTransfer("sells", &finalParams, &finalParams.Idem, "CreateSell")
+}
+
+// CommitTransfer executes a buy or sell created without Commit, referenced by
+// the Id of the returned transfer. Quotes expire after a while, after which
+// an error is returned
+func (c Client) CommitTransfer(id string) (*Transfer, error) {
+	return c.transferRequest("transfers/"+id+"/commit", nil, "CommitTransfer")
+}
+
+// createTransfer creates a buy or sell with the idempotency key *idem of
+// params, which is returned in the transfer
+func (c Client) createTransfer(path string, params interface{}, idem *string, caller string) (*Transfer, error) {
+	var transfer *Transfer
+	err := c.submitIdempotent(idem, func(single Client) error {
+		var err error
+		transfer, err = single.transferRequest(path, params, caller)
+		return err
+	}, func() error {
+		var err error
+		transfer, err = c.FindTransferByIdem(*idem)
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
-	if err := checkApiErrors(holder.response, "Buy"); err != nil {
-		return nil, err
+	if transfer.Idem == "" {
+		transfer.Idem = *idem
 	}
-	return &holder.Transfer, nil
+	return transfer, nil
 }
 
-// Sell an amount of BTC
-func (c Client) Sell(amount float64) (*transfer, error) {
-	params := map[string]interface{}{
-		"qty": amount,
-	}
+func (c Client) transferRequest(path string, params interface{}, caller string) (*Transfer, error) {
 	holder := transferHolder{}
-	if err := c.Post("sells", params, &holder); err != nil {
+	source, err := c.request("POST", path, params, &holder)
+	if err != nil {
 		return nil, err
 	}
-	if err := checkApiErrors(holder.response, "Sell"); err != nil {
+	if err := checkApiErrors(holder.response, source, caller); err != nil {
 		return nil, err
 	}
 	return &holder.Transfer, nil
 }
 
+// GetPaymentMethods gets the payment methods buys and sells can use
+func (c Client) GetPaymentMethods() (*PaymentMethods, error) {
+	holder := paymentMethodsHolder{}
+	if err := c.Get("payment_methods", nil, &holder); err != nil {
+		return nil, err
+	}
+	methods := PaymentMethods{
+		DefaultBuy:  holder.DefaultBuy,
+		DefaultSell: holder.DefaultSell,
+	}
+	// Remove one layer of nesting
+	for _, m := range holder.PaymentMethods {
+		methods.PaymentMethods = append(methods.PaymentMethods, m.PaymentMethod)
+	}
+	return &methods, nil
+}
+
 // GetContacts gets a users contacts
-func (c Client) GetContacts(params *ContactsParams) (*contactsHolder, error) {
-	holder := contactsHolder{}
+func (c Client) GetContacts(params *ContactsParams) (*Contacts, error) {
+	holder := Contacts{}
 	if err := c.Get("contacts", params, &holder); err != nil {
 		return nil, err
 	}
@@ -267,14 +668,14 @@ func (c Client) GetContacts(params *ContactsParams) (*contactsHolder, error) {
 }
 
 // GetCurrencies gets all currency names and ISO's
-func (c Client) GetCurrencies() ([]currency, error) {
+func (c Client) GetCurrencies() ([]Currency, error) {
 	holder := [][]string{}
 	if err := c.Get("currencies", nil, &holder); err != nil {
 		return nil, err
 	}
-	finalData := []currency{}
+	finalData := []Currency{}
 	for _, curr := range holder {
-		class := currency{
+		class := Currency{
 			Name: curr[0],
 			Iso:  curr[1],
 		}
@@ -292,25 +693,26 @@ func (c Client) GetExchangeRates() (map[string]string, error) {
 	return holder, nil
 }
 
-// GetExchangeRate gets the exchange rate between two specified currencies
-func (c Client) GetExchangeRate(from string, to string) (float64, error) {
+// GetExchangeRate gets the exchange rate between two specified currencies as
+// an exact rational number. Use Money.Convert to apply it to an amount
+func (c Client) GetExchangeRate(from string, to string) (*big.Rat, error) {
 	exchanges, err := c.GetExchangeRates()
 	if err != nil {
-		return 0.0, err
+		return nil, err
 	}
-	key := from + "_to_" + to
+	key := strings.ToLower(from) + "_to_" + strings.ToLower(to)
 	if exchanges[key] == "" {
-		return 0.0, errors.New("The exchange rate does not exist for this currency pair")
+		return nil, errors.New("The exchange rate does not exist for this currency pair")
 	}
-	exchangeFloat, err := strconv.ParseFloat(exchanges[key], 64)
-	if err != nil {
-		return 0.0, err
+	rate, ok := new(big.Rat).SetString(exchanges[key])
+	if !ok {
+		return nil, fmt.Errorf("Invalid exchange rate %q for %s", exchanges[key], key)
 	}
-	return exchangeFloat, nil
+	return rate, nil
 }
 
 // GetTransactions gets transactions associated with an account
-func (c Client) GetTransactions(page int) (*transactions, error) {
+func (c Client) GetTransactions(page int) (*Transactions, error) {
 	params := map[string]int{
 		"page": page,
 	}
@@ -318,8 +720,8 @@ func (c Client) GetTransactions(page int) (*transactions, error) {
 	if err := c.Get("transactions", params, &holder); err != nil {
 		return nil, err
 	}
-	transactions := transactions{
-		paginationStats: holder.paginationStats,
+	transactions := Transactions{
+		Page: holder.Page,
 	}
 	// Remove one layer of nesting
 	for _, tx := range holder.Transactions {
@@ -329,7 +731,7 @@ func (c Client) GetTransactions(page int) (*transactions, error) {
 }
 
 // GetOrders gets orders associated with an account
-func (c Client) GetOrders(page int) (*orders, error) {
+func (c Client) GetOrders(page int) (*Orders, error) {
 	holder := ordersHolder{}
 	params := map[string]int{
 		"page": page,
@@ -337,8 +739,8 @@ func (c Client) GetOrders(page int) (*orders, error) {
 	if err := c.Get("orders", params, &holder); err != nil {
 		return nil, err
 	}
-	orders := orders{
-		paginationStats: holder.paginationStats,
+	orders := Orders{
+		Page: holder.Page,
 	}
 	// Remove one layer of nesting
 	for _, o := range holder.Orders {
@@ -348,7 +750,7 @@ func (c Client) GetOrders(page int) (*orders, error) {
 }
 
 // GetTransfers get transfers associated with an account
-func (c Client) GetTransfers(page int) (*transfers, error) {
+func (c Client) GetTransfers(page int) (*Transfers, error) {
 	params := map[string]int{
 		"page": page,
 	}
@@ -356,8 +758,8 @@ func (c Client) GetTransfers(page int) (*transfers, error) {
 	if err := c.Get("transfers", params, &holder); err != nil {
 		return nil, err
 	}
-	transfers := transfers{
-		paginationStats: holder.paginationStats,
+	transfers := Transfers{
+		Page: holder.Page,
 	}
 	// Remove one layer of nesting
 	for _, t := range holder.Transfers {
@@ -367,20 +769,20 @@ func (c Client) GetTransfers(page int) (*transfers, error) {
 }
 
 // GetBuyPrice gets the current BTC buy price
-func (c Client) GetBuyPrice(qty int) (*pricesHolder, error) {
+func (c Client) GetBuyPrice(qty int) (*Prices, error) {
 	return c.getPrice("buy", qty)
 }
 
 // GetSellPrice gets the current BTC sell price
-func (c Client) GetSellPrice(qty int) (*pricesHolder, error) {
+func (c Client) GetSellPrice(qty int) (*Prices, error) {
 	return c.getPrice("sell", qty)
 }
 
-func (c Client) getPrice(kind string, qty int) (*pricesHolder, error) {
+func (c Client) getPrice(kind string, qty int) (*Prices, error) {
 	params := map[string]int{
 		"qty": qty,
 	}
-	holder := pricesHolder{}
+	holder := Prices{}
 	if err := c.Get("prices/"+kind, params, &holder); err != nil {
 		return nil, err
 	}
@@ -388,31 +790,33 @@ func (c Client) getPrice(kind string, qty int) (*pricesHolder, error) {
 }
 
 // GetTransaction gets a particular transaction referenced by id
-func (c Client) GetTransaction(id string) (*transaction, error) {
+func (c Client) GetTransaction(id string) (*Transaction, error) {
 	holder := transactionHolder{}
-	if err := c.Get("transactions/"+id, nil, &holder); err != nil {
+	source, err := c.request("GET", "transactions/"+id, nil, &holder)
+	if err != nil {
 		return nil, err
 	}
-	if err := checkApiErrors(holder.response, "GetTransaction"); err != nil {
+	if err := checkApiErrors(holder.response, source, "GetTransaction"); err != nil {
 		return nil, err
 	}
 	return &holder.Transaction, nil
 }
 
 // GetOrder gets a particular order referenced by id
-func (c Client) GetOrder(id string) (*order, error) {
+func (c Client) GetOrder(id string) (*Order, error) {
 	holder := orderHolder{}
-	if err := c.Get("orders/"+id, nil, &holder); err != nil {
+	source, err := c.request("GET", "orders/"+id, nil, &holder)
+	if err != nil {
 		return nil, err
 	}
-	if err := checkApiErrors(holder.response, "GetOrder"); err != nil {
+	if err := checkApiErrors(holder.response, source, "GetOrder"); err != nil {
 		return nil, err
 	}
 	return &holder.Order, nil
 }
 
 // GetUser gets the user associated with the authentication
-func (c Client) GetUser() (*user, error) {
+func (c Client) GetUser() (*User, error) {
 	holder := usersHolder{}
 	if err := c.Get("users", nil, &holder); err != nil {
 		return nil, err
@@ -422,16 +826,28 @@ func (c Client) GetUser() (*user, error) {
 
 // checkApiErrors checks for errors returned by coinbase API JSON response
 // i.e { "success": false, "errors": ["Button with code code123456 does not exist"], ...}
-func checkApiErrors(resp response, method string) error {
+// The errors are returned as an *APIError describing the request like those
+// of failed responses
+func checkApiErrors(resp response, source requestInfo, method string) error {
 	if resp.Success == false { // Return errors received from API here
-		err := " in " + method + "()"
-		if resp.Errors != nil {
-			err = strings.Join(resp.Errors, ",") + err
-			return errors.New(err)
+		errs := resp.Errors
+		if errs == nil && resp.Error != "" {
+			errs = []string{resp.Error}
 		}
-		if resp.Error != "" { // Return errors received from API here
-			err = resp.Error + err
-			return errors.New(err)
+		if errs != nil {
+			statusCode := source.statusCode
+			if statusCode == 0 {
+				statusCode = http.StatusOK
+			}
+			return &APIError{
+				StatusCode: statusCode,
+				Method:     source.method,
+				Endpoint:   source.endpoint,
+				Errors:     errs,
+				Body:       source.body,
+				RequestID:  source.requestID,
+				caller:     method,
+			}
 		}
 	}
 	return nil
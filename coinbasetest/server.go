@@ -0,0 +1,428 @@
+// Package coinbasetest provides a fake coinbase server for testing code built
+// on the coinbase client without hitting the real API:
+//
+//	s := coinbasetest.NewServer()
+//	defer s.Close()
+//	c := coinbase.ApiKeyClient(s.Key, s.Secret, coinbase.WithBaseURL(s.BaseURL()))
+//
+// The server implements the v1 endpoints called by the client, authenticates
+// requests like coinbase does and answers with the fixtures of the
+// testdata/fixtures.json cassette unless a route is overridden with Handle,
+// Respond or LoadCassette. The API v2 is served at V2BaseURL:
+//
+//	c := coinbase.V2ApiKeyClient(s.Key, s.Secret, coinbase.WithBaseURL(s.V2BaseURL()))
+package coinbasetest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fabioberger/coinbase-go/cassette"
+)
+
+// The default responses of the server, as a cassette whose paths may contain
+// wildcard segments
+//
+//go:embed testdata/fixtures.json
+var fixtures []byte
+
+// Prefix of the API endpoints, i.e /api/v1/account/balance
+const apiPrefix = "/api/v1/"
+
+// Prefix of the API v2 endpoints, i.e /v2/accounts
+const v2Prefix = "/v2/"
+
+// Maximum gap between the CB-ACCESS-TIMESTAMP of a request and the clock of
+// the server
+const timestampWindow = 30 * time.Second
+
+// Server is a fake coinbase server backed by an httptest.Server. Key, Secret
+// and AccessToken are the credentials it accepts and may be changed before the
+// first request is sent
+type Server struct {
+	*httptest.Server
+	Key          string
+	Secret       string
+	AccessToken  string
+	RefreshToken string
+
+	mu        sync.Mutex
+	routes    []route
+	requests  []Request
+	lastNonce map[string]int64
+}
+
+// Request is a request received by the server, with the path relative to the
+// base URL of its API version, i.e "transactions/send_money"
+type Request struct {
+	Version int // API version, 1 or 2
+	Method  string
+	Path    string
+	Query   url.Values
+	Header  http.Header
+	Body    []byte
+}
+
+// route binds a method and path pattern to a handler. Pattern segments
+// starting with ':' match any value, i.e "transactions/:id"
+type route struct {
+	version  int
+	method   string
+	segments []string
+	handler  http.HandlerFunc
+	fixture  bool
+}
+
+// NewServer starts a fake coinbase server. Close it when done
+func NewServer() *Server {
+	return newServer(httptest.NewServer)
+}
+
+// NewTLSServer starts a fake coinbase server over HTTPS. Its certificate is
+// only trusted by the client of s.Client(), to pass to coinbase.WithHTTPClient
+func NewTLSServer() *Server {
+	return newServer(httptest.NewTLSServer)
+}
+
+func newServer(start func(http.Handler) *httptest.Server) *Server {
+	s := &Server{
+		Key:          "test-key",
+		Secret:       "test-secret",
+		AccessToken:  "test-access-token",
+		RefreshToken: "test-refresh-token",
+		lastNonce:    map[string]int64{},
+	}
+	s.loadFixtures()
+	s.Server = start(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// BaseURL returns the URL to pass to coinbase.WithBaseURL, i.e
+// http://127.0.0.1:1234/api/v1/
+func (s *Server) BaseURL() string {
+	return s.URL + apiPrefix
+}
+
+// V2BaseURL returns the URL of the API v2 to pass to coinbase.WithBaseURL, i.e
+// http://127.0.0.1:1234/v2/
+func (s *Server) V2BaseURL() string {
+	return s.URL + v2Prefix
+}
+
+// SiteURL returns the URL of the fake website serving the OAuth endpoints, to
+// pass to coinbase.CustomEnvironment
+func (s *Server) SiteURL() string {
+	return s.URL + "/"
+}
+
+// Handle overrides the handler of a route. pattern is relative to the API base
+// URL and may contain wildcard segments, i.e Handle("GET", "transactions/:id", h).
+// Routes of the API v2 start with /v2/, i.e Handle("GET", "/v2/accounts/:id", h)
+func (s *Server) Handle(method string, pattern string, handler http.HandlerFunc) {
+	version, segments := splitPattern(pattern)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Routes are prepended so that the latest override of a route wins
+	s.routes = append([]route{{
+		version:  version,
+		method:   method,
+		segments: segments,
+		handler:  handler,
+	}}, s.routes...)
+	s.sortRoutes()
+}
+
+// Respond overrides a route to answer with the given status code and JSON body
+func (s *Server) Respond(method string, pattern string, status int, body string) {
+	s.Handle(method, pattern, func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	})
+}
+
+// Requests returns the requests received so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// LastRequest returns the last request received, or nil if there was none
+func (s *Server) LastRequest() *Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		return nil
+	}
+	r := s.requests[len(s.requests)-1]
+	return &r
+}
+
+// LoadCassette overrides routes with the responses recorded in the cassette
+// file at path (see coinbase.WithCassette). Unlike a client replaying the
+// cassette, the server only matches the method and path of requests, which
+// may contain wildcard segments
+func (s *Server) LoadCassette(path string) error {
+	c, err := cassette.Load(path)
+	if err != nil {
+		return err
+	}
+	for _, interaction := range c.Interactions {
+		s.Handle(interaction.Request.Method, interaction.Request.Path, cassetteHandler(interaction.Response))
+	}
+	return nil
+}
+
+// loadFixtures registers a route for every interaction of the fixtures
+// cassette
+func (s *Server) loadFixtures() {
+	c, err := cassette.Parse(fixtures)
+	if err != nil {
+		panic(err)
+	}
+	for _, interaction := range c.Interactions {
+		version, segments := splitPattern(interaction.Request.Path)
+		s.routes = append(s.routes, route{
+			version:  version,
+			method:   interaction.Request.Method,
+			segments: segments,
+			handler:  cassetteHandler(interaction.Response),
+			fixture:  true,
+		})
+	}
+	s.sortRoutes()
+}
+
+// sortRoutes orders routes so that overrides come before fixtures and literal
+// segments are preferred to wildcards. It must be called with mu held
+func (s *Server) sortRoutes() {
+	sort.SliceStable(s.routes, func(i, j int) bool {
+		a, b := s.routes[i], s.routes[j]
+		if a.fixture != b.fixture {
+			return !a.fixture
+		}
+		return wildcards(a.segments) < wildcards(b.segments)
+	})
+}
+
+// splitPattern returns the API version and the segments of a route pattern,
+// which is either relative to the API v1 base URL or starts with one of the
+// API prefixes, i.e "/api/v1/transactions/:id" or "/v2/accounts/:id"
+func splitPattern(pattern string) (int, []string) {
+	version := 1
+	switch {
+	case strings.HasPrefix(pattern, v2Prefix):
+		version = 2
+		pattern = strings.TrimPrefix(pattern, v2Prefix)
+	case strings.HasPrefix(pattern, apiPrefix):
+		pattern = strings.TrimPrefix(pattern, apiPrefix)
+	}
+	return version, strings.Split(strings.Trim(pattern, "/"), "/")
+}
+
+func wildcards(segments []string) int {
+	n := 0
+	for _, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			n++
+		}
+	}
+	return n
+}
+
+func (r route) match(version int, method string, segments []string) bool {
+	if r.version != version || r.method != method || len(r.segments) != len(segments) {
+		return false
+	}
+	for i, segment := range r.segments {
+		if !strings.HasPrefix(segment, ":") && segment != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	if req.URL.Path == "/oauth/token" {
+		s.serveTokens(w, req)
+		return
+	}
+	version := 0
+	switch {
+	case strings.HasPrefix(req.URL.Path, apiPrefix):
+		version = 1
+	case strings.HasPrefix(req.URL.Path, v2Prefix):
+		version = 2
+	default:
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	_, segments := splitPattern(req.URL.Path)
+	path := strings.Join(segments, "/")
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{
+		Version: version,
+		Method:  req.Method,
+		Path:    path,
+		Query:   req.URL.Query(),
+		Header:  req.Header.Clone(),
+		Body:    body,
+	})
+	s.mu.Unlock()
+
+	if version == 2 && !s.authenticateV2(req, body) {
+		writeV2Error(w, http.StatusUnauthorized, "authentication_error", "Invalid credentials")
+		return
+	}
+	if version == 1 && !s.authenticate(req, body) {
+		writeError(w, http.StatusUnauthorized, "Invalid credentials")
+		return
+	}
+
+	s.mu.Lock()
+	var handler http.HandlerFunc
+	for _, r := range s.routes {
+		if r.match(version, req.Method, segments) {
+			handler = r.handler
+			break
+		}
+	}
+	s.mu.Unlock()
+	if handler == nil && version == 2 {
+		writeV2Error(w, http.StatusNotFound, "not_found", "No route matches "+req.Method+" "+path)
+		return
+	}
+	if handler == nil {
+		writeError(w, http.StatusNotFound, "No route matches "+req.Method+" "+path)
+		return
+	}
+	handler(w, req)
+}
+
+// authenticate verifies the API key signature or the OAuth bearer token of
+// a request
+func (s *Server) authenticate(req *http.Request, body []byte) bool {
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		return auth == "Bearer "+s.AccessToken
+	}
+	if req.Header.Get("ACCESS_KEY") != s.Key {
+		return false
+	}
+	nonce, err := strconv.ParseInt(req.Header.Get("ACCESS_NONCE"), 10, 64)
+	if err != nil {
+		return false
+	}
+	scheme := "http://"
+	if req.TLS != nil {
+		scheme = "https://"
+	}
+	message := req.Header.Get("ACCESS_NONCE") + scheme + req.Host + req.URL.RequestURI() + string(body)
+	h := hmac.New(sha256.New, []byte(s.Secret))
+	h.Write([]byte(message))
+	expected := hex.EncodeToString(h.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(req.Header.Get("ACCESS_SIGNATURE"))) {
+		return false
+	}
+	// Nonces must increase so that requests cannot be replayed
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if nonce <= s.lastNonce[s.Key] {
+		return false
+	}
+	s.lastNonce[s.Key] = nonce
+	return true
+}
+
+// authenticateV2 verifies the API v2 key signature or the OAuth bearer token
+// of a request
+func (s *Server) authenticateV2(req *http.Request, body []byte) bool {
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		return auth == "Bearer "+s.AccessToken
+	}
+	if req.Header.Get("CB-ACCESS-KEY") != s.Key {
+		return false
+	}
+	timestamp, err := strconv.ParseInt(req.Header.Get("CB-ACCESS-TIMESTAMP"), 10, 64)
+	if err != nil {
+		return false
+	}
+	if gap := time.Since(time.Unix(timestamp, 0)); gap > timestampWindow || gap < -timestampWindow {
+		return false
+	}
+	message := req.Header.Get("CB-ACCESS-TIMESTAMP") + req.Method + req.URL.RequestURI() + string(body)
+	h := hmac.New(sha256.New, []byte(s.Secret))
+	h.Write([]byte(message))
+	expected := hex.EncodeToString(h.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(req.Header.Get("CB-ACCESS-SIGN")))
+}
+
+// serveTokens implements the OAuth token endpoint, issuing AccessToken for any
+// authorization code and for RefreshToken
+func (s *Server) serveTokens(w http.ResponseWriter, req *http.Request) {
+	params := map[string]string{}
+	json.NewDecoder(req.Body).Decode(&params)
+	switch {
+	case params["grant_type"] == "authorization_code" && params["code"] != "":
+	case params["grant_type"] == "refresh_token" && params["refresh_token"] == s.RefreshToken:
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant","error_description":"The provided authorization grant is invalid"}`))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token":  s.AccessToken,
+		"token_type":    "bearer",
+		"expires_in":    7200,
+		"refresh_token": s.RefreshToken,
+		"scope":         "all",
+	})
+}
+
+func cassetteHandler(resp cassette.Response) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		for k, v := range resp.Header {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(resp.Status)
+		w.Write(resp.ResponseBody())
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"success": false,
+		"errors":  []string{message},
+	})
+}
+
+// writeV2Error writes an API v2 error object, i.e
+// {"errors":[{"id":"not_found","message":"Not found"}]}
+func writeV2Error(w http.ResponseWriter, status int, id string, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"errors": []map[string]string{{"id": id, "message": message}},
+	})
+}
@@ -0,0 +1,219 @@
+package coinbasetest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fabioberger/coinbase-go/cassette"
+)
+
+// signedRequest sends a request signed like the coinbase client does
+func signedRequest(t *testing.T, s *Server, method string, path string, body string, nonce int64) *http.Response {
+	req, err := http.NewRequest(method, s.BaseURL()+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := strconv.FormatInt(nonce, 10)
+	h := hmac.New(sha256.New, []byte(s.Secret))
+	h.Write([]byte(n + s.BaseURL() + path + body))
+	req.Header.Set("ACCESS_KEY", s.Key)
+	req.Header.Set("ACCESS_NONCE", n)
+	req.Header.Set("ACCESS_SIGNATURE", hex.EncodeToString(h.Sum(nil)))
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// signedV2Request sends a request signed like the API v2 client does
+func signedV2Request(t *testing.T, s *Server, method string, path string, body string, timestamp time.Time) *http.Response {
+	req, err := http.NewRequest(method, s.V2BaseURL()+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	h := hmac.New(sha256.New, []byte(s.Secret))
+	h.Write([]byte(ts + method + "/v2/" + path + body))
+	req.Header.Set("CB-ACCESS-KEY", s.Key)
+	req.Header.Set("CB-ACCESS-TIMESTAMP", ts)
+	req.Header.Set("CB-ACCESS-SIGN", hex.EncodeToString(h.Sum(nil)))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestServerFixtures(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	resp := signedRequest(t, s, "GET", "account/balance", "", 1)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 but got %d", resp.StatusCode)
+	}
+	if body := readBody(t, resp); !strings.Contains(body, `"36.62800000"`) {
+		t.Errorf("Unexpected balance fixture %s", body)
+	}
+
+	// Any ID is served the same fixture
+	resp = signedRequest(t, s, "GET", "transactions/abc123", "", 2)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200 but got %d", resp.StatusCode)
+	}
+	readBody(t, resp)
+
+	resp = signedRequest(t, s, "GET", "unknown", "", 3)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status 404 but got %d", resp.StatusCode)
+	}
+	readBody(t, resp)
+
+	if n := len(s.Requests()); n != 3 {
+		t.Errorf("Expected 3 recorded requests but got %d", n)
+	}
+	if r := s.LastRequest(); r.Method != "GET" || r.Path != "unknown" {
+		t.Errorf("Unexpected last request %s %s", r.Method, r.Path)
+	}
+}
+
+func TestServerAuthentication(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	resp := signedRequest(t, s, "GET", "account/balance", "", 10)
+	readBody(t, resp)
+	// A replayed nonce is rejected
+	resp = signedRequest(t, s, "GET", "account/balance", "", 10)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 but got %d", resp.StatusCode)
+	}
+	readBody(t, resp)
+
+	req, _ := http.NewRequest("GET", s.BaseURL()+"account/balance", nil)
+	req.Header.Set("ACCESS_KEY", s.Key)
+	req.Header.Set("ACCESS_NONCE", "11")
+	req.Header.Set("ACCESS_SIGNATURE", "bad")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 but got %d", resp.StatusCode)
+	}
+	readBody(t, resp)
+
+	req, _ = http.NewRequest("GET", s.BaseURL()+"account/balance", nil)
+	req.Header.Set("Authorization", "Bearer "+s.AccessToken)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200 but got %d", resp.StatusCode)
+	}
+	readBody(t, resp)
+}
+
+func TestServerAuthenticationTLS(t *testing.T) {
+	s := NewTLSServer()
+	defer s.Close()
+
+	if !strings.HasPrefix(s.BaseURL(), "https://") {
+		t.Fatalf("Expected an https base URL but got %s", s.BaseURL())
+	}
+	resp := signedRequest(t, s, "GET", "account/balance", "", 10)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200 but got %d", resp.StatusCode)
+	}
+	readBody(t, resp)
+}
+
+func TestServerOverride(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	s.Respond("GET", "transactions/:id", http.StatusNotFound, `{"success":false,"errors":["Transaction not found"]}`)
+	s.Respond("GET", "transactions/known", http.StatusOK, `{"transaction":{"id":"known"}}`)
+
+	resp := signedRequest(t, s, "GET", "transactions/other", "", 1)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status 404 but got %d", resp.StatusCode)
+	}
+	readBody(t, resp)
+
+	resp = signedRequest(t, s, "GET", "transactions/known", "", 2)
+	if body := readBody(t, resp); body != `{"transaction":{"id":"known"}}` {
+		t.Errorf("Unexpected override response %s", body)
+	}
+}
+
+func TestServerLoadCassette(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	c := &cassette.Cassette{Interactions: []cassette.Interaction{{
+		Request:  cassette.Request{Method: "GET", Path: "/api/v1/account/balance"},
+		Response: cassette.Response{Status: http.StatusOK, Body: []byte(`{"amount":"1.00000000","currency":"BTC"}`)},
+	}}}
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.LoadCassette(path); err != nil {
+		t.Fatal(err)
+	}
+	resp := signedRequest(t, s, "GET", "account/balance", "", 1)
+	if body := readBody(t, resp); body != `{"amount":"1.00000000","currency":"BTC"}` {
+		t.Errorf("Unexpected cassette response %s", body)
+	}
+}
+
+func TestServerV2(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	resp := signedV2Request(t, s, "GET", "accounts/abc123", "", time.Now())
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 but got %d", resp.StatusCode)
+	}
+	if body := readBody(t, resp); !strings.Contains(body, `"My Wallet"`) {
+		t.Errorf("Unexpected account fixture %s", body)
+	}
+	if r := s.LastRequest(); r.Version != 2 || r.Path != "accounts/abc123" {
+		t.Errorf("Unexpected request %+v", r)
+	}
+
+	// Stale timestamps are rejected with an API v2 error object
+	resp = signedV2Request(t, s, "GET", "accounts/abc123", "", time.Now().Add(-time.Minute))
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 but got %d", resp.StatusCode)
+	}
+	if body := readBody(t, resp); !strings.Contains(body, `"id":"authentication_error"`) {
+		t.Errorf("Unexpected error %s", body)
+	}
+
+	// v1 routes are not served under the API v2 prefix
+	resp = signedV2Request(t, s, "GET", "account/balance", "", time.Now())
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status 404 but got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,985 @@
+package coinbasetest
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fabioberger/coinbase-go/address"
+)
+
+// Number of items per page of the list endpoints, as on coinbase
+const perPage = 30
+
+// Simulator is a Server keeping a consistent ledger instead of serving
+// fixtures for the endpoints moving money: the account balance, transactions,
+// transfers, buttons and orders. Money sent with SendMoney is debited from the
+// balance and listed by GetTransactions, Buy and Sell create transfers,
+// CreateOrderFromButtonCode creates orders retrievable with GetOrder and so on.
+// Other endpoints keep serving fixtures.
+//
+// The ledger is that of a single BTC account. Amounts in other currencies are
+// converted at the price set with SetPrice. Committed transfers settle
+// immediately, and orders are paid with PayOrder or MispayOrder. Requests
+// repeating the idempotency key of a previous one are answered with the
+// transaction or transfer it created, and LoseResponses simulates timeouts
+type Simulator struct {
+	*Server
+
+	mu            sync.Mutex
+	balance       *big.Rat
+	price         *big.Rat // USD per BTC
+	lastId        int64
+	transactions  []*simTransaction // Newest first
+	transfers     []*simTransfer    // Newest first
+	buttons       map[string]*simButton
+	orders        []*simOrder // Newest first
+	lostResponses int         // Requests moving money to process without answering
+}
+
+// The account owner, as in the fixtures
+var simUser = &simActor{
+	Id:    "5011f33df8182b142400000e",
+	Name:  "User Two",
+	Email: "user2@example.com",
+}
+
+type simAmount struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+type simActor struct {
+	Id    string `json:"id,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+type simTransaction struct {
+	Id               string    `json:"id"`
+	CreatedAt        string    `json:"created_at"`
+	Hsh              string    `json:"hsh,omitempty"`
+	Notes            string    `json:"notes,omitempty"`
+	Idem             string    `json:"idem,omitempty"`
+	Amount           simAmount `json:"amount"`
+	Request          bool      `json:"request"`
+	Status           string    `json:"status"`
+	Sender           *simActor `json:"sender,omitempty"`
+	Recipient        *simActor `json:"recipient,omitempty"`
+	RecipientAddress string    `json:"recipient_address,omitempty"`
+}
+
+type simTransfer struct {
+	Id            string    `json:"id"`
+	Type          string    `json:"type"`
+	Code          string    `json:"code"`
+	CreatedAt     string    `json:"created_at"`
+	Fees          simFees   `json:"fees"`
+	Status        string    `json:"status"`
+	PayoutDate    string    `json:"payout_date"`
+	TransactionId string    `json:"transaction_id"`
+	Idem          string    `json:"idem,omitempty"`
+	Btc           simAmount `json:"btc"`
+	Subtotal      simAmount `json:"subtotal"`
+	Total         simAmount `json:"total"`
+	Description   string    `json:"description"`
+
+	amount *big.Rat // BTC credited to the account once committed
+}
+
+// The payment methods of the fixtures, and whether they can be used to sell
+var simPaymentMethods = map[string]bool{
+	"530eb5b217cb34e07a000011": true,
+	"530eb7e817cb34e07a00001a": false,
+}
+
+type simFees struct {
+	Coinbase simAmount `json:"coinbase"`
+	Bank     simAmount `json:"bank"`
+}
+
+type simButton struct {
+	Code         string    `json:"code"`
+	Type         string    `json:"type"`
+	Name         string    `json:"name"`
+	Description  string    `json:"description,omitempty"`
+	Custom       string    `json:"custom,omitempty"`
+	CallbackUrl  string    `json:"callback_url,omitempty"`
+	Subscription bool      `json:"subscription"`
+	Style        string    `json:"style,omitempty"`
+	Text         string    `json:"text,omitempty"`
+	Price        simAmount `json:"price"`
+}
+
+type simOrder struct {
+	Id             string    `json:"id"`
+	CreatedAt      string    `json:"created_at"`
+	Status         string    `json:"status"`
+	TotalBtc       simAmount `json:"total_btc"`
+	TotalNative    simAmount `json:"total_native"`
+	Custom         string    `json:"custom"`
+	ReceiveAddress string    `json:"receive_address"`
+	Button         struct {
+		Type        string `json:"type"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Id          string `json:"id"`
+	} `json:"button"`
+	Transaction       *simOrderTransaction `json:"transaction"`
+	MispaidBtc        *simAmount           `json:"mispaid_btc,omitempty"`
+	MispaidNative     *simAmount           `json:"mispaid_native,omitempty"`
+	Mispayments       []*simMispayment     `json:"mispayments,omitempty"`
+	RefundAddress     string               `json:"refund_address,omitempty"`
+	RefundTransaction *simTransaction      `json:"refund_transaction,omitempty"`
+}
+
+type simMispayment struct {
+	Id           string    `json:"id"`
+	CreatedAt    string    `json:"created_at"`
+	AmountBtc    simAmount `json:"amount_btc"`
+	AmountNative simAmount `json:"amount_native"`
+}
+
+type simOrderTransaction struct {
+	Id            string `json:"id"`
+	Hash          string `json:"hash"`
+	Confirmations int64  `json:"confirmations"`
+}
+
+// NewSimulator starts a Simulator with an empty ledger, a balance of 0 BTC
+// and a price of 500 USD per BTC. Close it when done
+func NewSimulator() *Simulator {
+	s := &Simulator{
+		Server:  NewServer(),
+		balance: new(big.Rat),
+		price:   big.NewRat(500, 1),
+		buttons: map[string]*simButton{},
+	}
+	s.Handle("GET", "account/balance", s.getBalance)
+	s.Handle("GET", "transactions", s.getTransactions)
+	s.Handle("GET", "transactions/:id", s.getTransaction)
+	s.Handle("POST", "transactions/send_money", s.lossy(s.sendMoney))
+	s.Handle("POST", "transactions/request_money", s.lossy(s.requestMoney))
+	s.Handle("PUT", "transactions/:id/resend_request", s.resendRequest)
+	s.Handle("PUT", "transactions/:id/complete_request", s.completeRequest)
+	s.Handle("DELETE", "transactions/:id/cancel_request", s.cancelRequest)
+	s.Handle("POST", "buys", s.lossy(s.transfer("Buy")))
+	s.Handle("POST", "sells", s.lossy(s.transfer("Sell")))
+	s.Handle("POST", "transfers/:id/commit", s.commitTransfer)
+	s.Handle("GET", "transfers", s.getTransfers)
+	s.Handle("POST", "buttons", s.createButton)
+	s.Handle("GET", "buttons/:id", s.getButton)
+	s.Handle("POST", "buttons/:id/create_order", s.createOrder)
+	s.Handle("POST", "orders", s.createAdHocOrder)
+	s.Handle("GET", "orders", s.getOrders)
+	s.Handle("GET", "orders/:id", s.getOrder)
+	s.Handle("POST", "orders/:id/refund", s.refundOrder)
+	return s
+}
+
+// SetBalance sets the BTC balance of the account, i.e SetBalance("1.5")
+func (s *Simulator) SetBalance(btc string) error {
+	amount, err := parseAmount(btc)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.balance = amount
+	return nil
+}
+
+// Balance returns the BTC balance of the account, i.e "1.50000000"
+func (s *Simulator) Balance() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.balance.FloatString(8)
+}
+
+// SetPrice sets the price of a BTC in USD used to convert amounts
+func (s *Simulator) SetPrice(usd string) error {
+	price, err := parseAmount(usd)
+	if err != nil {
+		return err
+	}
+	if price.Sign() <= 0 {
+		return errors.New("The price must be positive")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.price = price
+	return nil
+}
+
+// PayOrder simulates a customer paying the order referenced by id in full: the
+// order is completed and its BTC total is credited to the account
+func (s *Simulator) PayOrder(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.findOrder(id)
+	if o == nil {
+		return errors.New("Order not found")
+	}
+	if o.Status != "new" {
+		return fmt.Errorf("Order %s is %s", id, o.Status)
+	}
+	amount, _ := parseAmount(o.TotalBtc.Amount)
+	tx := s.receivePayment(o, amount)
+	o.Status = "completed"
+	o.Transaction = &simOrderTransaction{Id: tx.Id, Hash: tx.Hsh}
+	return nil
+}
+
+// MispayOrder simulates a customer paying btc BTC, an amount other than its
+// total, for the order referenced by id: the order becomes mispaid and the
+// amount is credited to the account until it is refunded with RefundOrder
+func (s *Simulator) MispayOrder(id string, btc string) error {
+	amount, err := parseAmount(btc)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.findOrder(id)
+	if o == nil {
+		return errors.New("Order not found")
+	}
+	if o.Status != "new" {
+		return fmt.Errorf("Order %s is %s", id, o.Status)
+	}
+	tx := s.receivePayment(o, amount)
+	paid, native := btcAmount(amount), usdAmount(new(big.Rat).Mul(amount, s.price))
+	o.Status = "mispaid"
+	o.Transaction = &simOrderTransaction{Id: tx.Id, Hash: tx.Hsh}
+	o.MispaidBtc, o.MispaidNative = &paid, &native
+	o.Mispayments = append(o.Mispayments, &simMispayment{
+		Id:           fmt.Sprintf("%024x", s.nextId()),
+		CreatedAt:    now(),
+		AmountBtc:    paid,
+		AmountNative: native,
+	})
+	return nil
+}
+
+// receivePayment credits a payment of amount BTC for order o. It must be
+// called with mu held
+func (s *Simulator) receivePayment(o *simOrder, amount *big.Rat) *simTransaction {
+	tx := s.newTransaction(amount, "complete")
+	tx.Recipient = simUser
+	tx.Notes = "Payment for order " + o.Id
+	s.balance.Add(s.balance, amount)
+	return tx
+}
+
+// LoseResponses makes the simulator process the next n requests moving money
+// (sends, money requests, buys and sells) but answer them with a 503 error, as
+// when the response of coinbase is lost after a timeout
+func (s *Simulator) LoseResponses(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lostResponses = n
+}
+
+// lossy wraps the handler of an endpoint moving money so that its response is
+// lost after the request was processed when LoseResponses says so
+func (s *Simulator) lossy(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		recorder := httptest.NewRecorder()
+		handler(recorder, req)
+		s.mu.Lock()
+		lost := s.lostResponses > 0
+		if lost {
+			s.lostResponses--
+		}
+		s.mu.Unlock()
+		if lost {
+			writeError(w, http.StatusServiceUnavailable, "Service unavailable")
+			return
+		}
+		for k, v := range recorder.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(recorder.Code)
+		w.Write(recorder.Body.Bytes())
+	}
+}
+
+func (s *Simulator) nextId() int64 {
+	s.lastId++
+	return s.lastId
+}
+
+// simulatedAddress returns a valid testnet address derived from id, so that
+// the addresses of orders can be paid to with SendMoney
+func simulatedAddress(id int64) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("simulator-%d", id)))
+	return (&address.Address{Network: address.Testnet, Type: address.P2PKH, Hash: hash[:20]}).String()
+}
+
+// newTransaction records a transaction of amount BTC. It must be called with
+// mu held
+func (s *Simulator) newTransaction(amount *big.Rat, status string) *simTransaction {
+	id := s.nextId()
+	tx := &simTransaction{
+		Id:        fmt.Sprintf("%024x", id),
+		CreatedAt: now(),
+		Hsh:       fmt.Sprintf("%064x", id),
+		Amount:    btcAmount(amount),
+		Status:    status,
+	}
+	s.transactions = append([]*simTransaction{tx}, s.transactions...)
+	return tx
+}
+
+func (s *Simulator) findTransaction(id string) *simTransaction {
+	for _, tx := range s.transactions {
+		if tx.Id == id {
+			return tx
+		}
+	}
+	return nil
+}
+
+// findIdem returns the transaction created by a request carrying the
+// idempotency key idem, if any. It must be called with mu held
+func (s *Simulator) findIdem(idem string) *simTransaction {
+	for _, tx := range s.transactions {
+		if idem != "" && tx.Idem == idem {
+			return tx
+		}
+	}
+	return nil
+}
+
+func (s *Simulator) findOrder(id string) *simOrder {
+	for _, o := range s.orders {
+		if o.Id == id {
+			return o
+		}
+	}
+	return nil
+}
+
+// toBtc converts an amount in currency to BTC. It must be called with mu held
+func (s *Simulator) toBtc(amount *big.Rat, currency string) (*big.Rat, error) {
+	switch strings.ToUpper(currency) {
+	case "", "BTC":
+		return amount, nil
+	case "USD":
+		// Rounded to the satoshi, like the amounts listed in the ledger
+		btc, _ := parseAmount(new(big.Rat).Quo(amount, s.price).FloatString(8))
+		return btc, nil
+	}
+	return nil, fmt.Errorf("Unsupported currency %s", currency)
+}
+
+func (s *Simulator) getBalance(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, btcAmount(s.balance))
+}
+
+func (s *Simulator) getTransactions(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []interface{}{}
+	start, end, stats := paginate(req, len(s.transactions))
+	for _, tx := range s.transactions[start:end] {
+		list = append(list, map[string]interface{}{"transaction": tx})
+	}
+	stats["current_user"] = simUser
+	stats["balance"] = btcAmount(s.balance)
+	stats["native_balance"] = usdAmount(new(big.Rat).Mul(s.balance, s.price))
+	stats["transactions"] = list
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func (s *Simulator) getTransaction(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx := s.findTransaction(pathSegment(req, 1))
+	if tx == nil {
+		writeError(w, http.StatusNotFound, "Transaction not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"transaction": tx})
+}
+
+// transactionParams are the parameters of send_money and request_money
+type transactionParams struct {
+	Transaction struct {
+		To                string `json:"to"`
+		From              string `json:"from"`
+		Amount            string `json:"amount"`
+		AmountString      string `json:"amount_string"`
+		AmountCurrencyIso string `json:"amount_currency_iso"`
+		Notes             string `json:"notes"`
+		UserFee           string `json:"user_fee"`
+		Idem              string `json:"idem"`
+	} `json:"transaction"`
+}
+
+// amount returns the BTC amount of the transaction. It must be called with mu
+// held
+func (s *Simulator) amount(p transactionParams) (*big.Rat, error) {
+	value, currency := p.Transaction.Amount, "BTC"
+	if value == "" {
+		value, currency = p.Transaction.AmountString, p.Transaction.AmountCurrencyIso
+	}
+	amount, err := parseAmount(value)
+	if err != nil {
+		return nil, err
+	}
+	if amount.Sign() <= 0 {
+		return nil, errors.New("Amount must be positive")
+	}
+	return s.toBtc(amount, currency)
+}
+
+func (s *Simulator) sendMoney(w http.ResponseWriter, req *http.Request) {
+	p := transactionParams{}
+	if err := json.NewDecoder(req.Body).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if tx := s.findIdem(p.Transaction.Idem); tx != nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "transaction": tx})
+		return
+	}
+	amount, err := s.amount(p)
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+	if p.Transaction.To == "" {
+		writeFailure(w, "Please enter a recipient")
+		return
+	}
+	total := new(big.Rat).Set(amount)
+	if p.Transaction.UserFee != "" {
+		fee, err := parseAmount(p.Transaction.UserFee)
+		if err != nil {
+			writeFailure(w, err.Error())
+			return
+		}
+		total.Add(total, fee)
+	}
+	if total.Cmp(s.balance) > 0 {
+		writeFailure(w, "You don't have that much money in your account")
+		return
+	}
+	s.balance.Sub(s.balance, total)
+	tx := s.newTransaction(new(big.Rat).Neg(amount), "complete")
+	tx.Notes = p.Transaction.Notes
+	tx.Idem = p.Transaction.Idem
+	tx.Sender = simUser
+	if strings.Contains(p.Transaction.To, "@") {
+		tx.Recipient = &simActor{Email: p.Transaction.To}
+	} else {
+		tx.RecipientAddress = p.Transaction.To
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "transaction": tx})
+}
+
+func (s *Simulator) requestMoney(w http.ResponseWriter, req *http.Request) {
+	p := transactionParams{}
+	if err := json.NewDecoder(req.Body).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if tx := s.findIdem(p.Transaction.Idem); tx != nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "transaction": tx})
+		return
+	}
+	amount, err := s.amount(p)
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+	if !strings.Contains(p.Transaction.From, "@") {
+		writeFailure(w, "Please enter a valid email address")
+		return
+	}
+	tx := s.newTransaction(amount, "pending")
+	tx.Request = true
+	tx.Notes = p.Transaction.Notes
+	tx.Idem = p.Transaction.Idem
+	tx.Sender = &simActor{Email: p.Transaction.From}
+	tx.Recipient = simUser
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "transaction": tx})
+}
+
+// pendingRequest returns the pending money request referenced in the path of
+// req, or writes a failure. It must be called with mu held
+func (s *Simulator) pendingRequest(w http.ResponseWriter, req *http.Request) *simTransaction {
+	tx := s.findTransaction(pathSegment(req, 1))
+	if tx == nil {
+		writeError(w, http.StatusNotFound, "Transaction not found")
+		return nil
+	}
+	if !tx.Request || tx.Status != "pending" {
+		writeFailure(w, "This transaction is not a pending request")
+		return nil
+	}
+	return tx
+}
+
+func (s *Simulator) resendRequest(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pendingRequest(w, req) == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true})
+}
+
+// completeRequest marks a money request as paid and credits its amount
+func (s *Simulator) completeRequest(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx := s.pendingRequest(w, req)
+	if tx == nil {
+		return
+	}
+	amount, _ := parseAmount(tx.Amount.Amount)
+	s.balance.Add(s.balance, amount)
+	tx.Status = "complete"
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "transaction": tx})
+}
+
+func (s *Simulator) cancelRequest(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx := s.pendingRequest(w, req)
+	if tx == nil {
+		return
+	}
+	tx.Status = "canceled"
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true})
+}
+
+// transfer returns the handler of buys or sells. A coinbase fee of 1% and a
+// bank fee of 0.15 USD are charged. Transfers created without commit are
+// quotes settled by commitTransfer
+func (s *Simulator) transfer(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		p := struct {
+			Qty             string `json:"qty"`
+			Currency        string `json:"currency"`
+			Commit          *bool  `json:"commit"`
+			PaymentMethodId string `json:"payment_method_id"`
+			Idem            string `json:"idem"`
+		}{}
+		if err := json.NewDecoder(req.Body).Decode(&p); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, t := range s.transfers {
+			if p.Idem != "" && t.Idem == p.Idem {
+				writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "transfer": t})
+				return
+			}
+		}
+		qty, err := parseAmount(p.Qty)
+		if err == nil && qty.Sign() <= 0 {
+			err = errors.New("Quantity must be positive")
+		}
+		if err != nil {
+			writeFailure(w, err.Error())
+			return
+		}
+		if canSell, ok := simPaymentMethods[p.PaymentMethodId]; p.PaymentMethodId != "" && (!ok || kind == "Sell" && !canSell) {
+			writeFailure(w, "Invalid payment method")
+			return
+		}
+		btc, err := s.toBtc(qty, p.Currency)
+		if err != nil {
+			writeFailure(w, err.Error())
+			return
+		}
+		subtotal := new(big.Rat).Mul(btc, s.price)
+		coinbaseFee := new(big.Rat).Quo(subtotal, big.NewRat(100, 1))
+		bankFee := big.NewRat(15, 100)
+		total := new(big.Rat).Add(coinbaseFee, bankFee)
+		amount := new(big.Rat).Set(btc)
+		if kind == "Buy" {
+			total.Add(subtotal, total)
+		} else {
+			total.Sub(subtotal, total)
+			amount.Neg(amount)
+		}
+		created := time.Now()
+		t := &simTransfer{
+			Id:        fmt.Sprintf("%024x", s.nextId()),
+			Type:      kind,
+			Code:      fmt.Sprintf("%08X", s.nextId()),
+			CreatedAt: created.Format(time.RFC3339),
+			Fees: simFees{
+				Coinbase: usdAmount(coinbaseFee),
+				Bank:     usdAmount(bankFee),
+			},
+			Status:      "Created",
+			PayoutDate:  created.Format(time.RFC3339),
+			Btc:         btcAmount(btc),
+			Subtotal:    usdAmount(subtotal),
+			Total:       usdAmount(total),
+			Description: kind + " of " + btc.FloatString(8) + " BTC",
+			Idem:        p.Idem,
+			amount:      amount,
+		}
+		if p.Commit == nil || *p.Commit {
+			if msg := s.settle(t); msg != "" {
+				writeFailure(w, msg)
+				return
+			}
+		}
+		s.transfers = append([]*simTransfer{t}, s.transfers...)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "transfer": t})
+	}
+}
+
+// settle credits or debits the amount of transfer t and completes it, or
+// returns why it cannot be. It must be called with mu held
+func (s *Simulator) settle(t *simTransfer) string {
+	if new(big.Rat).Neg(t.amount).Cmp(s.balance) > 0 {
+		return "You don't have that much money in your account"
+	}
+	s.balance.Add(s.balance, t.amount)
+	tx := s.newTransaction(t.amount, "complete")
+	tx.Notes = t.Description
+	t.TransactionId = tx.Id
+	t.Status = "Completed"
+	return ""
+}
+
+func (s *Simulator) commitTransfer(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var t *simTransfer
+	for _, transfer := range s.transfers {
+		if transfer.Id == pathSegment(req, 1) {
+			t = transfer
+		}
+	}
+	if t == nil {
+		writeError(w, http.StatusNotFound, "Transfer not found")
+		return
+	}
+	if t.Status != "Created" {
+		writeFailure(w, "This transfer has already been committed")
+		return
+	}
+	if msg := s.settle(t); msg != "" {
+		writeFailure(w, msg)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "transfer": t})
+}
+
+func (s *Simulator) getTransfers(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []interface{}{}
+	start, end, stats := paginate(req, len(s.transfers))
+	for _, t := range s.transfers[start:end] {
+		list = append(list, map[string]interface{}{"transfer": t})
+	}
+	stats["transfers"] = list
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// buttonParams are the parameters of the buttons and orders endpoints
+type buttonParams struct {
+	Button struct {
+		simButton
+		PriceString      string `json:"price_string"`
+		PriceCurrencyIso string `json:"price_currency_iso"`
+	} `json:"button"`
+}
+
+// newButton validates the parameters of a button and registers it. It writes
+// a failure and returns nil if they are invalid. It must be called with mu held
+func (s *Simulator) newButton(w http.ResponseWriter, p buttonParams) *simButton {
+	if p.Button.Name == "" {
+		writeFailure(w, "Name can't be blank")
+		return nil
+	}
+	price, err := parseAmount(p.Button.PriceString)
+	if err != nil || price.Sign() <= 0 {
+		writeFailure(w, "Price must be greater than 0")
+		return nil
+	}
+	currency := strings.ToUpper(p.Button.PriceCurrencyIso)
+	if currency == "" {
+		currency = "BTC"
+	}
+	if _, err := s.toBtc(price, currency); err != nil {
+		writeFailure(w, err.Error())
+		return nil
+	}
+	b := p.Button.simButton
+	if b.Type == "" {
+		b.Type = "buy_now"
+	}
+	b.Code = fmt.Sprintf("%032x", s.nextId())
+	b.Price = amountIn(price, currency)
+	s.buttons[b.Code] = &b
+	return &b
+}
+
+func (s *Simulator) createButton(w http.ResponseWriter, req *http.Request) {
+	p := buttonParams{}
+	if err := json.NewDecoder(req.Body).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b := s.newButton(w, p); b != nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "button": b})
+	}
+}
+
+func (s *Simulator) getButton(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.buttons[pathSegment(req, 1)]
+	if b == nil {
+		writeError(w, http.StatusNotFound, "Button not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "button": b})
+}
+
+func (s *Simulator) createOrder(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.buttons[pathSegment(req, 1)]
+	if b == nil {
+		writeError(w, http.StatusNotFound, "Button not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "order": s.newOrder(b)})
+}
+
+// createAdHocOrder creates an order along with the button it is paid through
+func (s *Simulator) createAdHocOrder(w http.ResponseWriter, req *http.Request) {
+	p := buttonParams{}
+	if err := json.NewDecoder(req.Body).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b := s.newButton(w, p); b != nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "order": s.newOrder(b)})
+	}
+}
+
+// newOrder records a new order of button b. It must be called with mu held
+func (s *Simulator) newOrder(b *simButton) *simOrder {
+	price, _ := parseAmount(b.Price.Amount)
+	btc, _ := s.toBtc(price, b.Price.Currency)
+	id := s.nextId()
+	o := &simOrder{
+		Id:             fmt.Sprintf("%08X", id),
+		CreatedAt:      now(),
+		Status:         "new",
+		TotalBtc:       btcAmount(btc),
+		TotalNative:    b.Price,
+		Custom:         b.Custom,
+		ReceiveAddress: simulatedAddress(id),
+	}
+	o.Button.Type = b.Type
+	o.Button.Name = b.Name
+	o.Button.Description = b.Description
+	o.Button.Id = b.Code
+	s.orders = append([]*simOrder{o}, s.orders...)
+	return o
+}
+
+func (s *Simulator) getOrders(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []interface{}{}
+	start, end, stats := paginate(req, len(s.orders))
+	for _, o := range s.orders[start:end] {
+		list = append(list, map[string]interface{}{"order": o})
+	}
+	stats["orders"] = list
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func (s *Simulator) getOrder(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.findOrder(pathSegment(req, 1))
+	if o == nil {
+		writeError(w, http.StatusNotFound, "Order not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"order": o})
+}
+
+// refundOrder sends the amount paid for a completed or mispaid order, or for
+// one of its mispayments, back to the customer
+func (s *Simulator) refundOrder(w http.ResponseWriter, req *http.Request) {
+	p := struct {
+		Order struct {
+			RefundIsoCode         string `json:"refund_iso_code"`
+			MispaymentId          string `json:"mispayment_id"`
+			ExternalRefundAddress string `json:"external_refund_address"`
+		} `json:"order"`
+	}{}
+	if err := json.NewDecoder(req.Body).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.findOrder(pathSegment(req, 1))
+	if o == nil {
+		writeError(w, http.StatusNotFound, "Order not found")
+		return
+	}
+	if o.RefundTransaction != nil {
+		writeFailure(w, "This order has already been refunded")
+		return
+	}
+	var btc, native simAmount
+	switch {
+	case p.Order.MispaymentId != "":
+		for _, m := range o.Mispayments {
+			if m.Id == p.Order.MispaymentId {
+				btc, native = m.AmountBtc, m.AmountNative
+			}
+		}
+		if btc.Amount == "" {
+			writeFailure(w, "Mispayment not found")
+			return
+		}
+	case o.Status == "completed":
+		btc, native = o.TotalBtc, o.TotalNative
+	case o.Status == "mispaid":
+		btc, native = *o.MispaidBtc, *o.MispaidNative
+	default:
+		writeFailure(w, "Only completed and mispaid orders can be refunded")
+		return
+	}
+	var amount *big.Rat
+	switch currency := strings.ToUpper(p.Order.RefundIsoCode); currency {
+	case "BTC":
+		amount, _ = parseAmount(btc.Amount)
+	case native.Currency:
+		value, _ := parseAmount(native.Amount)
+		amount, _ = s.toBtc(value, currency)
+	default:
+		writeFailure(w, "Refund currency must be BTC or "+native.Currency)
+		return
+	}
+	address := p.Order.ExternalRefundAddress
+	if address == "" {
+		address = o.RefundAddress
+	}
+	if address == "" {
+		writeFailure(w, "The customer did not provide a refund address")
+		return
+	}
+	if amount.Cmp(s.balance) > 0 {
+		writeFailure(w, "You don't have that much money in your account")
+		return
+	}
+	s.balance.Sub(s.balance, amount)
+	tx := s.newTransaction(new(big.Rat).Neg(amount), "complete")
+	tx.Notes = "Refund for order " + o.Id
+	tx.Sender = simUser
+	tx.RecipientAddress = address
+	o.RefundTransaction = tx
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "order": o})
+}
+
+// paginate returns the bounds of the page requested by req among count items,
+// and the pagination stats of the response. The client sends the page number
+// in the JSON body, even for GET requests
+func paginate(req *http.Request, count int) (int, int, map[string]interface{}) {
+	p := struct {
+		Page int `json:"page"`
+	}{}
+	json.NewDecoder(req.Body).Decode(&p)
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	numPages := (count + perPage - 1) / perPage
+	if numPages == 0 {
+		numPages = 1
+	}
+	start := (p.Page - 1) * perPage
+	if start > count {
+		start = count
+	}
+	end := start + perPage
+	if end > count {
+		end = count
+	}
+	return start, end, map[string]interface{}{
+		"total_count":  count,
+		"num_pages":    numPages,
+		"current_page": p.Page,
+	}
+}
+
+// pathSegment returns the i-th segment of the path of req relative to the API
+// base URL
+func pathSegment(req *http.Request, i int) string {
+	segments := strings.Split(strings.TrimPrefix(req.URL.Path, apiPrefix), "/")
+	if i >= len(segments) {
+		return ""
+	}
+	return segments[i]
+}
+
+// writeFailure writes an error the way coinbase reports failed operations:
+// with status 200 and success set to false
+func writeFailure(w http.ResponseWriter, message string) {
+	writeError(w, http.StatusOK, message)
+}
+
+func parseAmount(s string) (*big.Rat, error) {
+	amount, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("Invalid amount %q", s)
+	}
+	return amount, nil
+}
+
+func btcAmount(amount *big.Rat) simAmount {
+	return simAmount{Amount: amount.FloatString(8), Currency: "BTC"}
+}
+
+func usdAmount(amount *big.Rat) simAmount {
+	return simAmount{Amount: amount.FloatString(2), Currency: "USD"}
+}
+
+func amountIn(amount *big.Rat, currency string) simAmount {
+	if currency == "BTC" {
+		return btcAmount(amount)
+	}
+	return usdAmount(amount)
+}
+
+func now() string {
+	return time.Now().Format(time.RFC3339)
+}
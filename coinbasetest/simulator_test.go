@@ -0,0 +1,361 @@
+package coinbasetest_test
+
+import (
+	"testing"
+
+	"github.com/fabioberger/coinbase-go"
+	"github.com/fabioberger/coinbase-go/coinbasetest"
+)
+
+func newSimulatorClient(t *testing.T, opts ...coinbase.ClientOption) (*coinbasetest.Simulator, coinbase.Client) {
+	s := coinbasetest.NewSimulator()
+	t.Cleanup(s.Close)
+	if err := s.SetBalance("2"); err != nil {
+		t.Fatal(err)
+	}
+	return s, coinbase.ApiKeyClient(s.Key, s.Secret, append([]coinbase.ClientOption{coinbase.WithBaseURL(s.BaseURL())}, opts...)...)
+}
+
+func expect(t *testing.T, prefix string, expected string, got string) {
+	if expected != got {
+		t.Errorf("%s Expected '%s' but got '%s'", prefix, expected, got)
+	}
+}
+
+func TestSimulatorSendMoney(t *testing.T) {
+	s, c := newSimulatorClient(t)
+
+	confirmation, err := c.SendMoney(&coinbase.TransactionParams{
+		To:      "user1@example.com",
+		Amount:  coinbase.MustParseMoney("0.5", "BTC"),
+		UserFee: coinbase.MustParseMoney("0.0002", "BTC"),
+		Notes:   "Payout",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "SendMoney", "-0.50000000 BTC", confirmation.Transaction.Amount.String())
+	expect(t, "SendMoney", "1.49980000", s.Balance())
+
+	balance, err := c.GetBalance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "SendMoney", "1.49980000 BTC", balance.String())
+
+	txs, err := c.GetTransactions(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs.Transactions) != 1 {
+		t.Fatalf("SendMoney Expected 1 transaction but got %d", len(txs.Transactions))
+	}
+	expect(t, "SendMoney", confirmation.Transaction.Id, txs.Transactions[0].Id)
+	expect(t, "SendMoney", "user1@example.com", txs.Transactions[0].Recipient.Email)
+
+	tx, err := c.GetTransaction(confirmation.Transaction.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "SendMoney", "Payout", tx.Notes)
+
+	// Amounts in USD are converted at the simulated price
+	s.SetPrice("400")
+	confirmation, err = c.SendMoney(&coinbase.TransactionParams{
+		To:     "37muSN5ZrukVTvyVh3mT5Zc5ew9L9CBare",
+		Amount: coinbase.MustParseMoney("100", "USD"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "SendMoney", "-0.25000000 BTC", confirmation.Transaction.Amount.String())
+	expect(t, "SendMoney", "1.24980000", s.Balance())
+
+	_, err = c.SendMoney(&coinbase.TransactionParams{
+		To:     "user1@example.com",
+		Amount: coinbase.MustParseMoney("5", "BTC"),
+	})
+	if !coinbase.IsInsufficientFunds(err) {
+		t.Errorf("SendMoney Expected an insufficient funds error but got '%v'", err)
+	}
+	expect(t, "SendMoney", "1.24980000", s.Balance())
+}
+
+func TestSimulatorRequests(t *testing.T) {
+	s, c := newSimulatorClient(t)
+
+	confirmation, err := c.RequestMoney(&coinbase.TransactionParams{
+		From:   "user1@example.com",
+		Amount: coinbase.MustParseMoney("0.1", "BTC"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := confirmation.Transaction.Id
+	expect(t, "RequestMoney", "pending", confirmation.Transaction.Status)
+
+	if ok, err := c.ResendRequest(id); err != nil || !ok {
+		t.Errorf("ResendRequest Expected success but got %v, '%v'", ok, err)
+	}
+	if ok, err := c.CancelRequest(id); err != nil || !ok {
+		t.Errorf("CancelRequest Expected success but got %v, '%v'", ok, err)
+	}
+	tx, err := c.GetTransaction(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "CancelRequest", "canceled", tx.Status)
+	if _, err := c.CompleteRequest(id); err == nil {
+		t.Error("CompleteRequest Expected an error completing a canceled request")
+	}
+
+	confirmation, err = c.RequestMoney(&coinbase.TransactionParams{
+		From:   "user1@example.com",
+		Amount: coinbase.MustParseMoney("0.1", "BTC"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	confirmation, err = c.CompleteRequest(confirmation.Transaction.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "CompleteRequest", "complete", confirmation.Transaction.Status)
+	expect(t, "CompleteRequest", "2.10000000", s.Balance())
+
+	if _, err := c.GetTransaction("unknown"); !coinbase.IsNotFound(err) {
+		t.Errorf("GetTransaction Expected a not found error but got '%v'", err)
+	}
+}
+
+func TestSimulatorTransfers(t *testing.T) {
+	s, c := newSimulatorClient(t)
+
+	buy, err := c.Buy(coinbase.MustParseMoney("1", "BTC"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "Buy", "Buy", buy.Type)
+	expect(t, "Buy", "500.00 USD", buy.Subtotal.String())
+	expect(t, "Buy", "505.15 USD", buy.Total.String())
+	expect(t, "Buy", "3.00000000", s.Balance())
+
+	sell, err := c.Sell(coinbase.MustParseMoney("250", "USD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "Sell", "0.50000000 BTC", sell.Btc.String())
+	expect(t, "Sell", "247.35 USD", sell.Total.String())
+	expect(t, "Sell", "2.50000000", s.Balance())
+
+	if _, err := c.Sell(coinbase.MustParseMoney("10", "BTC")); !coinbase.IsInsufficientFunds(err) {
+		t.Errorf("Sell Expected an insufficient funds error but got '%v'", err)
+	}
+
+	transfers, err := c.GetTransfers(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transfers.Transfers) != 2 {
+		t.Fatalf("GetTransfers Expected 2 transfers but got %d", len(transfers.Transfers))
+	}
+	expect(t, "GetTransfers", sell.Code, transfers.Transfers[0].Code)
+	expect(t, "GetTransfers", buy.Code, transfers.Transfers[1].Code)
+}
+
+func TestSimulatorQuotes(t *testing.T) {
+	s, c := newSimulatorClient(t)
+
+	methods, err := c.GetPaymentMethods()
+	if err != nil {
+		t.Fatal(err)
+	}
+	quote, err := c.CreateBuy(&coinbase.BuyParams{
+		Amount:          coinbase.MustParseMoney("1", "BTC"),
+		PaymentMethodId: methods.DefaultBuy,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "CreateBuy", "Created", quote.Status)
+	expect(t, "CreateBuy", "5.00 USD", quote.Fees.Coinbase.String())
+	expect(t, "CreateBuy", "505.15 USD", quote.Total.String())
+	expect(t, "CreateBuy", "2.00000000", s.Balance())
+
+	transfer, err := c.CommitTransfer(quote.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "CommitTransfer", "Completed", transfer.Status)
+	expect(t, "CommitTransfer", "3.00000000", s.Balance())
+	if _, err := c.CommitTransfer(quote.Id); err == nil {
+		t.Error("CommitTransfer Expected an error committing a transfer twice")
+	}
+
+	// The balance is checked again when a sell is committed
+	quote, err = c.CreateSell(&coinbase.SellParams{Amount: coinbase.MustParseMoney("3", "BTC")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Sell(coinbase.MustParseMoney("1", "BTC")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CommitTransfer(quote.Id); !coinbase.IsInsufficientFunds(err) {
+		t.Errorf("CommitTransfer Expected an insufficient funds error but got '%v'", err)
+	}
+	if _, err := c.CreateSell(&coinbase.SellParams{Amount: coinbase.MustParseMoney("1", "BTC"), PaymentMethodId: "530eb7e817cb34e07a00001a"}); err == nil {
+		t.Error("CreateSell Expected an error selling to a card")
+	}
+}
+
+func TestSimulatorCheckout(t *testing.T) {
+	s, c := newSimulatorClient(t)
+
+	button, err := c.CreateButton(&coinbase.Button{
+		Name:             "Widget",
+		PriceString:      "50",
+		PriceCurrencyIso: "USD",
+		Custom:           "Order123",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, err := c.CreateOrderFromButtonCode(button.Code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "CreateOrderFromButtonCode", "new", order.Status)
+	expect(t, "CreateOrderFromButtonCode", "0.10000000 BTC", order.TotalBtc.String())
+	expect(t, "CreateOrderFromButtonCode", "50.00 USD", order.TotalNative.String())
+	expect(t, "CreateOrderFromButtonCode", "Order123", order.Custom)
+
+	if err := s.PayOrder(order.Id); err != nil {
+		t.Fatal(err)
+	}
+	paid, err := c.GetOrder(order.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "GetOrder", "completed", paid.Status)
+	expect(t, "GetOrder", button.Code, paid.Button.Id)
+	expect(t, "PayOrder", "2.10000000", s.Balance())
+
+	it := c.OrdersIter(0)
+	count := 0
+	for it.Next() {
+		count++
+	}
+	if it.Err() != nil || count != 1 {
+		t.Errorf("OrdersIter Expected 1 order but got %d, '%v'", count, it.Err())
+	}
+
+	if _, err := c.CreateOrderFromButtonCode("unknown"); !coinbase.IsNotFound(err) {
+		t.Errorf("CreateOrderFromButtonCode Expected a not found error but got '%v'", err)
+	}
+}
+
+func TestSimulatorRefunds(t *testing.T) {
+	s, c := newSimulatorClient(t)
+
+	order, err := c.CreateOrder(&coinbase.OrderParams{
+		Name:  "Widget",
+		Price: coinbase.MustParseMoney("50", "USD"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	button, err := c.GetButton(order.Button.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "GetButton", "50.00 USD", button.Price.String())
+
+	// A mispayment is refunded in BTC to the address given by support
+	if err := s.MispayOrder(order.Id, "0.05"); err != nil {
+		t.Fatal(err)
+	}
+	mispaid, err := c.GetOrder(order.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "MispayOrder", "mispaid", mispaid.Status)
+	expect(t, "MispayOrder", "2.05000000", s.Balance())
+	if _, err := c.RefundOrder(order.Id, "BTC", "", ""); err == nil {
+		t.Error("RefundOrder Expected an error without a refund address")
+	}
+	refunded, err := c.RefundOrder(order.Id, "BTC", mispaid.Mispayments[0].Id, "n3NzN74yZLhpSE3gQ9K3BbzbUPaCU9Rgd9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "RefundOrder", "-0.05000000 BTC", refunded.RefundTransaction.Amount.String())
+	expect(t, "RefundOrder", "n3NzN74yZLhpSE3gQ9K3BbzbUPaCU9Rgd9", refunded.RefundTransaction.RecipientAddress)
+	expect(t, "RefundOrder", "2.00000000", s.Balance())
+	if _, err := c.RefundOrder(order.Id, "BTC", "", "n3NzN74yZLhpSE3gQ9K3BbzbUPaCU9Rgd9"); err == nil {
+		t.Error("RefundOrder Expected an error refunding an order twice")
+	}
+
+	// A completed order is refunded at its value in USD
+	order, err = c.CreateOrderFromButtonCode(button.Code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PayOrder(order.Id); err != nil {
+		t.Fatal(err)
+	}
+	s.SetPrice("250")
+	refunded, err = c.RefundOrder(order.Id, "USD", "", "n3NzN74yZLhpSE3gQ9K3BbzbUPaCU9Rgd9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "RefundOrder", "-0.20000000 BTC", refunded.RefundTransaction.Amount.String())
+	expect(t, "RefundOrder", "1.90000000", s.Balance())
+
+	orders, err := c.GetButtonOrders(button.Code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 2 {
+		t.Errorf("GetButtonOrders Expected 2 orders but got %d", len(orders))
+	}
+}
+
+func TestSimulatorLostResponses(t *testing.T) {
+	s, c := newSimulatorClient(t, coinbase.WithRetries(coinbase.RetryPolicy{MaxAttempts: 3, RetryableStatus: []int{503}}))
+
+	// The send went through, so it is found by its key instead of being resubmitted
+	s.LoseResponses(1)
+	confirmation, err := c.SendMoney(&coinbase.TransactionParams{
+		To:     "user1@example.com",
+		Amount: coinbase.MustParseMoney("0.5", "BTC"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "LoseResponses", "1.50000000", s.Balance())
+	expect(t, "LoseResponses", confirmation.Idem, confirmation.Transaction.Idem)
+
+	// Without retries, the send is repeated with its key after the error
+	params := &coinbase.TransactionParams{To: "user1@example.com", Amount: coinbase.MustParseMoney("0.5", "BTC"), Idem: coinbase.NewIdempotencyKey()}
+	s.LoseResponses(1)
+	single := coinbase.ApiKeyClient(s.Key, s.Secret, coinbase.WithBaseURL(s.BaseURL()), coinbase.WithRetries(coinbase.NoRetries))
+	if _, err := single.SendMoney(params); err == nil {
+		t.Fatal("LoseResponses Expected an error")
+	}
+	if _, err := c.SendMoney(params); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "LoseResponses", "1.00000000", s.Balance())
+
+	s.LoseResponses(1)
+	buy, err := c.Buy(coinbase.MustParseMoney("1", "BTC"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "LoseResponses", "2.00000000", s.Balance())
+	expect(t, "LoseResponses", "Completed", buy.Status)
+	transfer, err := c.FindTransferByIdem(buy.Idem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "LoseResponses", buy.Id, transfer.Id)
+}
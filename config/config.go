@@ -1,13 +1,8 @@
 package config
 
 var (
-	BaseUrl string
-	Sandbox = false // set to true if you want to use the sandbox API endpoint
-)
-
-func init() {
+	// Deprecated: BaseUrl is no longer read. Use coinbase.WithEnvironment or
+	// coinbase.WithBaseURL to select the API endpoint
 	BaseUrl = "https://api.coinbase.com/v1/"
-	if Sandbox == true {
-		BaseUrl = "https://api.sandbox.coinbase.com/v1/"
-	}
-}
+	Sandbox = false // set to true if you want clients to use the sandbox environment by default
+)
@@ -3,6 +3,7 @@ package coinbase
 import (
 	"fmt"
 	"log"
+	"math/big"
 	"os"
 	"testing"
 
@@ -29,7 +30,7 @@ func TestEndpointGetBalance(t *testing.T) {
 	if err != nil {
 		log.Fatal(err)
 	}
-	assert.IsType(t, 1.1, amount)
+	assert.IsType(t, Money{}, amount)
 }
 
 func TestEndpointGetReceiveAddress(t *testing.T) {
@@ -56,7 +57,7 @@ func TestEndpointGetAllAddresses(t *testing.T) {
 	if err != nil {
 		log.Fatal(err)
 	}
-	assert.IsType(t, "string", addresses.Addresses[0].CreatedAt)
+	assert.IsType(t, Time{}, addresses.Addresses[0].CreatedAt)
 	assert.IsType(t, "string", addresses.Addresses[0].Address)
 }
 
@@ -109,7 +110,7 @@ func TestEndpointGetExchangeRate(t *testing.T) {
 	if err != nil {
 		log.Fatal(err)
 	}
-	assert.IsType(t, 0.0, data)
+	assert.IsType(t, &big.Rat{}, data)
 }
 
 func TestEndpointGetTransactions(t *testing.T) {
@@ -129,7 +130,7 @@ func TestEndpointGetBuyPrice(t *testing.T) {
 		log.Fatal(err)
 	}
 	assert.IsType(t, "string", data.Subtotal.Currency)
-	assert.IsType(t, "string", data.Total.Amount)
+	assert.IsType(t, Money{}, data.Total)
 }
 
 func TestEndpointGetSellPrice(t *testing.T) {
@@ -139,7 +140,7 @@ func TestEndpointGetSellPrice(t *testing.T) {
 		log.Fatal(err)
 	}
 	assert.IsType(t, "string", data.Subtotal.Currency)
-	assert.IsType(t, "string", data.Total.Amount)
+	assert.IsType(t, Money{}, data.Total)
 }
 
 func TestEndpointGetTransaction(t *testing.T) {
@@ -0,0 +1,101 @@
+package coinbase
+
+import (
+	"strings"
+
+	"github.com/fabioberger/coinbase-go/address"
+	"github.com/fabioberger/coinbase-go/config"
+)
+
+// Environment selects the coinbase servers a client talks to. Every client and
+// OAuth service can use a different environment, i.e Sandbox for staging and
+// Production for live traffic within the same binary
+type Environment struct {
+	Name      string
+	BaseUrl   string // Base URL of the API, i.e https://api.coinbase.com/v1/
+	V2BaseUrl string // Base URL of the API v2, i.e https://api.coinbase.com/v2/
+	SiteUrl   string // Base URL of the website serving OAuth and button scripts, i.e https://coinbase.com/
+}
+
+var (
+	// Production is the live coinbase environment
+	Production = Environment{
+		Name:      "production",
+		BaseUrl:   "https://api.coinbase.com/v1/",
+		V2BaseUrl: "https://api.coinbase.com/v2/",
+		SiteUrl:   "https://coinbase.com/",
+	}
+	// Sandbox is the coinbase sandbox environment, running on the bitcoin testnet
+	Sandbox = Environment{
+		Name:      "sandbox",
+		BaseUrl:   "https://api.sandbox.coinbase.com/v1/",
+		V2BaseUrl: "https://api.sandbox.coinbase.com/v2/",
+		SiteUrl:   "https://sandbox.coinbase.com/",
+	}
+)
+
+// CustomEnvironment instantiates an Environment pointing at other servers,
+// i.e a proxy in front of coinbase. The API v2 is expected next to the API v1,
+// i.e at https://proxy/v2/ for https://proxy/v1/
+func CustomEnvironment(baseUrl string, siteUrl string) Environment {
+	env := Environment{
+		Name:    "custom",
+		BaseUrl: withTrailingSlash(baseUrl),
+		SiteUrl: withTrailingSlash(siteUrl),
+	}
+	env.V2BaseUrl = env.v2BaseUrl()
+	return env
+}
+
+// defaultEnvironment returns the environment used by clients instantiated
+// without WithEnvironment. config.Sandbox is read every time so that it can be
+// changed at runtime
+func defaultEnvironment() Environment {
+	if config.Sandbox {
+		return Sandbox
+	}
+	return Production
+}
+
+// WithEnvironment selects the environment of the client (Production by default)
+func WithEnvironment(env Environment) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.env = env
+	}
+}
+
+// network returns the bitcoin network of the environment. ok is false for
+// custom environments, which may stand in front of either
+func (e Environment) network() (network address.Network, ok bool) {
+	switch e.Name {
+	case Production.Name:
+		return address.Mainnet, true
+	case Sandbox.Name:
+		return address.Testnet, true
+	}
+	return 0, false
+}
+
+// Environment returns the environment the client talks to
+func (c Client) Environment() Environment {
+	return c.env
+}
+
+// v2BaseUrl returns V2BaseUrl, or the URL of the API v2 next to BaseUrl if it
+// is not set
+func (e Environment) v2BaseUrl() string {
+	if e.V2BaseUrl != "" {
+		return e.V2BaseUrl
+	}
+	if strings.HasSuffix(e.BaseUrl, "/v1/") {
+		return strings.TrimSuffix(e.BaseUrl, "v1/") + "v2/"
+	}
+	return e.BaseUrl
+}
+
+func withTrailingSlash(url string) string {
+	if !strings.HasSuffix(url, "/") {
+		url += "/"
+	}
+	return url
+}
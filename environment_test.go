@@ -0,0 +1,31 @@
+package coinbase
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fabioberger/coinbase-go/config"
+)
+
+func TestEnvironmentSelection(t *testing.T) {
+	c := ApiKeyClient("key", "secret", WithEnvironment(Sandbox))
+	compareString(t, "EnvironmentSelection", Sandbox.BaseUrl, c.rpc.auth.getBaseUrl())
+	c = ApiKeyClient("key", "secret")
+	compareString(t, "EnvironmentSelection", Production.BaseUrl, c.rpc.auth.getBaseUrl())
+
+	config.Sandbox = true
+	c = ApiKeyClient("key", "secret")
+	config.Sandbox = false
+	compareString(t, "EnvironmentSelection", "sandbox", c.Environment().Name)
+
+	custom := CustomEnvironment("http://localhost:3000/api/v1", "http://localhost:3000")
+	c = OAuthClient(&Tokens{}, WithEnvironment(custom))
+	compareString(t, "EnvironmentSelection", "http://localhost:3000/api/v1/", c.rpc.auth.getBaseUrl())
+}
+
+func TestEnvironmentAuthorizeUrl(t *testing.T) {
+	o := OAuth{ClientId: "id", RedirectUri: "https://example.com/tokens"}
+	compareBool(t, "EnvironmentAuthorizeUrl", true, strings.HasPrefix(o.CreateAuthorizeUrl([]string{"user"}), "https://coinbase.com/oauth/authorize?"))
+	o.env = Sandbox
+	compareBool(t, "EnvironmentAuthorizeUrl", true, strings.HasPrefix(o.CreateAuthorizeUrl([]string{"user"}), "https://sandbox.coinbase.com/oauth/authorize?"))
+}
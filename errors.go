@@ -0,0 +1,158 @@
+package coinbase
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APIError is the error returned whenever the coinbase API rejects a request,
+// either with a non 2xx HTTP response code or with a JSON response whose
+// "success" field is false. Use errors.As to inspect it, or one of the Is*
+// helpers below to classify it
+type APIError struct {
+	StatusCode int           // HTTP response code, i.e 404
+	Method     string        // HTTP method of the failed request, i.e POST
+	Endpoint   string        // Full URL of the failed request
+	Errors     []string      // Errors decoded from the "errors" or "error" field of the response
+	Codes      []string      // IDs of the API v2 error objects, i.e "not_found"
+	Body       []byte        // Raw response body
+	RequestID  string        // Request ID header sent back by coinbase, if any
+	caller     string        // Client method that detected the error, set by checkApiErrors
+	retryAfter time.Duration // Wait requested by the Retry-After header
+}
+
+// Error formats the API error. Errors detected inside a successful response
+// keep the "<errors> in <Method>()" format of previous releases
+func (e *APIError) Error() string {
+	if e.caller != "" {
+		return strings.Join(e.Errors, ",") + " in " + e.caller + "()"
+	}
+	msg := fmt.Sprintf("%s %s failed. Response code was %d %s", e.Method, e.Endpoint, e.StatusCode, http.StatusText(e.StatusCode))
+	if len(e.Errors) > 0 {
+		msg += ": " + strings.Join(e.Errors, ",")
+	}
+	return msg
+}
+
+// requestInfo describes the request which returned a successful response, so
+// that errors found in its body can be reported like failed responses
+type requestInfo struct {
+	method     string
+	endpoint   string
+	statusCode int
+	requestID  string
+	body       []byte
+}
+
+// requestID returns the request ID header sent back by coinbase, if any
+func requestID(resp *http.Response) string {
+	if id := resp.Header.Get("X-Request-Id"); id != "" {
+		return id
+	}
+	return resp.Header.Get("CB-Request-Id")
+}
+
+// newAPIError builds an APIError from an HTTP response and its body, decoding
+// any errors coinbase included in the JSON payload
+func newAPIError(req *http.Request, resp *http.Response, body []byte) *APIError {
+	e := &APIError{
+		StatusCode: resp.StatusCode,
+		Method:     req.Method,
+		Endpoint:   req.URL.String(),
+		Body:       body,
+		RequestID:  requestID(resp),
+		retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+	holder := struct {
+		Errors           []json.RawMessage `json:"errors"`
+		Error            string            `json:"error"`
+		ErrorDescription string            `json:"error_description"`
+	}{}
+	if err := json.Unmarshal(body, &holder); err == nil {
+		for _, raw := range holder.Errors {
+			// The API v1 returns messages, the API v2 objects holding an ID and a message
+			msg := ""
+			object := struct {
+				Id      string `json:"id"`
+				Message string `json:"message"`
+			}{}
+			if err := json.Unmarshal(raw, &msg); err == nil {
+				e.Errors = append(e.Errors, msg)
+			} else if err := json.Unmarshal(raw, &object); err == nil {
+				e.Errors = append(e.Errors, object.Message)
+				e.Codes = append(e.Codes, object.Id)
+			}
+		}
+		if holder.Error != "" {
+			e.Errors = append(e.Errors, holder.Error)
+		}
+		if holder.ErrorDescription != "" {
+			e.Errors = append(e.Errors, holder.ErrorDescription)
+		}
+	}
+	return e
+}
+
+// IsNotFound reports whether err is an APIError for a resource that does not exist
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound) || hasMessage(err, "does not exist", "not found") || HasCode(err, "not_found")
+}
+
+// IsUnauthorized reports whether err is an APIError caused by invalid or
+// expired credentials
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized)
+}
+
+// IsRateLimited reports whether err is an APIError caused by exceeding the
+// coinbase rate limits
+func IsRateLimited(err error) bool {
+	return hasStatus(err, http.StatusTooManyRequests)
+}
+
+// IsInsufficientFunds reports whether err is an APIError caused by an account
+// balance too low to complete the request
+func IsInsufficientFunds(err error) bool {
+	return hasMessage(err, "insufficient funds", "not enough funds", "don't have that much", "exceeds your balance") ||
+		HasCode(err, "insufficient_funds")
+}
+
+func hasStatus(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+func hasMessage(err error, substrings ...string) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	for _, msg := range apiErr.Errors {
+		msg = strings.ToLower(msg)
+		for _, s := range substrings {
+			if strings.Contains(msg, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasCode reports whether err is an APIError holding the API v2 error ID code,
+// i.e HasCode(err, "validation_error")
+func HasCode(err error, code string) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	for _, c := range apiErr.Codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package coinbase
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIErrorFromResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Request-Id", "req123")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"success":false,"errors":["Transaction not found"]}`))
+	}))
+	defer srv.Close()
+
+	c := ApiKeyClient("key", "secret", WithBaseURL(srv.URL))
+	_, err := c.GetTransaction("ID")
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("APIErrorFromResponse Expected *APIError but got '%v'", err)
+	}
+	compareInt(t, "APIErrorFromResponse", 404, int64(apiErr.StatusCode))
+	compareString(t, "APIErrorFromResponse", "GET", apiErr.Method)
+	compareString(t, "APIErrorFromResponse", "req123", apiErr.RequestID)
+	compareString(t, "APIErrorFromResponse", "Transaction not found", apiErr.Errors[0])
+	compareBool(t, "APIErrorFromResponse", true, IsNotFound(err))
+	compareBool(t, "APIErrorFromResponse", false, IsUnauthorized(err))
+}
+
+func TestAPIErrorFromSuccessfulResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("CB-Request-Id", "req456")
+		w.Write([]byte(`{"success":false,"errors":["You don't have that much."]}`))
+	}))
+	defer srv.Close()
+
+	c := ApiKeyClient("key", "secret", WithBaseURL(srv.URL+"/"), WithRetries(NoRetries))
+	_, err := c.SendMoney(&TransactionParams{To: "user1@example.com", Amount: MustParseMoney("1", "BTC")})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("APIErrorFromSuccessfulResponse Expected *APIError but got '%v'", err)
+	}
+	compareInt(t, "APIErrorFromSuccessfulResponse", 200, int64(apiErr.StatusCode))
+	compareString(t, "APIErrorFromSuccessfulResponse", "POST", apiErr.Method)
+	compareString(t, "APIErrorFromSuccessfulResponse", srv.URL+"/transactions/send_money", apiErr.Endpoint)
+	compareString(t, "APIErrorFromSuccessfulResponse", "req456", apiErr.RequestID)
+	compareString(t, "APIErrorFromSuccessfulResponse", "You don't have that much. in send_money()", err.Error())
+	compareBool(t, "APIErrorFromSuccessfulResponse", true, IsInsufficientFunds(err))
+}
+
+func TestAPIErrorFromJSONBody(t *testing.T) {
+	err := checkApiErrors(response{Errors: []string{"You don't have that much."}}, requestInfo{}, "SendMoney")
+	compareString(t, "APIErrorFromJSONBody", "You don't have that much. in SendMoney()", err.Error())
+	compareBool(t, "APIErrorFromJSONBody", true, IsInsufficientFunds(err))
+	compareBool(t, "APIErrorFromJSONBody", false, IsRateLimited(err))
+	if err := checkApiErrors(response{Success: true}, requestInfo{}, "SendMoney"); err != nil {
+		t.Errorf("APIErrorFromJSONBody Expected no error but got '%v'", err)
+	}
+}
@@ -16,6 +16,6 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	fmt.Printf("Balance is %f BTC", balance)
+	fmt.Printf("Balance is %s", balance)
 
 }
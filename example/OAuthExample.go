@@ -4,7 +4,6 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"strconv"
 
 	"github.com/fabioberger/coinbase-go"
 	"github.com/go-martini/martini"
@@ -53,7 +52,7 @@ func main() {
 		if err != nil {
 			return err.Error()
 		}
-		return strconv.FormatFloat(amount, 'f', 6, 64)
+		return amount.String()
 	})
 
 	// HTTP
@@ -11,25 +11,32 @@ type tokensHolder struct {
 	Scope        string `json:"scope,omitempty"`
 }
 
+// accountHolder used to marshal the JSON request returned in CreateAccount
+// and RenameAccount
+type accountHolder struct {
+	response
+	Account Account `json:"account"`
+}
+
 // addressesHolder used to marshal the JSON request returned in GetAllAddresses
 type addressesHolder struct {
-	paginationStats
+	Page
 	Addresses []struct {
-		Address address `json:"address,omitempty"`
+		Address Address `json:"address,omitempty"`
 	} `json:"addresses,omitempty"`
 }
 
 // orderHolder used to marshal the JSON request returned in CreateOrderFromButtonCode and GetOrder
 type orderHolder struct {
 	response
-	Order order `json:"order,omitempty"`
+	Order Order `json:"order,omitempty"`
 }
 
 // orderHolders used to marshal the JSON request returned in GetOrders
 type ordersHolder struct {
-	paginationStats
+	Page
 	Orders []struct {
-		Order order `json:"order,omitempty"`
+		Order Order `json:"order,omitempty"`
 	} `json:"orders,omitempty"`
 }
 
@@ -39,42 +46,42 @@ type buttonHolder struct {
 	Button Button `json:"button,omitempty"`
 }
 
+// paymentMethodsHolder used to marshal the JSON request returned in
+// GetPaymentMethods
+type paymentMethodsHolder struct {
+	PaymentMethods []struct {
+		PaymentMethod PaymentMethod `json:"payment_method"`
+	} `json:"payment_methods"`
+	DefaultBuy  string `json:"default_buy"`
+	DefaultSell string `json:"default_sell"`
+}
+
 // transfersHolder used to marshal the JSON request returned in GetTransfers
 type transfersHolder struct {
-	paginationStats
+	Page
 	Transfers []struct {
-		Transfer transfer `json:"transfer,omitempty"`
+		Transfer Transfer `json:"transfer,omitempty"`
 	} `json:"transfers,omitempty"`
 }
 
 // transferHolder used to marshal the JSON request returned in Buy & Sell
 type transferHolder struct {
 	response
-	Transfer transfer `json:"transfer,omitempty"`
-}
-
-// pricesHolder used to marshal the JSON request returned in GetBuyPrice & GetSellPrice
-type pricesHolder struct {
-	Subtotal amount `json:"subtotal,omitempty"`
-	Fees     []struct {
-		Coinbase amount `json:"coinbase,omitempty"`
-		Bank     amount `json:"bank,omitempty"`
-	} `json:"fees,omitempty"`
-	Total amount `json:"total,omitempty"`
+	Transfer Transfer `json:"transfer,omitempty"`
 }
 
 // usersHolder used to marshal the JSON request returned in GetUser
 type usersHolder struct {
 	response
 	Users []struct {
-		User user `json:"user,omitempty"`
+		User User `json:"user,omitempty"`
 	} `json:"users,omitempty"`
 }
 
 // userHolder used to marshal the JSON request returned in CreateUser
 type userHolder struct {
 	response
-	User  user  `json:"user,omitempty"`
+	User  User  `json:"user,omitempty"`
 	Oauth oauth `json:"oauth,omitempty"`
 }
 
@@ -85,28 +92,21 @@ type response struct {
 	Error   string   `json:"error"`
 }
 
-// contactsHolder used to marshal the JSON request returned in GetContacts
-type contactsHolder struct {
-	paginationStats
-	Contacts []contact `json:"contacts,omitempty"`
-	Emails   []string  `json:"emails,omitempty"` // Add for convenience
-}
-
 // transactionHolder used to marshal the JSON request returned in SendMoney, RequestMoney,
 // GetTransaction
 type transactionHolder struct {
 	response
-	Transaction transaction `json:"transaction"`
-	Transfer    transfer    `json:"transfer"`
+	Transaction Transaction `json:"transaction"`
+	Transfer    Transfer    `json:"transfer"`
 }
 
 // transactionsHolder used to marshal the JSON request returned in GetTransactions
 type transactionsHolder struct {
-	paginationStats
-	CurrentUser   user   `json:"current_user,omitempty"`
-	Balance       amount `json:"balance,omitempty"`
-	NativeBalance amount `json:"native_balance,omitempty"`
+	Page
+	CurrentUser   User  `json:"current_user,omitempty"`
+	Balance       Money `json:"balance,omitempty"`
+	NativeBalance Money `json:"native_balance,omitempty"`
 	Transactions  []struct {
-		Transaction transaction `json:"transaction,omitempty"`
+		Transaction Transaction `json:"transaction,omitempty"`
 	} `json:"transactions,omitempty"`
 }
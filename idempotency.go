@@ -0,0 +1,111 @@
+package coinbase
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+)
+
+// Idempotency keys make the requests moving money safe to repeat: coinbase
+// answers a request carrying the key of a previous one with the transaction
+// it already created. SendMoney, RequestMoney, Buy, Sell, CreateBuy and
+// CreateSell generate a key unless the params carry one, and return it in the
+// result. When a request fails with a retryable error (see RetryPolicy), the
+// transaction is looked up by its key before the request is resubmitted, so
+// that a request which went through despite a timeout is not sent twice.
+//
+// To survive a crash, generate the key with NewIdempotencyKey and persist it
+// before making the request. Calls given a key look for a transaction carrying
+// it first among the 100 most recent transactions or transfers, and return that
+// transaction instead of submitting the request again. If the lookup fails, the
+// request is submitted with the key for coinbase to recognize it, i.e
+//
+//	params.Idem = NewIdempotencyKey()
+//	// Save params.Idem along with the payout
+//	confirmation, err := c.SendMoney(params)
+
+// ErrIdemNotFound is returned by FindTransactionByIdem and FindTransferByIdem
+// when none of the recent transactions or transfers carry the key
+var ErrIdemNotFound = errors.New("No recent transaction carries this idempotency key")
+
+// idemLookupLimit is the number of recent transactions or transfers searched
+// for an idempotency key
+const idemLookupLimit = 100
+
+// NewIdempotencyKey returns a random idempotency key, a version 4 UUID
+func NewIdempotencyKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40 // Version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	h := hex.EncodeToString(b)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// FindTransactionByIdem returns the transaction created with the idempotency
+// key idem among the recent transactions of the account, or ErrIdemNotFound
+func (c Client) FindTransactionByIdem(idem string) (*Transaction, error) {
+	it := c.TransactionsIter(idemLookupLimit)
+	for it.Next() {
+		if tx := it.Value(); tx.Idem == idem {
+			return &tx, nil
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return nil, ErrIdemNotFound
+}
+
+// FindTransferByIdem returns the buy or sell created with the idempotency key
+// idem among the recent transfers of the account, or ErrIdemNotFound
+func (c Client) FindTransferByIdem(idem string) (*Transfer, error) {
+	it := c.TransfersIter(idemLookupLimit)
+	for it.Next() {
+		if t := it.Value(); t.Idem == idem {
+			return &t, nil
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return nil, ErrIdemNotFound
+}
+
+// submitIdempotent makes a request moving money with the idempotency key
+// *idem, generating one if it is empty. submit sends the request through the
+// given client, which makes a single attempt. find looks up the result of a
+// previous request carrying the key and returns ErrIdemNotFound if there is
+// none. Its requests are retried according to the retry policy of c. Failed
+// attempts are retried according to the same policy, unless find reports that
+// they went through
+func (c Client) submitIdempotent(idem *string, submit func(Client) error, find func() error) error {
+	if *idem == "" {
+		*idem = NewIdempotencyKey()
+	} else if err := find(); err == nil {
+		return nil // The request was already made
+	}
+	// The request is submitted whether the key was not found or the lookup
+	// failed, in which case coinbase is left to recognize the key
+	single := c
+	single.rpc.retry = NoRetries
+	for attempt := 1; ; attempt++ {
+		err := submit(single)
+		if err == nil {
+			return nil
+		}
+		wait, ok := c.rpc.retry.shouldRetry(c.Context(), err, attempt)
+		if !ok {
+			return err
+		}
+		if err := sleep(c.Context(), wait); err != nil {
+			return err
+		}
+		if findErr := find(); findErr == nil {
+			return nil
+		} else if !errors.Is(findErr, ErrIdemNotFound) {
+			// Resubmitting could send the money twice
+			return err
+		}
+	}
+}
@@ -1,22 +1,39 @@
 package coinbase
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"log"
 	"os"
+	"reflect"
 	"testing"
+	"time"
+
+	"github.com/fabioberger/coinbase-go/coinbasetest"
 )
 
-// Initialize the client with mock mode enabled on rpc
-// All calls return the corresponding json response from the test_data files
+// Fake coinbase server shared by the mock tests
+var testServer *coinbasetest.Server
+
+func TestMain(m *testing.M) {
+	testServer = coinbasetest.NewServer()
+	code := m.Run()
+	testServer.Close()
+	os.Exit(code)
+}
+
+// Initialize the client against the fake coinbase server
+// All calls return the corresponding json response from the coinbasetest fixtures
 func initTestClient() Client {
-	return apiKeyClientTest(os.Getenv("COINBASE_KEY"), os.Getenv("COINBASE_SECRET"))
+	return ApiKeyClient(testServer.Key, testServer.Secret, WithBaseURL(testServer.BaseURL()))
 }
 
 // About Mock Tests:
-// All Mock Tests simulate requests to the coinbase API by returning the expected
-// return values from a file under the test_data folder. The values received from
-// the file are compared with the expected value given the marshaling of the JSON
-// executed correctly.
+// All Mock Tests send requests to a fake coinbase server returning the expected
+// return values from a file under the coinbasetest/testdata folder. The values
+// received from the server are compared with the expected value given the
+// marshaling of the JSON executed correctly.
 
 func TestMockGetBalanceParse(t *testing.T) {
 	c := initTestClient()
@@ -24,7 +41,7 @@ func TestMockGetBalanceParse(t *testing.T) {
 	if err != nil {
 		log.Fatal(err)
 	}
-	compareFloat(t, "GetBalanceParse", 36.62800000, amount)
+	compareString(t, "GetBalanceParse", "36.62800000 BTC", amount.String())
 }
 
 func TestMockGetReceiveAddressParse(t *testing.T) {
@@ -44,7 +61,7 @@ func TestMockGetAllAddressesParse(t *testing.T) {
 	if err != nil {
 		log.Fatal(err)
 	}
-	compareString(t, "GetAllAddressesParse", "2013-05-09T23:07:08-07:00", addresses.Addresses[0].CreatedAt)
+	compareString(t, "GetAllAddressesParse", "2013-05-09T23:07:08-07:00", addresses.Addresses[0].CreatedAt.Format(time.RFC3339))
 	compareString(t, "GetAllAddressesParse", "mwigfecvyG4MZjb6R5jMbmNcs7TkzhUaCj", addresses.Addresses[1].Address)
 	compareInt(t, "GetAllAddressesParse", 1, int64(addresses.NumPages))
 }
@@ -67,10 +84,43 @@ func TestMockSendMoneyParse(t *testing.T) {
 	if err != nil {
 		log.Fatal(err)
 	}
-	compareString(t, "SendMoneyParse", "-1.23400000", data.Transaction.Amount.Amount)
+	compareString(t, "SendMoneyParse", "-1.23400000", data.Transaction.Amount.Amount())
 	compareString(t, "SendMoneyParse", "37muSN5ZrukVTvyVh3mT5Zc5ew9L9CBare", data.Transaction.RecipientAddress)
 }
 
+func TestMockSendMoneyInvalidRecipient(t *testing.T) {
+	c := initTestClient()
+	before := len(testServer.Requests())
+	// The last character of the address is mistyped
+	params := &TransactionParams{To: "37muSN5ZrukVTvyVh3mT5Zc5ew9L9CBarf", Amount: MustParseMoney("1", "BTC")}
+	if _, err := c.SendMoney(params); !errors.Is(err, ErrInvalidRecipient) {
+		t.Errorf("SendMoneyInvalidRecipient Expected ErrInvalidRecipient but got '%v'", err)
+	}
+
+	// Addresses of the other network are refused too
+	params.To = "muVu2JZo8PbewBHRp6bpqFvVD87qvqEHWA"
+	if _, err := c.SendMoney(params); !errors.Is(err, ErrInvalidRecipient) {
+		t.Errorf("SendMoneyInvalidRecipient Expected a testnet address to be refused but got '%v'", err)
+	}
+	sandbox := ApiKeyClient(testServer.Key, testServer.Secret, WithEnvironment(Sandbox), WithBaseURL(testServer.BaseURL()))
+	params.To = "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2"
+	if _, err := sandbox.SendMoney(params); !errors.Is(err, ErrInvalidRecipient) {
+		t.Errorf("SendMoneyInvalidRecipient Expected a mainnet address to be refused but got '%v'", err)
+	}
+	if len(testServer.Requests()) != before {
+		t.Error("SendMoneyInvalidRecipient Expected no request to be sent")
+	}
+}
+
+func TestMockGenerateReceiveURI(t *testing.T) {
+	c := initTestClient()
+	uri, err := c.GenerateReceiveURI(&AddressParams{Label: "Order 42"}, MustParseMoney("0.5", "BTC"), "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareString(t, "GenerateReceiveURI", "bitcoin:muVu2JZo8PbewBHRp6bpqFvVD87qvqEHWA?amount=0.50000000&label=Order%2042", uri)
+}
+
 func TestMockRequestMoneyParse(t *testing.T) {
 	c := initTestClient()
 	params := &TransactionParams{}
@@ -78,7 +128,7 @@ func TestMockRequestMoneyParse(t *testing.T) {
 	if err != nil {
 		log.Fatal(err)
 	}
-	compareString(t, "RequestMoneyParse", "1.23400000", data.Transaction.Amount.Amount)
+	compareString(t, "RequestMoneyParse", "1.23400000", data.Transaction.Amount.Amount())
 	compareString(t, "RequestMoneyParse", "5011f33df8182b142400000e", data.Transaction.Recipient.Id)
 }
 
@@ -100,6 +150,31 @@ func TestMockCancelRequestParse(t *testing.T) {
 	compareBool(t, "CancelRequestParse", false, data)
 }
 
+func TestMockResendRequestErrors(t *testing.T) {
+	s := coinbasetest.NewServer()
+	defer s.Close()
+	s.Respond("PUT", "transactions/:id/resend_request", 200, `{"success":false,"errors":["Transaction not found"]}`)
+	s.Respond("DELETE", "transactions/:id/cancel_request", 204, "")
+	c := ApiKeyClient(s.Key, s.Secret, WithBaseURL(s.BaseURL()))
+	if _, err := c.ResendRequest("ID"); !IsNotFound(err) {
+		t.Errorf("ResendRequestErrors Expected a not found error but got '%v'", err)
+	}
+	cancelled, err := c.CancelRequest("ID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareBool(t, "ResendRequestErrors", false, cancelled)
+}
+
+func TestMockTLSServer(t *testing.T) {
+	s := coinbasetest.NewTLSServer()
+	defer s.Close()
+	c := ApiKeyClient(s.Key, s.Secret, WithBaseURL(s.BaseURL()), WithHTTPClient(s.Client()))
+	if _, err := c.GetBalance(); err != nil {
+		t.Errorf("TLSServer Expected a signed request to be accepted but got '%v'", err)
+	}
+}
+
 func TestMockCompleteRequestParse(t *testing.T) {
 	c := initTestClient()
 	data, err := c.CompleteRequest("ID")
@@ -119,6 +194,50 @@ func TestMockCreateOrderFromButtonCodeParse(t *testing.T) {
 	compareString(t, "CreateOrderFromButtonCodeParse", "new", data.Status)
 }
 
+func TestMockCreateOrderParse(t *testing.T) {
+	c := initTestClient()
+	data, err := c.CreateOrder(&OrderParams{Name: "test", Price: MustParseMoney("1.23", "USD"), Custom: "Order123"})
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareString(t, "CreateOrderParse", "8QNULQFE", data.Id)
+	compareString(t, "CreateOrderParse", "0.12300000 BTC", data.TotalBtc.String())
+	compareString(t, "CreateOrderParse", `{"button":{"name":"test","custom":"Order123","price_string":"1.23","price_currency_iso":"USD"}}`, string(testServer.LastRequest().Body))
+}
+
+func TestMockRefundOrderParse(t *testing.T) {
+	c := initTestClient()
+	data, err := c.RefundOrder("JV4PNFKL", "BTC", "5335cd2f3aa5f2fc7d000027", "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareString(t, "RefundOrderParse", "mispaid", data.Status)
+	compareString(t, "RefundOrderParse", "5335cd2f3aa5f2fc7d000027", data.Mispayments[0].Id)
+	compareString(t, "RefundOrderParse", "-0.05000000 BTC", data.RefundTransaction.Amount.String())
+	compareString(t, "RefundOrderParse", `{"order":{"refund_iso_code":"BTC","mispayment_id":"5335cd2f3aa5f2fc7d000027"}}`, string(testServer.LastRequest().Body))
+}
+
+func TestMockGetButtonParse(t *testing.T) {
+	c := initTestClient()
+	data, err := c.GetButton("93865b9cae83706ae59220c013bc0afd")
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareString(t, "GetButtonParse", "Sample description", data.Description)
+	compareString(t, "GetButtonParse", "1.23 USD", data.Price.String())
+
+	orders, err := c.GetButtonOrders("eec6d08e9e215195a471eae432a49fc7")
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareInt(t, "GetButtonOrders", 1, int64(len(orders)))
+	orders, err = c.GetButtonOrders(data.Code)
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareInt(t, "GetButtonOrders", 0, int64(len(orders)))
+}
+
 func TestMockCreateUserParse(t *testing.T) {
 	c := initTestClient()
 	data, err := c.CreateUser("test@email.com", "password")
@@ -131,24 +250,58 @@ func TestMockCreateUserParse(t *testing.T) {
 
 func TestMockBuyParse(t *testing.T) {
 	c := initTestClient()
-	data, err := c.Buy(1000.0, true)
+	data, err := c.Buy(MustParseMoney("1000", "BTC"), true)
 	if err != nil {
 		log.Fatal(err)
 	}
-	compareString(t, "Buys", "2013-01-28T16:08:58-08:00", data.CreatedAt)
-	compareString(t, "Buys", "USD", data.Fees.Bank.CurrencyIso)
-	compareString(t, "Buys", "13.55", data.Subtotal.Amount)
+	compareString(t, "Buys", "2013-01-28T16:08:58-08:00", data.CreatedAt.Format(time.RFC3339))
+	compareString(t, "Buys", "USD", data.Fees.Bank.Currency)
+	compareString(t, "Buys", "13.55", data.Subtotal.Amount())
 }
 
 func TestMockSellParse(t *testing.T) {
 	c := initTestClient()
-	data, err := c.Sell(1000.0)
+	data, err := c.Sell(MustParseMoney("1000", "BTC"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareString(t, "Sells", "2013-01-28T16:32:35-08:00", data.CreatedAt.Format(time.RFC3339))
+	compareString(t, "Sells", "USD", data.Fees.Bank.Currency)
+	compareString(t, "Sells", "13.50", data.Subtotal.Amount())
+}
+
+func TestMockCreateBuyParse(t *testing.T) {
+	c := initTestClient()
+	quote, err := c.CreateBuy(&BuyParams{Amount: MustParseMoney("10", "USD"), PaymentMethodId: "530eb5b217cb34e07a000011"})
+	if err != nil {
+		log.Fatal(err)
+	}
+	body := map[string]interface{}{}
+	if err := json.Unmarshal(testServer.LastRequest().Body, &body); err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "CreateBuy", quote.Idem, body["idem"].(string))
+	delete(body, "idem")
+	data, _ := json.Marshal(body)
+	compareString(t, "CreateBuy", `{"commit":false,"currency":"USD","payment_method_id":"530eb5b217cb34e07a000011","qty":"10.00"}`, string(data))
+	compareString(t, "CreateBuy", "13.84 USD", quote.Total.String())
+	transfer, err := c.CommitTransfer(quote.Id)
 	if err != nil {
 		log.Fatal(err)
 	}
-	compareString(t, "Sells", "2013-01-28T16:32:35-08:00", data.CreatedAt)
-	compareString(t, "Sells", "USD", data.Fees.Bank.CurrencyIso)
-	compareString(t, "Sells", "13.50", data.Subtotal.Amount)
+	compareString(t, "CommitTransfer", "transfers/5106d0e6c6d8e6c9a2000003/commit", testServer.LastRequest().Path)
+	compareString(t, "CommitTransfer", "Pending", transfer.Status)
+}
+
+func TestMockGetPaymentMethodsParse(t *testing.T) {
+	c := initTestClient()
+	data, err := c.GetPaymentMethods()
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareInt(t, "GetPaymentMethods", 2, int64(len(data.PaymentMethods)))
+	compareString(t, "GetPaymentMethods", "530eb5b217cb34e07a000011", data.DefaultBuy)
+	compareBool(t, "GetPaymentMethods", false, data.PaymentMethods[1].CanSell)
 }
 
 func TestMockGetContactsParse(t *testing.T) {
@@ -172,7 +325,7 @@ func TestMockGetTransactionsParse(t *testing.T) {
 	}
 	compareInt(t, "GetTransactions", 2, data.TotalCount)
 	compareString(t, "GetTransactions", "5018f833f8182b129c00002f", data.Transactions[0].Id)
-	compareString(t, "GetTransactions", "-1.00000000", data.Transactions[1].Amount.Amount)
+	compareString(t, "GetTransactions", "-1.00000000", data.Transactions[1].Amount.Amount())
 }
 
 func TestMockGetOrdersParse(t *testing.T) {
@@ -213,7 +366,7 @@ func TestMockGetOrder(t *testing.T) {
 		log.Fatal(err)
 	}
 	compareString(t, "GetTransaction", "A7C52JQT", data.Id)
-	compareString(t, "GetTransaction", "BTC", data.TotalBtc.CurrencyIso)
+	compareString(t, "GetTransaction", "BTC", data.TotalBtc.Currency)
 	compareString(t, "GetTransaction", "test", data.Button.Name)
 }
 
@@ -224,10 +377,160 @@ func TestMockGetUser(t *testing.T) {
 		log.Fatal(err)
 	}
 	compareString(t, "GetTransaction", "512db383f8182bd24d000001", data.Id)
-	compareString(t, "GetTransaction", "49.76000000", data.Balance.Amount)
+	compareString(t, "GetTransaction", "49.76000000", data.Balance.Amount())
 	compareString(t, "GetTransaction", "Company Name, Inc.", data.Merchant.CompanyName)
 }
 
+func TestMockGetExchangeRateParse(t *testing.T) {
+	c := initTestClient()
+	rate, err := c.GetExchangeRate("btc", "usd")
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareString(t, "GetExchangeRateParse", "386.53", rate.FloatString(2))
+}
+
+func TestMockGetBuyPriceParse(t *testing.T) {
+	c := initTestClient()
+	prices, err := c.GetBuyPrice(1)
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareString(t, "GetBuyPriceParse", "10.10 USD", prices.Subtotal.String())
+	compareString(t, "GetBuyPriceParse", "0.15 USD", prices.Fees[1].Bank.String())
+	compareString(t, "GetBuyPriceParse", "10.35 USD", prices.Total.String())
+}
+
+func TestMockUnauthorized(t *testing.T) {
+	c := ApiKeyClient(testServer.Key, "wrong-secret", WithBaseURL(testServer.BaseURL()), WithRetries(NoRetries))
+	if _, err := c.GetBalance(); !IsUnauthorized(err) {
+		t.Errorf("Unauthorized Expected an unauthorized error but got '%v'", err)
+	}
+}
+
+func TestMockModelsRoundTrip(t *testing.T) {
+	c := initTestClient()
+	txs, err := c.GetTransactions(1)
+	if err != nil {
+		log.Fatal(err)
+	}
+	orders, err := c.GetOrders(1)
+	if err != nil {
+		log.Fatal(err)
+	}
+	transfers, err := c.GetTransfers(1)
+	if err != nil {
+		log.Fatal(err)
+	}
+	user, err := c.GetUser()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Models can be cached as JSON and decoded back unchanged
+	for _, model := range []interface{}{txs, orders, transfers, user} {
+		data, err := json.Marshal(model)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded := reflect.New(reflect.TypeOf(model).Elem()).Interface()
+		if err := json.Unmarshal(data, decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(model, decoded) {
+			t.Errorf("ModelsRoundTrip Expected %+v but got %+v", model, decoded)
+		}
+	}
+}
+
+func TestMockGetAccountsParse(t *testing.T) {
+	c := initTestClient()
+	data, err := c.GetAccounts(&AccountsParams{AllAccounts: true})
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareInt(t, "GetAccounts", 2, data.TotalCount)
+	compareString(t, "GetAccounts", "536a541fa9393bb3c7000023", data.Accounts[0].Id)
+	compareBool(t, "GetAccounts", true, data.Accounts[0].Primary)
+	compareString(t, "GetAccounts", "500.12 USD", data.Accounts[0].NativeBalance.String())
+
+	it := c.AccountsIter(0)
+	count := 0
+	for it.Next() {
+		count++
+	}
+	if it.Err() != nil || count != 2 {
+		t.Errorf("AccountsIter Expected 2 accounts but got %d, '%v'", count, it.Err())
+	}
+}
+
+func TestMockAccountsParse(t *testing.T) {
+	c := initTestClient()
+	account, err := c.CreateAccount("Savings Wallet")
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareString(t, "CreateAccount", "Savings Wallet", account.Name)
+	compareString(t, "CreateAccount", `{"account":{"name":"Savings Wallet"}}`, string(testServer.LastRequest().Body))
+
+	account, err = c.RenameAccount(account.Id, "Satoshi Wallet")
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareString(t, "RenameAccount", "Satoshi Wallet", account.Name)
+	if err := c.SetPrimaryAccount(account.Id); err != nil {
+		t.Errorf("SetPrimaryAccount Expected no error but got '%v'", err)
+	}
+	if err := c.DeleteAccount(account.Id); err != nil {
+		t.Errorf("DeleteAccount Expected no error but got '%v'", err)
+	}
+}
+
+func TestMockAccountScope(t *testing.T) {
+	c := initTestClient().Account("536a541fa9393bb3c7000034")
+	compareString(t, "AccountScope", "536a541fa9393bb3c7000034", c.AccountId())
+
+	// Requests without params, with params and with an Idem key all carry the account
+	if _, err := c.GetBalance(); err != nil {
+		log.Fatal(err)
+	}
+	compareString(t, "AccountScope", `{"account_id":"536a541fa9393bb3c7000034"}`, string(testServer.LastRequest().Body))
+	if _, err := c.GetTransactions(2); err != nil {
+		log.Fatal(err)
+	}
+	compareString(t, "AccountScope", `{"account_id":"536a541fa9393bb3c7000034","page":2}`, string(testServer.LastRequest().Body))
+	params := &TransactionParams{To: "user1@example.com", Amount: MustParseMoney("1", "BTC"), Idem: "abc"}
+	if _, err := c.SendMoney(params); err != nil {
+		log.Fatal(err)
+	}
+	body := map[string]interface{}{}
+	if err := json.Unmarshal(testServer.LastRequest().Body, &body); err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "AccountScope", "536a541fa9393bb3c7000034", body["account_id"].(string))
+	compareBool(t, "AccountScope", true, canRetry("POST", c.scoped(&transactionRequestParams{Transaction: params})))
+
+	// An account set in the params takes precedence
+	if _, err := c.GetAllAddresses(&AddressesParams{AccountId: "other"}); err != nil {
+		log.Fatal(err)
+	}
+	compareString(t, "AccountScope", `{"account_id":"other"}`, string(testServer.LastRequest().Body))
+
+	// The original client still acts on the primary account
+	if _, err := initTestClient().GetBalance(); err != nil {
+		log.Fatal(err)
+	}
+	compareString(t, "AccountScope", "null", string(testServer.LastRequest().Body))
+}
+
+func TestMockCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c := initTestClient().WithContext(ctx)
+	if _, err := c.GetBalance(); !errors.Is(err, context.Canceled) {
+		t.Errorf("CanceledContext Expected '%v' but got '%v'", context.Canceled, err)
+	}
+}
+
 func compareFloat(t *testing.T, prefix string, expected float64, got float64) {
 	if expected != got {
 		t.Errorf(`%s
@@ -0,0 +1,398 @@
+package coinbase
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Money is an exact amount of a currency, usually stored as an integer number of
+// the currency's minor units (i.e satoshis for BTC, cents for USD). It is used
+// for all amounts sent to and received from the coinbase API in place of floats.
+// Amounts have a single representation, so that equal amounts of the same
+// currency compare equal with ==
+type Money struct {
+	Currency string // ISO code of the currency, i.e BTC
+	units    int64  // Amount in units of 10^-(CurrencyExponent(Currency)+exp)
+	exp      int    // Decimal places of units minus those of the minor unit of Currency
+}
+
+// Amount is the type of the amounts of the response models, an alias of Money
+type Amount = Money
+
+// Number of decimal places of the minor unit of currencies that do not use 2
+var currencyExponents = map[string]int{
+	"BTC": 8, "BCH": 8, "LTC": 8, "ZEC": 8, "DOGE": 8,
+	"ETH": 18, "ETC": 18, "DAI": 18,
+	"USDC": 6, "XRP": 6, "XLM": 7,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+}
+
+// CurrencyExponent returns the number of decimal places of a currency's minor
+// unit, i.e 8 for BTC and 2 for USD
+func CurrencyExponent(currency string) int {
+	if exp, ok := currencyExponents[strings.ToUpper(currency)]; ok {
+		return exp
+	}
+	return 2
+}
+
+// NewMoney instantiates Money from an amount of minor units, i.e NewMoney(2600, "BTC")
+// is 0.00002600 BTC
+func NewMoney(units int64, currency string) Money {
+	return Money{Currency: strings.ToUpper(currency), units: units}
+}
+
+// ParseMoney parses a decimal amount such as "-1.23400000" in the given currency.
+// An error is returned if the amount is more precise than the currency's minor unit
+func ParseMoney(amount string, currency string) (Money, error) {
+	return parseMoney(amount, currency, false)
+}
+
+// MustParseMoney is like ParseMoney but panics if the amount cannot be parsed
+func MustParseMoney(amount string, currency string) Money {
+	m, err := ParseMoney(amount, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// parseMoney parses a decimal amount. Amounts more precise than the minor unit
+// of the currency are rejected unless lenient, in which case they keep all their
+// decimal places. Amounts too large to be counted in minor units, i.e 100 ETH in
+// units of 10^-18, only keep the decimal places they have
+func parseMoney(amount string, currency string, lenient bool) (Money, error) {
+	exp := CurrencyExponent(currency)
+	places := exp
+	if i := strings.IndexByte(amount, '.'); i >= 0 {
+		places = len(strings.TrimRight(strings.TrimSpace(amount[i+1:]), "0"))
+		if places > exp && !lenient {
+			return Money{}, fmt.Errorf("amount %q has more than %d decimal places", amount, exp)
+		}
+		if places < exp {
+			places = exp
+		}
+	}
+	units, err := parseUnits(amount, places)
+	if errors.Is(err, strconv.ErrRange) {
+		if i := strings.IndexByte(amount, '.'); i >= 0 {
+			places = len(strings.TrimRight(strings.TrimSpace(amount[i+1:]), "0"))
+		} else {
+			places = 0
+		}
+		units, err = parseUnits(amount, places)
+	}
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Currency: strings.ToUpper(currency), units: units, exp: places - exp}.normalized(), nil
+}
+
+// parseUnits converts a decimal string into an integer number of 10^-exp units
+func parseUnits(amount string, exp int) (int64, error) {
+	s := strings.TrimSpace(amount)
+	negative := false
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	if whole == "" && frac == "" || strings.ContainsAny(whole+frac, "+-") {
+		return 0, fmt.Errorf("invalid amount %q", amount)
+	}
+	if len(frac) > exp {
+		if strings.Trim(frac[exp:], "0") != "" {
+			return 0, fmt.Errorf("amount %q has more than %d decimal places", amount, exp)
+		}
+		frac = frac[:exp]
+	}
+	frac += strings.Repeat("0", exp-len(frac))
+	if whole == "" {
+		whole = "0"
+	}
+	units, err := strconv.ParseInt(whole+frac, 10, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, fmt.Errorf("amount %q overflows: %w", amount, strconv.ErrRange)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", amount)
+	}
+	if negative {
+		units = -units
+	}
+	return units, nil
+}
+
+// Units returns the amount in minor units of its currency, i.e 100000000 for
+// 1 BTC. ok is false if the amount cannot be counted in minor units: amounts
+// decoded from coinbase with more decimal places than the minor unit, and
+// amounts too large for an int64, i.e above 9.22 ETH (see Rat)
+func (m Money) Units() (units int64, ok bool) {
+	if m.exp > 0 {
+		return 0, false
+	}
+	scaled, err := m.rescale(0)
+	if err != nil {
+		return 0, false
+	}
+	return scaled.units, true
+}
+
+// exponent returns the number of decimal places of units. It is the one of the
+// currency's minor unit, except for amounts decoded from coinbase with more
+// decimal places, and amounts too large to be counted in minor units
+func (m Money) exponent() int {
+	return CurrencyExponent(m.Currency) + m.exp
+}
+
+// Amount formats the amount as a decimal string with all the decimal places of
+// the currency's minor unit, or of the amount if it has more, i.e "1.23400000"
+func (m Money) Amount() string {
+	exp := m.exponent()
+	sign := ""
+	if m.units < 0 {
+		sign = "-"
+	}
+	digits := strconv.FormatUint(uint64(abs(m.units)), 10)
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	whole, frac := digits[:len(digits)-exp], digits[len(digits)-exp:]
+	if padding := CurrencyExponent(m.Currency) - exp; padding > 0 {
+		// Amounts counted in larger units than the minor unit
+		frac += strings.Repeat("0", padding)
+	}
+	if frac == "" {
+		return sign + whole
+	}
+	return sign + whole + "." + frac
+}
+
+// String formats the amount followed by its currency, i.e "1.23400000 BTC"
+func (m Money) String() string {
+	return m.Amount() + " " + m.Currency
+}
+
+// Rat returns the amount as an exact rational number of major units
+func (m Money) Rat() *big.Rat {
+	denom := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(m.exponent())), nil)
+	return new(big.Rat).SetFrac(big.NewInt(m.units), denom)
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m.units == 0
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of the amount
+func (m Money) Sign() int {
+	switch {
+	case m.units < 0:
+		return -1
+	case m.units > 0:
+		return 1
+	}
+	return 0
+}
+
+// Neg returns the amount with its sign inverted
+func (m Money) Neg() Money {
+	m.units = -m.units
+	return m
+}
+
+// Abs returns the absolute value of the amount
+func (m Money) Abs() Money {
+	m.units = abs(m.units)
+	return m
+}
+
+// Add returns the sum of two amounts of the same currency
+func (m Money) Add(o Money) (Money, error) {
+	if err := m.checkCurrency(o); err != nil {
+		return Money{}, err
+	}
+	m, o, err := align(m, o)
+	if err != nil {
+		return Money{}, err
+	}
+	if (o.units > 0 && m.units > math.MaxInt64-o.units) || (o.units < 0 && m.units < math.MinInt64-o.units) {
+		return Money{}, errors.New("amount overflows")
+	}
+	m.units += o.units
+	return m.normalized(), nil
+}
+
+// Sub returns the difference of two amounts of the same currency
+func (m Money) Sub(o Money) (Money, error) {
+	if o.units == math.MinInt64 {
+		return Money{}, errors.New("amount overflows")
+	}
+	return m.Add(o.Neg())
+}
+
+// Mul returns the amount multiplied by n
+func (m Money) Mul(n int64) (Money, error) {
+	if n != 0 && m.units != 0 {
+		product := m.units * n
+		if product/n != m.units || (m.units == -1 && n == math.MinInt64) || (n == -1 && m.units == math.MinInt64) {
+			return Money{}, errors.New("amount overflows")
+		}
+		m.units = product
+		return m.normalized(), nil
+	}
+	return NewMoney(0, m.Currency), nil
+}
+
+// Convert converts the amount into another currency given the exchange rate
+// between the two (as returned by GetExchangeRate). The result is rounded half
+// away from zero to the minor unit of the target currency, or to fewer decimal
+// places if it is too large to be counted in minor units
+func (m Money) Convert(rate *big.Rat, currency string) (Money, error) {
+	value := new(big.Rat).Mul(m.Rat(), rate)
+	for exp := CurrencyExponent(currency); exp >= 0; exp-- {
+		scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil)
+		scaled := new(big.Rat).Mul(value, new(big.Rat).SetInt(scale))
+		quo, rem := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+		if new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(scaled.Denom()) >= 0 {
+			quo.Add(quo, big.NewInt(int64(scaled.Sign())))
+		}
+		if quo.IsInt64() {
+			target := NewMoney(quo.Int64(), currency)
+			target.exp = exp - CurrencyExponent(currency)
+			return target.normalized(), nil
+		}
+	}
+	return Money{}, errors.New("amount overflows")
+}
+
+// Cmp compares two amounts of the same currency and returns -1, 0 or +1 if m
+// is less than, equal to or greater than o
+func (m Money) Cmp(o Money) (int, error) {
+	if err := m.checkCurrency(o); err != nil {
+		return 0, err
+	}
+	return m.Rat().Cmp(o.Rat()), nil
+}
+
+// Equal reports whether two amounts have the same currency and value
+func (m Money) Equal(o Money) bool {
+	return strings.EqualFold(m.Currency, o.Currency) && m.Rat().Cmp(o.Rat()) == 0
+}
+
+// align returns m and o counted in units of the same number of decimal places
+func align(m Money, o Money) (Money, Money, error) {
+	m, o = m.trimmed(), o.trimmed()
+	var err error
+	if m.exp < o.exp {
+		m, err = m.rescale(o.exp)
+	} else if o.exp < m.exp {
+		o, err = o.rescale(m.exp)
+	}
+	return m, o, err
+}
+
+// rescale returns the amount counted in units of exp more decimal places than
+// the minor unit of its currency. exp must not be lower than m.exp
+func (m Money) rescale(exp int) (Money, error) {
+	for ; m.exp < exp; m.exp++ {
+		if m.units > math.MaxInt64/10 || m.units < math.MinInt64/10 {
+			return Money{}, errors.New("amount overflows")
+		}
+		m.units *= 10
+	}
+	return m, nil
+}
+
+// trimmed returns the amount without its trailing zero decimal places
+func (m Money) trimmed() Money {
+	for m.units%10 == 0 && m.exponent() > 0 {
+		m.units /= 10
+		m.exp--
+	}
+	return m
+}
+
+// normalized returns the amount counted in minor units if possible. Otherwise
+// it drops the trailing zero decimal places, as parseMoney does
+func (m Money) normalized() Money {
+	for m.exp > 0 && m.units%10 == 0 {
+		m.units /= 10
+		m.exp--
+	}
+	if m.exp < 0 {
+		if scaled, err := m.rescale(0); err == nil {
+			return scaled
+		}
+		return m.trimmed()
+	}
+	return m
+}
+
+func (m Money) checkCurrency(o Money) error {
+	if !strings.EqualFold(m.Currency, o.Currency) {
+		return fmt.Errorf("currency mismatch: %s and %s", m.Currency, o.Currency)
+	}
+	return nil
+}
+
+// MarshalJSON encodes the amount the way coinbase does, i.e
+// {"amount":"1.23400000","currency":"BTC"}
+func (m Money) MarshalJSON() ([]byte, error) {
+	if m.Currency == "" && m.units == 0 {
+		return []byte("null"), nil
+	}
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{m.Amount(), m.Currency})
+}
+
+// UnmarshalJSON decodes both amount formats returned by coinbase, i.e
+// {"amount":"1.23400000","currency":"BTC"} and {"cents":123400000,"currency_iso":"BTC"}
+func (m *Money) UnmarshalJSON(data []byte) error {
+	holder := struct {
+		Amount      *string      `json:"amount"`
+		Currency    string       `json:"currency"`
+		Cents       *json.Number `json:"cents"`
+		CurrencyIso string       `json:"currency_iso"`
+	}{}
+	if err := json.Unmarshal(data, &holder); err != nil {
+		return err
+	}
+	*m = Money{}
+	switch {
+	case holder.Amount != nil:
+		// Amounts returned by coinbase keep all their decimal places, even for
+		// currencies whose minor unit is not known here
+		money, err := parseMoney(*holder.Amount, holder.Currency, true)
+		if err != nil {
+			return err
+		}
+		*m = money
+	case holder.Cents != nil:
+		units, err := parseUnits(holder.Cents.String(), 0)
+		if err != nil {
+			return err
+		}
+		*m = NewMoney(units, holder.CurrencyIso)
+	}
+	return nil
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,113 @@
+package coinbase
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestMoneyParseAndFormat(t *testing.T) {
+	m, err := ParseMoney("-1.234", "btc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	units, ok := m.Units()
+	compareBool(t, "MoneyParseAndFormat", true, ok)
+	compareInt(t, "MoneyParseAndFormat", -123400000, units)
+	compareString(t, "MoneyParseAndFormat", "-1.23400000 BTC", m.String())
+	compareString(t, "MoneyParseAndFormat", "0.05", MustParseMoney(".05", "USD").Amount())
+	compareString(t, "MoneyParseAndFormat", "0.00000001", NewMoney(1, "BTC").Amount())
+	compareString(t, "MoneyParseAndFormat", "500", MustParseMoney("500.00", "JPY").Amount())
+	for _, invalid := range []string{"", ".", "1.2.3", "1e5", "--1", "0.000000001"} {
+		if _, err := ParseMoney(invalid, "BTC"); err == nil {
+			t.Errorf("MoneyParseAndFormat Expected an error parsing '%s'", invalid)
+		}
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	a := MustParseMoney("0.1", "BTC")
+	b := MustParseMoney("0.2", "BTC")
+	sum, err := a.Add(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareBool(t, "MoneyArithmetic", true, sum.Equal(MustParseMoney("0.3", "BTC")))
+	diff, _ := a.Sub(b)
+	compareString(t, "MoneyArithmetic", "-0.10000000", diff.Amount())
+	cmp, _ := a.Cmp(b)
+	compareInt(t, "MoneyArithmetic", -1, int64(cmp))
+	if _, err := a.Add(MustParseMoney("1", "USD")); err == nil {
+		t.Error("MoneyArithmetic Expected an error adding different currencies")
+	}
+	usd, err := MustParseMoney("1.5", "BTC").Convert(big.NewRat(38653, 100), "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "MoneyArithmetic", "579.80 USD", usd.String())
+}
+
+func TestMoneyCryptocurrencies(t *testing.T) {
+	eth := MustParseMoney("0.1", "ETH")
+	compareString(t, "MoneyCryptocurrencies", "0.100000000000000000 ETH", eth.String())
+	units, ok := eth.Units()
+	compareBool(t, "MoneyCryptocurrencies", true, ok)
+	compareInt(t, "MoneyCryptocurrencies", 100000000000000000, units)
+
+	// Amounts too large to be counted in wei keep the decimal places they have
+	large := MustParseMoney("1500.25", "ETH")
+	compareString(t, "MoneyCryptocurrencies", "1500.250000000000000000 ETH", large.String())
+	if _, ok := large.Units(); ok {
+		t.Error("MoneyCryptocurrencies Expected 1500.25 ETH not to fit in wei")
+	}
+	sum, err := large.Add(eth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "MoneyCryptocurrencies", "1500.350000000000000000", sum.Amount())
+	// Equal amounts have the same representation however they were computed
+	compareBool(t, "MoneyCryptocurrencies", true, sum == MustParseMoney("1500.350", "ETH"))
+	back, _ := sum.Sub(large)
+	compareBool(t, "MoneyCryptocurrencies", true, back == eth)
+	cmp, _ := eth.Cmp(large)
+	compareInt(t, "MoneyCryptocurrencies", -1, int64(cmp))
+	compareBool(t, "MoneyCryptocurrencies", true, sum.Equal(MustParseMoney("1500.35", "ETH")))
+
+	converted, err := MustParseMoney("50000", "USD").Convert(big.NewRat(1, 2000), "ETH")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "MoneyCryptocurrencies", "25.000000000000000000 ETH", converted.String())
+	if _, err := ParseMoney("0.0000000000000000001", "ETH"); err == nil {
+		t.Error("MoneyCryptocurrencies Expected an error parsing an amount smaller than a wei")
+	}
+
+	// Amounts returned by coinbase may have more decimal places than the minor unit
+	holder := struct {
+		Balance Money `json:"balance"`
+	}{}
+	if err := json.Unmarshal([]byte(`{"balance":{"amount":"0.123456789","currency":"XYZ"}}`), &holder); err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "MoneyCryptocurrencies", "0.123456789 XYZ", holder.Balance.String())
+	encoded, _ := json.Marshal(holder.Balance)
+	compareString(t, "MoneyCryptocurrencies", `{"amount":"0.123456789","currency":"XYZ"}`, string(encoded))
+}
+
+func TestMoneyJSON(t *testing.T) {
+	holder := struct {
+		Amount Money `json:"amount"`
+		Fee    Money `json:"fee"`
+	}{}
+	data := []byte(`{"amount":{"amount":"13.55","currency":"USD"},"fee":{"cents":10000000,"currency_iso":"BTC"}}`)
+	if err := json.Unmarshal(data, &holder); err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "MoneyJSON", "13.55 USD", holder.Amount.String())
+	compareString(t, "MoneyJSON", "0.10000000 BTC", holder.Fee.String())
+	encoded, _ := json.Marshal(holder.Fee)
+	compareString(t, "MoneyJSON", `{"amount":"0.10000000","currency":"BTC"}`, string(encoded))
+
+	params, _ := json.Marshal(TransactionParams{To: "user@example.com", Amount: MustParseMoney("10", "USD")})
+	compareString(t, "MoneyJSON", `{"to":"user@example.com","amount_string":"10.00","amount_currency_iso":"USD"}`, string(params))
+}
@@ -1,6 +1,7 @@
 package coinbase
 
 import (
+	"context"
 	"net/http"
 	"net/url"
 	"strings"
@@ -13,12 +14,14 @@ type OAuth struct {
 	ClientSecret string
 	RedirectUri  string
 	Rpc          rpc
+	ctx          context.Context
+	env          Environment
 }
 
 // OAuthService Instantiates OAuth Struct in order to send service related OAuth requests
-func OAuthService(clientId string, clientSecret string, redirectUri string) (*OAuth, error) {
-	certFilePath := basePath + "/ca-coinbase.crt"
-	serviceAuth, err := serviceOAuth(certFilePath)
+func OAuthService(clientId string, clientSecret string, redirectUri string, opts ...ClientOption) (*OAuth, error) {
+	cfg := newClientConfig(opts)
+	serviceAuth, err := serviceOAuth(cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -27,19 +30,44 @@ func OAuthService(clientId string, clientSecret string, redirectUri string) (*OA
 		ClientSecret: clientSecret,
 		RedirectUri:  redirectUri,
 		Rpc: rpc{
-			auth: serviceAuth,
-			mock: false,
+			auth:      serviceAuth,
+			retry:     cfg.retry,
+			userAgent: cfg.userAgent,
 		},
+		env: cfg.env,
 	}
 	return &o, nil
 }
 
+// WithContext returns a copy of the OAuth service whose token requests are
+// bound to ctx
+func (o OAuth) WithContext(ctx context.Context) OAuth {
+	if ctx == nil {
+		panic("nil context")
+	}
+	o.ctx = ctx
+	return o
+}
+
+// Context returns the context token requests are bound to. It defaults to
+// context.Background()
+func (o OAuth) Context() context.Context {
+	if o.ctx != nil {
+		return o.ctx
+	}
+	return context.Background()
+}
+
 // CreateAuthorizeUrl create the Authorize Url used to redirect users for
-// coinbase app authorization. The scope parameter includes the specific
+// coinbase app authorization on the website of the service's environment. The scope parameter includes the specific
 // permissions one wants to ask from the user
 func (o OAuth) CreateAuthorizeUrl(scope []string) string {
-	Url, _ := url.Parse("https://coinbase.com")
-	Url.Path += "/oauth/authorize"
+	siteUrl := o.env.SiteUrl
+	if siteUrl == "" {
+		siteUrl = Production.SiteUrl
+	}
+	Url, _ := url.Parse(siteUrl)
+	Url.Path += "oauth/authorize"
 
 	parameters := url.Values{}
 	parameters.Add("response_type", "code")
@@ -52,26 +80,29 @@ func (o OAuth) CreateAuthorizeUrl(scope []string) string {
 }
 
 // RefreshTokens refreshes a users existing OAuth tokens
-func (o OAuth) RefreshTokens(oldTokens map[string]interface{}) (*oauthTokens, error) {
-	refresh_token := oldTokens["refresh_token"].(string)
-	return o.GetTokens(refresh_token, "refresh_token")
+func (o OAuth) RefreshTokens(oldTokens *Tokens) (*Tokens, error) {
+	return o.GetTokens(oldTokens.RefreshToken, "refresh_token")
 }
 
 // NewTokens generates new tokens for an OAuth user
-func (o OAuth) NewTokens(code string) (*oauthTokens, error) {
+func (o OAuth) NewTokens(code string) (*Tokens, error) {
 	return o.GetTokens(code, "authorization_code")
 }
 
 // NewTokensRequest generates new tokens for OAuth user given an http request
-// containing the query parameter 'code'
-func (o OAuth) NewTokensFromRequest(req *http.Request) (*oauthTokens, error) {
+// containing the query parameter 'code'. The token request is bound to the
+// context of req unless a context was set with WithContext
+func (o OAuth) NewTokensFromRequest(req *http.Request) (*Tokens, error) {
 	query := req.URL.Query()
 	code := query.Get("code")
+	if o.ctx == nil {
+		o.ctx = req.Context()
+	}
 	return o.GetTokens(code, "authorization_code")
 }
 
 // GetTokens gets tokens for an OAuth user specifying a grantType (i.e authorization_code)
-func (o OAuth) GetTokens(code string, grantType string) (*oauthTokens, error) {
+func (o OAuth) GetTokens(code string, grantType string) (*Tokens, error) {
 
 	postVars := map[string]string{
 		"grant_type":    grantType,
@@ -86,12 +117,12 @@ func (o OAuth) GetTokens(code string, grantType string) (*oauthTokens, error) {
 		postVars["code"] = code
 	}
 	holder := tokensHolder{}
-	err := o.Rpc.Request("POST", "oauth/token", postVars, &holder)
+	err := o.Rpc.Request(o.Context(), "POST", "oauth/token", postVars, &holder)
 	if err != nil {
 		return nil, err
 	}
 
-	tokens := oauthTokens{
+	tokens := Tokens{
 		AccessToken:  holder.AccessToken,
 		RefreshToken: holder.RefreshToken,
 		ExpireTime:   time.Now().UTC().Unix() + holder.ExpiresIn,
@@ -0,0 +1,181 @@
+package coinbase
+
+import (
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/fabioberger/coinbase-go/cassette"
+)
+
+// ClientOption configures a client at construction time, i.e
+// ApiKeyClient(key, secret, WithRequestTimeout(10*time.Second))
+type ClientOption func(*clientConfig)
+
+// clientConfig holds the settings applied by ClientOptions
+type clientConfig struct {
+	httpClient     *http.Client
+	baseUrl        string
+	dialTimeout    time.Duration
+	requestTimeout time.Duration
+	userAgent      string
+	apiVersion     string // CB-VERSION of API v2 requests
+	proxy          func(*http.Request) (*url.URL, error)
+	retry          RetryPolicy
+	env            Environment
+	certPool       *x509.CertPool     // Pinned certificate authorities
+	cassette       *cassette.Recorder // Records or replays interactions
+	err            error              // Invalid option, returned when the client is used
+}
+
+// newClientConfig applies opts over the default settings
+func newClientConfig(opts []ClientOption) clientConfig {
+	cfg := clientConfig{
+		dialTimeout: defaultDialTimeout,
+		userAgent:   defaultUserAgent,
+		apiVersion:  defaultAPIVersion,
+		retry:       DefaultRetryPolicy(),
+		env:         defaultEnvironment(),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.certPool != nil && !canPin(cfg.httpClient) && cfg.err == nil {
+		cfg.err = errUnpinnable
+	}
+	return cfg
+}
+
+// baseUrlOr returns the base URL set with WithBaseURL, or def if none was set
+func (cfg clientConfig) baseUrlOr(def string) string {
+	if cfg.baseUrl != "" {
+		return cfg.baseUrl
+	}
+	return def
+}
+
+// WithHTTPClient makes the client send requests through httpClient instead of
+// one built from WithDialTimeout and WithProxy. The certificate authorities
+// pinned with WithCertPool, WithCertPEM, WithPinnedCA, or by default by
+// OAuthService, still apply: they replace the RootCAs of a copy of its
+// transport, which must then be an *http.Transport (or nil for
+// http.DefaultTransport). Other transports make the client fail with an error
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.httpClient = httpClient
+	}
+}
+
+// WithBaseURL overrides the URL requests are sent to, i.e to point the client
+// at a local stand-in server. For OAuthService it is the URL of the OAuth
+// token endpoint. It takes precedence over the URLs of the Environment
+func WithBaseURL(baseUrl string) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.baseUrl = withTrailingSlash(baseUrl)
+	}
+}
+
+// WithDialTimeout sets how long to wait when connecting to coinbase. It
+// defaults to 2 seconds
+func WithDialTimeout(timeout time.Duration) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.dialTimeout = timeout
+	}
+}
+
+// WithRequestTimeout sets a time limit for each request, including reading
+// the response. There is no limit by default
+func WithRequestTimeout(timeout time.Duration) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.requestTimeout = timeout
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request
+func WithUserAgent(userAgent string) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.userAgent = userAgent
+	}
+}
+
+// WithAPIVersion sets the CB-VERSION header sent by V2Client, the date of the
+// API v2 version the client expects, i.e "2016-02-18". The v1 clients ignore it
+func WithAPIVersion(version string) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.apiVersion = version
+	}
+}
+
+// WithProxy routes requests through the proxy returned by proxy, i.e
+// WithProxy(http.ProxyURL(egressUrl)) or WithProxy(http.ProxyFromEnvironment)
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.proxy = proxy
+	}
+}
+
+// WithRetries sets the retry policy of the client (see RetryPolicy)
+func WithRetries(policy RetryPolicy) ClientOption {
+	policy.RetryableStatus = append([]int(nil), policy.RetryableStatus...)
+	return func(cfg *clientConfig) {
+		cfg.retry = policy
+	}
+}
+
+// WithCertPool pins the certificate authorities trusted to sign the
+// certificates of coinbase. OAuthService pins the embedded coinbase bundle by
+// default, other clients only pin certificates when given this option
+func WithCertPool(pool *x509.CertPool) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.certPool = pool
+	}
+}
+
+// WithCertPEM is like WithCertPool with the certificates given in PEM format
+func WithCertPEM(pemData []byte) ClientOption {
+	return func(cfg *clientConfig) {
+		certs, err := certPoolFromPEM(pemData)
+		if err != nil {
+			cfg.err = err
+			return
+		}
+		cfg.certPool = certs
+	}
+}
+
+// WithPinnedCA pins the coinbase certificate authorities embedded in the
+// library, as OAuthService does by default
+func WithPinnedCA() ClientOption {
+	return func(cfg *clientConfig) {
+		certs, err := coinbaseCertPool()
+		if err != nil {
+			cfg.err = err
+			return
+		}
+		cfg.certPool = certs
+	}
+}
+
+// WithCassette records the interactions of the client with coinbase into the
+// cassette file at path, or replays them without network access, depending on
+// mode (see the cassette package). Credentials and emails are redacted from
+// the cassette
+func WithCassette(path string, mode cassette.Mode) ClientOption {
+	return func(cfg *clientConfig) {
+		recorder, err := cassette.New(path, mode)
+		if err != nil {
+			cfg.err = err
+			return
+		}
+		cfg.cassette = recorder
+	}
+}
+
+// WithCassetteRecorder is like WithCassette with a Recorder shared by several
+// clients, i.e by an OAuthService and the clients of its users
+func WithCassetteRecorder(recorder *cassette.Recorder) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.cassette = recorder
+	}
+}
@@ -0,0 +1,89 @@
+package coinbase
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fabioberger/coinbase-go/cassette"
+)
+
+func TestClientOptions(t *testing.T) {
+	userAgent := ""
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		userAgent = req.Header.Get("User-Agent")
+		if req.URL.Path == "/slow/account/balance" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		w.Write([]byte(`{"amount":"1.00000000","currency":"BTC"}`))
+	}))
+	defer srv.Close()
+
+	c := ApiKeyClient("key", "secret", WithBaseURL(srv.URL), WithUserAgent("MyApp/1.0"))
+	if _, err := c.GetBalance(); err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "ClientOptions", "MyApp/1.0", userAgent)
+
+	c = ApiKeyClient("key", "secret", WithBaseURL(srv.URL+"/slow"), WithRequestTimeout(10*time.Millisecond), WithRetries(NoRetries))
+	if _, err := c.GetBalance(); err == nil {
+		t.Error("ClientOptions Expected the request to time out")
+	}
+}
+
+func TestClientOptionsProxy(t *testing.T) {
+	proxied := ""
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		proxied = req.URL.String()
+		w.Write([]byte(`{"amount":"1.00000000","currency":"BTC"}`))
+	}))
+	defer proxy.Close()
+
+	proxyUrl, _ := url.Parse(proxy.URL)
+	c := ApiKeyClient("key", "secret", WithBaseURL("http://coinbase.invalid/v1"), WithProxy(http.ProxyURL(proxyUrl)))
+	if _, err := c.GetBalance(); err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "ClientOptionsProxy", "http://coinbase.invalid/v1/account/balance", proxied)
+}
+
+func TestClientOptionsCassette(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	params := &TransactionParams{To: "user1@example.com", Amount: MustParseMoney("1.234", "BTC")}
+
+	c := ApiKeyClient(testServer.Key, testServer.Secret, WithBaseURL(testServer.BaseURL()), WithCassette(path, cassette.Record))
+	if _, err := c.SendMoney(params); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	compareBool(t, "ClientOptionsCassette", false, bytes.Contains(data, []byte("user1@example.com")))
+	compareBool(t, "ClientOptionsCassette", false, bytes.Contains(data, []byte(testServer.Key)))
+
+	// Replayed requests do not reach the server
+	sent := len(testServer.Requests())
+	c = ApiKeyClient("key", "secret", WithBaseURL(testServer.BaseURL()), WithCassette(path, cassette.Replay), WithRetries(NoRetries))
+	confirmation, err := c.SendMoney(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "ClientOptionsCassette", "501a1791f8182b2071000087", confirmation.Transaction.Id)
+	compareInt(t, "ClientOptionsCassette", int64(sent), int64(len(testServer.Requests())))
+
+	// Every interaction is replayed once, and unmatched requests fail
+	if _, err := c.SendMoney(params); err == nil {
+		t.Error("ClientOptionsCassette Expected an error replaying an interaction twice")
+	}
+	if _, err := c.GetBalance(); err == nil {
+		t.Error("ClientOptionsCassette Expected an error for an unrecorded request")
+	}
+
+	c = ApiKeyClient("key", "secret", WithCassette(filepath.Join(t.TempDir(), "missing.json"), cassette.Replay))
+	if _, err := c.GetBalance(); err == nil {
+		t.Error("ClientOptionsCassette Expected an error for a missing cassette")
+	}
+}
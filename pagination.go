@@ -0,0 +1,255 @@
+package coinbase
+
+// Pagination includes the iterators used to walk every page of the list endpoints
+// (i.e GetTransactions, GetOrders). Iterators fetch one page at a time as they
+// are advanced and are used as follows:
+//
+//	it := c.TransactionsIter(0)
+//	for it.Next() {
+//		tx := it.Value()
+//	}
+//	if err := it.Err(); err != nil {
+//		log.Fatal(err)
+//	}
+
+// pager keeps track of the position of an iterator in a paginated list. fetch
+// requests the given page and returns the number of items it contains
+type pager struct {
+	fetch    func(page int64) (int, Page, error)
+	limit    int // Maximum number of items to iterate over, 0 for no limit
+	page     int64
+	numPages int64
+	size     int
+	index    int
+	count    int
+	done     bool
+	err      error
+}
+
+// next advances to the next item, fetching the following page when the
+// current one is exhausted
+func (p *pager) next() bool {
+	if p.done || p.err != nil || (p.limit > 0 && p.count >= p.limit) {
+		return false
+	}
+	p.index++
+	for p.index >= p.size {
+		if p.page > 0 && p.page >= p.numPages {
+			p.done = true
+			return false
+		}
+		size, stats, err := p.fetch(p.page + 1)
+		if err != nil {
+			p.err = err
+			return false
+		}
+		p.page++
+		p.numPages = stats.NumPages
+		p.size = size
+		p.index = 0
+		if size == 0 {
+			p.done = true
+			return false
+		}
+	}
+	p.count++
+	return true
+}
+
+// TransactionsIter iterates over all transactions associated with an account
+type TransactionsIter struct {
+	pager
+	items []Transaction
+}
+
+// TransactionsIter returns an iterator over the transactions associated with
+// an account, stopping after limit transactions unless limit is 0
+func (c Client) TransactionsIter(limit int) *TransactionsIter {
+	it := &TransactionsIter{}
+	it.limit = limit
+	it.fetch = func(page int64) (int, Page, error) {
+		txs, err := c.GetTransactions(int(page))
+		if err != nil {
+			return 0, Page{}, err
+		}
+		it.items = txs.Transactions
+		return len(it.items), txs.Page, nil
+	}
+	return it
+}
+
+// Next advances the iterator and reports whether a transaction is available
+func (it *TransactionsIter) Next() bool { return it.next() }
+
+// Value returns the current transaction
+func (it *TransactionsIter) Value() Transaction { return it.items[it.index] }
+
+// Err returns the error that stopped the iteration, if any
+func (it *TransactionsIter) Err() error { return it.err }
+
+// OrdersIter iterates over all orders associated with an account
+type OrdersIter struct {
+	pager
+	items []Order
+}
+
+// OrdersIter returns an iterator over the orders associated with an account,
+// stopping after limit orders unless limit is 0
+func (c Client) OrdersIter(limit int) *OrdersIter {
+	it := &OrdersIter{}
+	it.limit = limit
+	it.fetch = func(page int64) (int, Page, error) {
+		orders, err := c.GetOrders(int(page))
+		if err != nil {
+			return 0, Page{}, err
+		}
+		it.items = orders.Orders
+		return len(it.items), orders.Page, nil
+	}
+	return it
+}
+
+// Next advances the iterator and reports whether an order is available
+func (it *OrdersIter) Next() bool { return it.next() }
+
+// Value returns the current order
+func (it *OrdersIter) Value() Order { return it.items[it.index] }
+
+// Err returns the error that stopped the iteration, if any
+func (it *OrdersIter) Err() error { return it.err }
+
+// TransfersIter iterates over all transfers associated with an account
+type TransfersIter struct {
+	pager
+	items []Transfer
+}
+
+// TransfersIter returns an iterator over the transfers associated with an
+// account, stopping after limit transfers unless limit is 0
+func (c Client) TransfersIter(limit int) *TransfersIter {
+	it := &TransfersIter{}
+	it.limit = limit
+	it.fetch = func(page int64) (int, Page, error) {
+		transfers, err := c.GetTransfers(int(page))
+		if err != nil {
+			return 0, Page{}, err
+		}
+		it.items = transfers.Transfers
+		return len(it.items), transfers.Page, nil
+	}
+	return it
+}
+
+// Next advances the iterator and reports whether a transfer is available
+func (it *TransfersIter) Next() bool { return it.next() }
+
+// Value returns the current transfer
+func (it *TransfersIter) Value() Transfer { return it.items[it.index] }
+
+// Err returns the error that stopped the iteration, if any
+func (it *TransfersIter) Err() error { return it.err }
+
+// AddressesIter iterates over all bitcoin addresses associated with an account
+type AddressesIter struct {
+	pager
+	items []Address
+}
+
+// AddressesIter returns an iterator over the bitcoin addresses matching params,
+// stopping after limit addresses unless limit is 0. params.Limit sets the page
+// size and params.Page is ignored
+func (c Client) AddressesIter(params *AddressesParams, limit int) *AddressesIter {
+	query := AddressesParams{}
+	if params != nil {
+		query = *params
+	}
+	it := &AddressesIter{}
+	it.limit = limit
+	it.fetch = func(page int64) (int, Page, error) {
+		query.Page = page
+		addresses, err := c.GetAllAddresses(&query)
+		if err != nil {
+			return 0, Page{}, err
+		}
+		it.items = addresses.Addresses
+		return len(it.items), addresses.Page, nil
+	}
+	return it
+}
+
+// Next advances the iterator and reports whether an address is available
+func (it *AddressesIter) Next() bool { return it.next() }
+
+// Value returns the current address
+func (it *AddressesIter) Value() Address { return it.items[it.index] }
+
+// Err returns the error that stopped the iteration, if any
+func (it *AddressesIter) Err() error { return it.err }
+
+// ContactsIter iterates over all contacts of a user
+type ContactsIter struct {
+	pager
+	items []Contact
+}
+
+// ContactsIter returns an iterator over the contacts matching params, stopping
+// after limit contacts unless limit is 0. params.Limit sets the page size and
+// params.Page is ignored
+func (c Client) ContactsIter(params *ContactsParams, limit int) *ContactsIter {
+	query := ContactsParams{}
+	if params != nil {
+		query = *params
+	}
+	it := &ContactsIter{}
+	it.limit = limit
+	it.fetch = func(page int64) (int, Page, error) {
+		query.Page = page
+		contacts, err := c.GetContacts(&query)
+		if err != nil {
+			return 0, Page{}, err
+		}
+		it.items = contacts.Contacts
+		return len(it.items), contacts.Page, nil
+	}
+	return it
+}
+
+// Next advances the iterator and reports whether a contact is available
+func (it *ContactsIter) Next() bool { return it.next() }
+
+// Value returns the current contact
+func (it *ContactsIter) Value() Contact { return it.items[it.index] }
+
+// Err returns the error that stopped the iteration, if any
+func (it *ContactsIter) Err() error { return it.err }
+
+// AccountsIter iterates over all accounts of the user
+type AccountsIter struct {
+	pager
+	items []Account
+}
+
+// AccountsIter returns an iterator over the accounts of the user, stopping
+// after limit accounts unless limit is 0
+func (c Client) AccountsIter(limit int) *AccountsIter {
+	it := &AccountsIter{}
+	it.limit = limit
+	it.fetch = func(page int64) (int, Page, error) {
+		accounts, err := c.GetAccounts(&AccountsParams{Page: page})
+		if err != nil {
+			return 0, Page{}, err
+		}
+		it.items = accounts.Accounts
+		return len(it.items), accounts.Page, nil
+	}
+	return it
+}
+
+// Next advances the iterator and reports whether an account is available
+func (it *AccountsIter) Next() bool { return it.next() }
+
+// Value returns the current account
+func (it *AccountsIter) Value() Account { return it.items[it.index] }
+
+// Err returns the error that stopped the iteration, if any
+func (it *AccountsIter) Err() error { return it.err }
@@ -0,0 +1,74 @@
+package coinbase
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Serves three pages of two transactions each and fails on any other page
+func transactionPagesServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		params := map[string]int{}
+		json.NewDecoder(req.Body).Decode(&params)
+		page := params["page"]
+		if page < 1 || page > 3 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"total_count":6,"num_pages":3,"current_page":%d,"transactions":[
+			{"transaction":{"id":"%d-a"}},{"transaction":{"id":"%d-b"}}]}`, page, page, page)
+	}))
+}
+
+func TestTransactionsIter(t *testing.T) {
+	srv := transactionPagesServer()
+	defer srv.Close()
+	c := ApiKeyClient("key", "secret", WithBaseURL(srv.URL))
+
+	ids := []string{}
+	it := c.TransactionsIter(0)
+	for it.Next() {
+		ids = append(ids, it.Value().Id)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "TransactionsIter", "[1-a 1-b 2-a 2-b 3-a 3-b]", fmt.Sprint(ids))
+
+	ids = []string{}
+	it = c.TransactionsIter(3)
+	for it.Next() {
+		ids = append(ids, it.Value().Id)
+	}
+	compareString(t, "TransactionsIter", "[1-a 1-b 2-a]", fmt.Sprint(ids))
+}
+
+func TestPagerStopsOnError(t *testing.T) {
+	p := pager{fetch: func(page int64) (int, Page, error) {
+		if page == 2 {
+			return 0, Page{}, errors.New("boom")
+		}
+		return 1, Page{NumPages: 5}, nil
+	}}
+	compareBool(t, "PagerStopsOnError", true, p.next())
+	compareBool(t, "PagerStopsOnError", false, p.next())
+	compareBool(t, "PagerStopsOnError", false, p.next())
+	compareString(t, "PagerStopsOnError", "boom", fmt.Sprint(p.err))
+}
+
+func TestMockOrdersIter(t *testing.T) {
+	c := initTestClient()
+	it := c.OrdersIter(0)
+	count := 0
+	for it.Next() {
+		count++
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	compareInt(t, "OrdersIter", 1, int64(count))
+}
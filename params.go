@@ -1,5 +1,9 @@
 package coinbase
 
+import (
+	"encoding/json"
+)
+
 // Params includes all the struct parameters that are required for specific API requests
 // By defining a specific param struct, a developer can know which parameters are allowed
 // for a given request. Also included here are the return object structs returned by
@@ -13,6 +17,14 @@ type AddressesParams struct {
 	Query     string `json:"query,omitempty"`
 }
 
+// Parameter Struct for GET /api/v1/accounts Requests. Inactive accounts are
+// only listed when AllAccounts is set
+type AccountsParams struct {
+	Page        int64 `json:"page,omitempty"`
+	Limit       int64 `json:"limit,omitempty"`
+	AllAccounts bool  `json:"all_accounts,omitempty"`
+}
+
 // Parameter Struct for POST /api/v1/account/generate_receive_address Requests
 type AddressParams struct {
 	Label       string `json:"label,omitempty"`
@@ -20,18 +32,181 @@ type AddressParams struct {
 }
 
 // Parameter Struct for POST /api/v1/transactions/(request_money,send_money) Requests
+// Amount may be given in BTC or in any other currency (see GetCurrencies), in
+// which case coinbase converts it to BTC using the current exchange rate.
+// A transaction created with a given Idem is only found among the 100 most
+// recent transactions of the account: a key older than that is submitted again
 type TransactionParams struct {
-	To                string `json:"to,omitempty"`
-	From              string `json:"from,omitempty"`
-	Amount            string `json:"amount,omitempty"`
-	AmountString      string `json:"amount_string,omitempty"`
-	AmountCurrencyIso string `json:"amount_currency_iso,omitempty"`
-	Notes             string `json:"notes,omitempty"`
-	UserFee           string `json:"user_fee,omitempty"`
-	ReferrerId        string `json:"refferer_id,omitempty"`
-	Idem              string `json:"idem,omitempty"`
-	InstantBuy        bool   `json:"instant_buy,omitempty"`
-	OrderId           string `json:"order_id,omitempty"`
+	To         string `json:"to,omitempty"`
+	From       string `json:"from,omitempty"`
+	Amount     Money  `json:"-"`
+	Notes      string `json:"notes,omitempty"`
+	UserFee    Money  `json:"-"` // Must be denominated in BTC
+	ReferrerId string `json:"refferer_id,omitempty"`
+	Idem       string `json:"idem,omitempty"` // Generated if empty (see NewIdempotencyKey)
+	InstantBuy bool   `json:"instant_buy,omitempty"`
+	OrderId    string `json:"order_id,omitempty"`
+}
+
+// MarshalJSON encodes the amounts of TransactionParams as the decimal strings
+// expected by the coinbase API: BTC amounts are sent as "amount" and any other
+// currency as "amount_string" & "amount_currency_iso"
+func (p TransactionParams) MarshalJSON() ([]byte, error) {
+	type params TransactionParams // Prevents infinite recursion into MarshalJSON
+	final := struct {
+		params
+		Amount            string `json:"amount,omitempty"`
+		AmountString      string `json:"amount_string,omitempty"`
+		AmountCurrencyIso string `json:"amount_currency_iso,omitempty"`
+		UserFee           string `json:"user_fee,omitempty"`
+	}{
+		params: params(p),
+	}
+	if p.Amount.Currency == "BTC" {
+		final.Amount = p.Amount.Amount()
+	} else if p.Amount.Currency != "" {
+		final.AmountString = p.Amount.Amount()
+		final.AmountCurrencyIso = p.Amount.Currency
+	}
+	if p.UserFee.Currency != "" {
+		final.UserFee = p.UserFee.Amount()
+	}
+	return json.Marshal(final)
+}
+
+// UnmarshalJSON decodes TransactionParams as encoded by MarshalJSON, i.e to
+// load params persisted by a ProposalStore
+func (p *TransactionParams) UnmarshalJSON(data []byte) error {
+	type params TransactionParams // Prevents infinite recursion into UnmarshalJSON
+	final := struct {
+		*params
+		Amount            string `json:"amount"`
+		AmountString      string `json:"amount_string"`
+		AmountCurrencyIso string `json:"amount_currency_iso"`
+		UserFee           string `json:"user_fee"`
+	}{
+		params: (*params)(p),
+	}
+	if err := json.Unmarshal(data, &final); err != nil {
+		return err
+	}
+	var err error
+	if final.Amount != "" {
+		p.Amount, err = ParseMoney(final.Amount, "BTC")
+	} else if final.AmountString != "" {
+		p.Amount, err = ParseMoney(final.AmountString, final.AmountCurrencyIso)
+	}
+	if err != nil {
+		return err
+	}
+	if final.UserFee != "" {
+		p.UserFee, err = ParseMoney(final.UserFee, "BTC")
+	}
+	return err
+}
+
+// Parameter Struct for POST /api/v1/orders Requests, creating an order without
+// a pre-made button. Price may be given in BTC or in any other currency
+type OrderParams struct {
+	Name         string `json:"name"`
+	Price        Money  `json:"-"`
+	Description  string `json:"description,omitempty"`
+	Custom       string `json:"custom,omitempty"`
+	CustomSecure bool   `json:"custom_secure,omitempty"`
+	CallbackUrl  string `json:"callback_url,omitempty"`
+	SuccessUrl   string `json:"success_url,omitempty"`
+	CancelUrl    string `json:"cancel_url,omitempty"`
+	InfoUrl      string `json:"info_url,omitempty"`
+	AutoRedirect bool   `json:"auto_redirect,omitempty"`
+}
+
+// MarshalJSON encodes the price of OrderParams as "price_string" &
+// "price_currency_iso"
+func (p OrderParams) MarshalJSON() ([]byte, error) {
+	type params OrderParams // Prevents infinite recursion into MarshalJSON
+	final := struct {
+		params
+		PriceString      string `json:"price_string,omitempty"`
+		PriceCurrencyIso string `json:"price_currency_iso,omitempty"`
+	}{
+		params:           params(p),
+		PriceCurrencyIso: p.Price.Currency,
+	}
+	if p.Price.Currency != "" {
+		final.PriceString = p.Price.Amount()
+	}
+	return json.Marshal(final)
+}
+
+// Parameter Struct for POST /api/v1/orders/:id/refund Requests. RefundIsoCode
+// selects whether the BTC amount paid ("BTC") or its value at the time of the
+// order ("USD" or the native currency of the order) is refunded
+type RefundOrderParams struct {
+	RefundIsoCode         string `json:"refund_iso_code"`
+	MispaymentId          string `json:"mispayment_id,omitempty"`           // Refunds a mispayment instead of the order
+	ExternalRefundAddress string `json:"external_refund_address,omitempty"` // Overrides the refund address of the customer
+}
+
+// Parameter Struct for POST /api/v1/buys Requests. Amount may be given in BTC
+// or in the currency of the payment method, i.e USD. Unless Commit is set, the
+// buy is only a quote: the returned transfer holds its fees and total and
+// must be executed with CommitTransfer before it expires
+type BuyParams struct {
+	Amount               Money  `json:"-"`
+	AgreeBtcAmountVaries bool   `json:"agree_btc_amount_varies,omitempty"`
+	Commit               bool   `json:"commit"`
+	PaymentMethodId      string `json:"payment_method_id,omitempty"` // The default payment method if empty
+	Idem                 string `json:"idem,omitempty"`              // Generated if empty (see NewIdempotencyKey)
+}
+
+// MarshalJSON encodes the amount of BuyParams as "qty" & "currency"
+func (p BuyParams) MarshalJSON() ([]byte, error) {
+	type params BuyParams // Prevents infinite recursion into MarshalJSON
+	return json.Marshal(struct {
+		params
+		quantity
+	}{params(p), newQuantity(p.Amount)})
+}
+
+func (p *BuyParams) idempotencyKey() string {
+	return p.Idem
+}
+
+// Parameter Struct for POST /api/v1/sells Requests. As with BuyParams, the
+// sell is only a quote unless Commit is set
+type SellParams struct {
+	Amount          Money  `json:"-"`
+	Commit          bool   `json:"commit"`
+	PaymentMethodId string `json:"payment_method_id,omitempty"` // The default payment method if empty
+	Idem            string `json:"idem,omitempty"`              // Generated if empty (see NewIdempotencyKey)
+}
+
+// MarshalJSON encodes the amount of SellParams as "qty" & "currency"
+func (p SellParams) MarshalJSON() ([]byte, error) {
+	type params SellParams // Prevents infinite recursion into MarshalJSON
+	return json.Marshal(struct {
+		params
+		quantity
+	}{params(p), newQuantity(p.Amount)})
+}
+
+func (p *SellParams) idempotencyKey() string {
+	return p.Idem
+}
+
+// quantity is the amount of a buy or sell as expected by the coinbase API: a
+// BTC amount is sent as "qty" alone
+type quantity struct {
+	Qty      string `json:"qty"`
+	Currency string `json:"currency,omitempty"`
+}
+
+func newQuantity(amount Money) quantity {
+	q := quantity{Qty: amount.Amount()}
+	if amount.Currency != "BTC" {
+		q.Currency = amount.Currency
+	}
+	return q
 }
 
 // Parameter Struct for GET /api/v1/contacts Requests
@@ -41,41 +216,50 @@ type ContactsParams struct {
 	Query string `json:"query,omitempty"`
 }
 
-// The OAuth Tokens Struct returned from OAuth Authentication
-type oauthTokens struct {
-	AccessToken  string
-	RefreshToken string
-	ExpireTime   int64
+// The OAuth Tokens Struct returned from OAuth Authentication. ExpireTime is
+// the Unix time at which the access token expires
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpireTime   int64  `json:"expire_time"`
 }
 
 // The return response from SendMoney, RequestMoney, CompleteRequest
-type transactionConfirmation struct {
-	Transaction transaction
-	Transfer    transfer
+type TransactionConfirmation struct {
+	Transaction Transaction
+	Transfer    Transfer
+	Idem        string // Idempotency key of the request, generated if none was given
 }
 
 // The return response from GetAllAddresses
-type addresses struct {
-	paginationStats
-	Addresses []address
+type Addresses struct {
+	Page
+	Addresses []Address
 }
 
 // The structure for one returned address from GetAllAddresses
-type address struct {
+type Address struct {
 	Address     string `json:"address,omitempty"`
 	CallbackUrl string `json:"callback_url,omitempty"`
 	Label       string `json:"label,omitempty"`
-	CreatedAt   string `json:"created_at,omitempty"`
+	CreatedAt   Time   `json:"created_at,omitempty"`
 }
 
 // The sub-structure of a response denominating a currency
-type currency struct {
+type Currency struct {
 	Name string `json:"name,omitempty"`
 	Iso  string `json:"iso,omitempty"`
 }
 
+// The return response from GetContacts
+type Contacts struct {
+	Page
+	Contacts []Contact `json:"contacts,omitempty"`
+	Emails   []string  `json:"emails,omitempty"` // Add for convenience
+}
+
 // The sub-structure of a response denominating a contact
-type contact struct {
+type Contact struct {
 	Contact struct {
 		Email string `json:"email,omitempty"`
 	} `json:"contact,omitempty"`
@@ -111,29 +295,47 @@ type Button struct {
 	Price4              string `json:"price4,omitempty"`
 	Price5              string `json:"price5,omitempty"`
 	Code                string `json:"code,omitempty"`
-	Price               fee    `json:"price,omitempty"`
+	Price               Money  `json:"price,omitempty"`
 	Id                  string `json:"id,omitempty"`
 	EmbedHtml           string `json:"embed_html"` //Added embed_html for convenience
 }
 
+// The return response from GetAccounts
+type Accounts struct {
+	Page
+	Accounts []Account `json:"accounts"`
+}
+
+// The return response from CreateAccount and RenameAccount, an account (or
+// wallet) of the user
+type Account struct {
+	Id            string `json:"id,omitempty"`
+	Name          string `json:"name,omitempty"`
+	Balance       Money  `json:"balance,omitempty"`
+	NativeBalance Money  `json:"native_balance,omitempty"`
+	CreatedAt     Time   `json:"created_at,omitempty"`
+	Primary       bool   `json:"primary,omitempty"`
+	Active        bool   `json:"active,omitempty"`
+}
+
 // The return response from GetUser and CreateUser
-type user struct {
+type User struct {
 	Id             string   `json:"id,omitempty"`
 	Name           string   `json:"name,omitempty"`
 	Email          string   `json:"email,omitempty"`
 	ReceiveAddress string   `json:"receive_address,omitempty"`
 	TimeZone       string   `json:"timezone,omitempty"`
 	NativeCurrency string   `json:"native_currency,omitempty"`
-	Balance        amount   `json:"balance,omitempty"`
-	Merchant       merchant `json:"merchant,omitempty"`
+	Balance        Money    `json:"balance,omitempty"`
+	Merchant       Merchant `json:"merchant,omitempty"`
 	BuyLevel       int64    `json:"buy_level,omitempty"`
 	SellLevel      int64    `json:"sell_level,omitempty"`
-	BuyLimit       amount   `json:"buy_limit,omitempty"`
-	SellLimit      amount   `json:"sell_limit,omitempty"`
+	BuyLimit       Money    `json:"buy_limit,omitempty"`
+	SellLimit      Money    `json:"sell_limit,omitempty"`
 }
 
 // The sub-structure of a response denominating a merchant
-type merchant struct {
+type Merchant struct {
 	CompanyName string `json:"company_name,omitempty"`
 	Logo        struct {
 		Small  string `json:"small,omitempty"`
@@ -151,78 +353,91 @@ type oauth struct {
 	Scope        string `json:"scope,omitempty"`
 }
 
-// The sub-structure of a response denominating pagination stats
-type paginationStats struct {
+// The return response from GetBuyPrice and GetSellPrice
+type Prices struct {
+	Subtotal Money  `json:"subtotal,omitempty"`
+	Fees     []Fees `json:"fees,omitempty"`
+	Total    Money  `json:"total,omitempty"`
+}
+
+// Page holds the pagination stats of the responses of list endpoints
+type Page struct {
 	TotalCount  int64 `json:"total_count,omitempty"`
 	NumPages    int64 `json:"num_pages,omitempty"`
 	CurrentPage int64 `json:"current_page,omitempty"`
 }
 
 // The return response from GetTransfers
-type transfers struct {
-	paginationStats
-	Transfers []transfer
+type Transfers struct {
+	Page
+	Transfers []Transfer
 }
 
 // The sub-structure of a response denominating a transfer
-type transfer struct {
+type Transfer struct {
 	Id            string `json:"id,omitempty"`
 	Type          string `json:"type,omitempty"`
 	Code          string `json:"code,omitempty"`
-	CreatedAt     string `json:"created_at,omitempty"`
-	Fees          fees   `json:"fees,omitempty"`
+	CreatedAt     Time   `json:"created_at,omitempty"`
+	Fees          Fees   `json:"fees,omitempty"`
 	Status        string `json:"status,omitempty"`
-	PayoutDate    string `json:"payout_date,omitempty"`
-	Btc           amount `json:"btc,omitempty"`
-	Subtotal      amount `json:"subtotal,omitempty"`
-	Total         amount `json:"total,omitempty"`
+	PayoutDate    Time   `json:"payout_date,omitempty"`
+	Btc           Money  `json:"btc,omitempty"`
+	Subtotal      Money  `json:"subtotal,omitempty"`
+	Total         Money  `json:"total,omitempty"`
 	Description   string `json:"description,omitempty"`
 	TransactionId string `json:"transaction_id,omitempty"`
+	Idem          string `json:"idem,omitempty"` // Idempotency key of the request that created the transfer
 }
 
-// The sub-structure of a response denominating an amount
-type amount struct {
-	Amount   string `json:"amount,omitempty"`
-	Currency string `json:"currency,omitempty"`
+// The return response from GetPaymentMethods
+type PaymentMethods struct {
+	PaymentMethods []PaymentMethod
+	DefaultBuy     string // ID of the payment method buys use by default
+	DefaultSell    string // ID of the payment method sells use by default
 }
 
-// The sub-structure of a response denominating a fee
-type fee struct {
-	Cents       float64 `json:"cents,omitempty"`
-	CurrencyIso string  `json:"currency_iso,omitempty"`
+// The sub-structure of a response denominating a payment method, i.e a bank
+// account
+type PaymentMethod struct {
+	Id       string `json:"id,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Currency string `json:"currency,omitempty"`
+	CanBuy   bool   `json:"can_buy,omitempty"`
+	CanSell  bool   `json:"can_sell,omitempty"`
 }
 
 // The sub-structure of a response denominating fees
-type fees struct {
-	Coinbase fee `json:"coinbase,omitempty"`
-	Bank     fee `json:"bank,omitempty"`
+type Fees struct {
+	Coinbase Money `json:"coinbase,omitempty"`
+	Bank     Money `json:"bank,omitempty"`
 }
 
 // The sub-structure of a response denominating a transaction actor
-type transactionActor struct {
+type TransactionActor struct {
 	Id    string `json:"id,omitempty"`
 	Name  string `json:"name,omitempty"`
 	Email string `json:"email,omitempty"`
 }
 
 // The return response from GetTransactions
-type transactions struct {
-	paginationStats
-	Transactions []transaction
+type Transactions struct {
+	Page
+	Transactions []Transaction
 }
 
 // The sub-structure of a response denominating a transaction
-type transaction struct {
+type Transaction struct {
 	Id                 string           `json:"id,omitempty"`
-	CreateAt           string           `json:"create_at,omitempty"`
+	CreatedAt          Time             `json:"created_at,omitempty"`
 	Hsh                string           `json:"hsh,omitempty"`
 	Notes              string           `json:"notes,omitempty"`
 	Idem               string           `json:"idem,omitempty"`
-	Amount             amount           `json:"amount,omitempty"`
+	Amount             Money            `json:"amount,omitempty"`
 	Request            bool             `json:"request,omitempty"`
 	Status             string           `json:"status,omitempty"`
-	Sender             transactionActor `json:"sender,omitempty"`
-	Recipient          transactionActor `json:"recipient,omitempty"`
+	Sender             TransactionActor `json:"sender,omitempty"`
+	Recipient          TransactionActor `json:"recipient,omitempty"`
 	RecipientAddress   string           `json:"recipient_address,omitempty"`
 	Type               string           `json:"type,omitempty"`
 	Signed             bool             `json:"signed,omitempty"`
@@ -234,20 +449,35 @@ type transaction struct {
 }
 
 // The return response from GetOrders
-type orders struct {
-	paginationStats
-	Orders []order
+type Orders struct {
+	Page
+	Orders []Order
 }
 
 // The sub-structure of a response denominating an order
-type order struct {
+type Order struct {
 	Id             string      `json:"id,omitempty"`
-	CreatedAt      string      `json:"created_at,omitempty"`
+	CreatedAt      Time        `json:"created_at,omitempty"`
 	Status         string      `json:"status,omitempty"`
-	TotalBtc       fee         `json:"total_btc,omitempty"`
-	TotalNative    fee         `json:"total_native,omitempty"`
+	TotalBtc       Money       `json:"total_btc,omitempty"`
+	TotalNative    Money       `json:"total_native,omitempty"`
 	Custom         string      `json:"custom,omitempty"`
 	ReceiveAddress string      `json:"receive_address,omitempty"`
 	Button         Button      `json:"button,omitempty"`
-	Transaction    transaction `json:"transaction,omitempty"`
+	Transaction    Transaction `json:"transaction,omitempty"`
+
+	MispaidBtc        Money        `json:"mispaid_btc,omitempty"`
+	MispaidNative     Money        `json:"mispaid_native,omitempty"`
+	Mispayments       []Mispayment `json:"mispayments,omitempty"`
+	RefundAddress     string       `json:"refund_address,omitempty"`
+	RefundTransaction Transaction  `json:"refund_transaction,omitempty"`
+}
+
+// The sub-structure of an order denominating a payment of the wrong amount,
+// refunded with RefundOrder
+type Mispayment struct {
+	Id           string `json:"id,omitempty"`
+	CreatedAt    Time   `json:"created_at,omitempty"`
+	AmountBtc    Money  `json:"amount_btc,omitempty"`
+	AmountNative Money  `json:"amount_native,omitempty"`
 }
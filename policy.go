@@ -0,0 +1,404 @@
+package coinbase
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PolicyClient wraps a Client and checks the calls moving money out of the
+// account (SendMoney, Buy, Sell and CompleteRequest) against a Policy before
+// making them, i.e
+//
+//	p, err := coinbase.NewPolicyClient(c, coinbase.Policy{
+//		MaxPerTransaction: coinbase.MustParseMoney("1000", "USD"),
+//		DailyLimit:        coinbase.MustParseMoney("5000", "USD"),
+//		DeniedRecipients:  []string{"1BadAddress..."},
+//	}, nil, log.New(os.Stderr, "", log.LstdFlags))
+//	confirmation, err := p.SendMoney(params)
+//	if coinbase.IsPolicyViolation(err) {
+//		// The send was refused and logged
+//	}
+//
+// Calls are checked and made one at a time, so that concurrent calls cannot
+// exceed the velocity limits together. Amounts in other currencies than the
+// limits are converted at the current exchange rate
+type PolicyClient struct {
+	client Client
+	policy Policy
+	ledger SpendingLedger
+	logger PolicyLogger
+	mu     sync.Mutex
+	now    func() time.Time
+}
+
+// Policy holds the spending rules enforced by PolicyClient. The zero value of
+// a field disables its rule. The limits must be in the same currency
+type Policy struct {
+	MaxPerTransaction Money          // Largest amount of a single call
+	DailyLimit        Money          // Largest total over the last 24 hours
+	WeeklyLimit       Money          // Largest total over the last 7 days
+	AllowedRecipients []string       // Bitcoin addresses or emails money may be sent to
+	DeniedRecipients  []string       // Bitcoin addresses or emails money may never be sent to
+	AllowedCurrencies []string       // Currencies amounts may be given in, i.e "BTC"
+	BusinessHours     *BusinessHours // When money may be moved
+}
+
+// BusinessHours is a weekly window during which money may be moved, i.e
+// &BusinessHours{Start: 9 * time.Hour, End: 17 * time.Hour} for 9am to 5pm
+// UTC on weekdays
+type BusinessHours struct {
+	Location *time.Location // UTC if nil
+	Weekdays []time.Weekday // Monday to Friday if empty
+	Start    time.Duration  // Time of the day the window opens
+	End      time.Duration  // Time of the day the window closes
+}
+
+// Rules of a Policy, as reported by PolicyViolation
+const (
+	RuleMaxPerTransaction = "max_per_transaction"
+	RuleDailyLimit        = "daily_limit"
+	RuleWeeklyLimit       = "weekly_limit"
+	RuleRecipient         = "recipient"
+	RuleCurrency          = "currency"
+	RuleBusinessHours     = "business_hours"
+)
+
+// PolicyViolation is the error returned by PolicyClient when a call breaks
+// the policy. The call is not made
+type PolicyViolation struct {
+	Rule   string      // One of the Rule constants
+	Reason string      // i.e "1500.00 USD exceeds the limit of 1000.00 USD per transaction"
+	Method string      // SendMoney, Buy, Sell or CompleteRequest
+	Params interface{} // *TransactionParams, *BuyParams, *SellParams, or the ID of the request
+}
+
+func (v *PolicyViolation) Error() string {
+	return fmt.Sprintf("%s refused by the spending policy: %s", v.Method, v.Reason)
+}
+
+// IsPolicyViolation reports whether err is a PolicyViolation
+func IsPolicyViolation(err error) bool {
+	var violation *PolicyViolation
+	return errors.As(err, &violation)
+}
+
+// PolicyLogger receives the policy violations. *log.Logger implements it
+type PolicyLogger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Spend is an amount moved through a PolicyClient, in the currency of the
+// limits of the policy
+type Spend struct {
+	At        time.Time
+	Amount    Money
+	Reference string // Idempotency key of the call, or ID of the completed request
+}
+
+// SpendingLedger records the amounts moved through a PolicyClient to enforce
+// the daily and weekly limits. Use a persistent ledger for the limits to hold
+// across restarts. Ledgers must be safe for concurrent use
+type SpendingLedger interface {
+	Record(spend Spend) error
+	// Since returns the spends made since t. PolicyClient never asks for
+	// older spends afterwards, so they may be discarded
+	Since(t time.Time) ([]Spend, error)
+}
+
+// MemoryLedger is a SpendingLedger keeping spends in memory
+type MemoryLedger struct {
+	mu     sync.Mutex
+	spends []Spend
+}
+
+// NewMemoryLedger instantiates an empty MemoryLedger
+func NewMemoryLedger() *MemoryLedger {
+	return &MemoryLedger{}
+}
+
+// Record appends spend to the ledger
+func (l *MemoryLedger) Record(spend Spend) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.spends = append(l.spends, spend)
+	return nil
+}
+
+// Since returns the spends made since t and discards the older ones
+func (l *MemoryLedger) Since(t time.Time) ([]Spend, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	recent := []Spend{}
+	for _, spend := range l.spends {
+		if !spend.At.Before(t) {
+			recent = append(recent, spend)
+		}
+	}
+	l.spends = recent
+	return append([]Spend(nil), recent...), nil
+}
+
+// NewPolicyClient wraps c with policy. Spends are recorded in ledger, a
+// MemoryLedger if nil, and violations are logged to logger unless it is nil
+func NewPolicyClient(c Client, policy Policy, ledger SpendingLedger, logger PolicyLogger) (*PolicyClient, error) {
+	currency := ""
+	for _, limit := range []Money{policy.MaxPerTransaction, policy.DailyLimit, policy.WeeklyLimit} {
+		if limit.Currency == "" {
+			continue
+		}
+		if currency != "" && !strings.EqualFold(limit.Currency, currency) {
+			return nil, fmt.Errorf("The limits of the policy are in different currencies: %s and %s", currency, limit.Currency)
+		}
+		currency = limit.Currency
+	}
+	if h := policy.BusinessHours; h != nil && (h.Start < 0 || h.End > 24*time.Hour || h.Start >= h.End) {
+		return nil, errors.New("The business hours must start before they end, within a day")
+	}
+	if ledger == nil {
+		ledger = NewMemoryLedger()
+	}
+	return &PolicyClient{
+		client: c,
+		policy: policy,
+		ledger: ledger,
+		logger: logger,
+		now:    time.Now,
+	}, nil
+}
+
+// Client returns the wrapped client, i.e to make calls not moving money
+func (p *PolicyClient) Client() Client {
+	return p.client
+}
+
+// SendMoney checks the send against the policy, then sends it
+func (p *PolicyClient) SendMoney(params *TransactionParams) (*TransactionConfirmation, error) {
+	finalParams := TransactionParams{}
+	if params != nil {
+		finalParams = *params
+	}
+	if finalParams.Idem == "" {
+		// Set the key beforehand so that it is recorded along with the spend
+		finalParams.Idem = NewIdempotencyKey()
+	}
+	var confirmation *TransactionConfirmation
+	err := p.guard("SendMoney", &finalParams, finalParams.Amount, finalParams.To, finalParams.Idem, func() error {
+		var err error
+		confirmation, err = p.client.SendMoney(&finalParams)
+		return err
+	})
+	return confirmation, err
+}
+
+// Buy checks the buy against the policy, then makes it (see Client.Buy)
+func (p *PolicyClient) Buy(amount Money, agreeBtcAmountVaries bool) (*Transfer, error) {
+	params := &BuyParams{Amount: amount, AgreeBtcAmountVaries: agreeBtcAmountVaries, Commit: true, Idem: NewIdempotencyKey()}
+	var transfer *Transfer
+	err := p.guard("Buy", params, amount, "", params.Idem, func() error {
+		var err error
+		transfer, err = p.client.CreateBuy(params)
+		return err
+	})
+	return transfer, err
+}
+
+// Sell checks the sell against the policy, then makes it (see Client.Sell)
+func (p *PolicyClient) Sell(amount Money) (*Transfer, error) {
+	params := &SellParams{Amount: amount, Commit: true, Idem: NewIdempotencyKey()}
+	var transfer *Transfer
+	err := p.guard("Sell", params, amount, "", params.Idem, func() error {
+		var err error
+		transfer, err = p.client.CreateSell(params)
+		return err
+	})
+	return transfer, err
+}
+
+// CompleteRequest checks the payment of the money request referenced by id
+// against the policy, then completes it
+func (p *PolicyClient) CompleteRequest(id string) (*TransactionConfirmation, error) {
+	request, err := p.client.GetTransaction(id)
+	if err != nil {
+		return nil, err
+	}
+	recipient := request.Recipient.Email
+	if recipient == "" {
+		recipient = request.RecipientAddress
+	}
+	var confirmation *TransactionConfirmation
+	err = p.guard("CompleteRequest", id, request.Amount.Abs(), recipient, id, func() error {
+		var err error
+		confirmation, err = p.client.CompleteRequest(id)
+		return err
+	})
+	return confirmation, err
+}
+
+// guard checks a call moving amount to recipient against the policy, makes it
+// and records the spend, unless the call was rejected. reference identifies
+// repeated calls, which are only counted once
+func (p *PolicyClient) guard(method string, params interface{}, amount Money, recipient string, reference string, call func() error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	spent, recorded, err := p.check(now, amount, recipient, reference)
+	if err != nil {
+		var violation *PolicyViolation
+		if errors.As(err, &violation) {
+			violation.Method = method
+			violation.Params = params
+			p.log(violation)
+		}
+		return err
+	}
+	err = call()
+	if recorded || rejected(err) {
+		return err
+	}
+	// The money may have moved even if the call failed, i.e when the response
+	// was lost, so the spend is recorded unless coinbase rejected the call
+	if recordErr := p.ledger.Record(Spend{At: now, Amount: spent, Reference: reference}); err == nil {
+		err = recordErr
+	}
+	return err
+}
+
+// rejected reports whether err proves that a call moved no money: coinbase
+// refused it with a 4xx status or an error in its response, or it was not sent
+func rejected(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode < 500
+	}
+	return errors.Is(err, ErrInvalidRecipient)
+}
+
+// check returns the amount spent by a call, in the currency of the limits,
+// and whether a call with the same reference was recorded already. It returns
+// a *PolicyViolation if the call breaks the policy
+func (p *PolicyClient) check(now time.Time, amount Money, recipient string, reference string) (Money, bool, error) {
+	if len(p.policy.AllowedCurrencies) > 0 && !containsFold(p.policy.AllowedCurrencies, amount.Currency) {
+		return Money{}, false, &PolicyViolation{Rule: RuleCurrency, Reason: "amounts in " + amount.Currency + " are not allowed"}
+	}
+	if recipient != "" && containsFold(p.policy.DeniedRecipients, recipient) {
+		return Money{}, false, &PolicyViolation{Rule: RuleRecipient, Reason: recipient + " is a denied recipient"}
+	}
+	if recipient != "" && len(p.policy.AllowedRecipients) > 0 && !containsFold(p.policy.AllowedRecipients, recipient) {
+		return Money{}, false, &PolicyViolation{Rule: RuleRecipient, Reason: recipient + " is not an allowed recipient"}
+	}
+	if h := p.policy.BusinessHours; h != nil && !h.contains(now) {
+		return Money{}, false, &PolicyViolation{Rule: RuleBusinessHours, Reason: "money may only be moved during business hours"}
+	}
+	spent, err := p.convert(amount.Abs())
+	if err != nil {
+		return Money{}, false, err
+	}
+	if limit := p.policy.MaxPerTransaction; limit.Currency != "" {
+		if cmp, _ := spent.Cmp(limit); cmp > 0 {
+			return Money{}, false, &PolicyViolation{Rule: RuleMaxPerTransaction, Reason: fmt.Sprintf("%s exceeds the limit of %s per transaction", spent, limit)}
+		}
+	}
+	if p.policy.DailyLimit.Currency == "" && p.policy.WeeklyLimit.Currency == "" {
+		return spent, false, nil
+	}
+	since := now.Add(-24 * time.Hour)
+	if p.policy.WeeklyLimit.Currency != "" {
+		since = now.Add(-7 * 24 * time.Hour)
+	}
+	spends, err := p.ledger.Since(since)
+	if err != nil {
+		return Money{}, false, err
+	}
+	daily, weekly := spent, spent
+	for _, s := range spends {
+		if reference != "" && s.Reference == reference {
+			return spent, true, nil // Repeated call, i.e with the same idempotency key
+		}
+		if weekly, err = weekly.Add(s.Amount); err != nil {
+			return Money{}, false, err
+		}
+		if s.At.After(now.Add(-24 * time.Hour)) {
+			if daily, err = daily.Add(s.Amount); err != nil {
+				return Money{}, false, err
+			}
+		}
+	}
+	if limit := p.policy.DailyLimit; limit.Currency != "" {
+		if cmp, _ := daily.Cmp(limit); cmp > 0 {
+			return Money{}, false, &PolicyViolation{Rule: RuleDailyLimit, Reason: fmt.Sprintf("%s over 24 hours exceeds the daily limit of %s", daily, limit)}
+		}
+	}
+	if limit := p.policy.WeeklyLimit; limit.Currency != "" {
+		if cmp, _ := weekly.Cmp(limit); cmp > 0 {
+			return Money{}, false, &PolicyViolation{Rule: RuleWeeklyLimit, Reason: fmt.Sprintf("%s over 7 days exceeds the weekly limit of %s", weekly, limit)}
+		}
+	}
+	return spent, false, nil
+}
+
+// convert converts amount into the currency of the limits of the policy
+func (p *PolicyClient) convert(amount Money) (Money, error) {
+	currency := ""
+	for _, limit := range []Money{p.policy.MaxPerTransaction, p.policy.DailyLimit, p.policy.WeeklyLimit} {
+		if limit.Currency != "" {
+			currency = limit.Currency
+		}
+	}
+	if currency == "" {
+		return amount, nil
+	}
+	return convertAt(p.client, amount, currency)
+}
+
+// convertAt converts amount into currency at the current exchange rate
+func convertAt(c Client, amount Money, currency string) (Money, error) {
+	if strings.EqualFold(amount.Currency, currency) {
+		return amount, nil
+	}
+	rate, err := c.GetExchangeRate(amount.Currency, currency)
+	if err != nil {
+		return Money{}, err
+	}
+	return amount.Convert(rate, currency)
+}
+
+func (p *PolicyClient) log(violation *PolicyViolation) {
+	if p.logger == nil {
+		return
+	}
+	params, _ := json.Marshal(violation.Params)
+	p.logger.Printf("coinbase: policy violation (%s) in %s: %s, params %s", violation.Rule, violation.Method, violation.Reason, params)
+}
+
+// contains reports whether t falls within the business hours
+func (h *BusinessHours) contains(t time.Time) bool {
+	location := h.Location
+	if location == nil {
+		location = time.UTC
+	}
+	t = t.In(location)
+	weekdays := h.Weekdays
+	if len(weekdays) == 0 {
+		weekdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	}
+	open := false
+	for _, day := range weekdays {
+		open = open || t.Weekday() == day
+	}
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, location)
+	elapsed := t.Sub(midnight)
+	return open && elapsed >= h.Start && elapsed < h.End
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(strings.TrimSpace(item), s) {
+			return true
+		}
+	}
+	return false
+}
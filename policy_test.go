@@ -0,0 +1,150 @@
+package coinbase
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPolicyLimits(t *testing.T) {
+	logs := &bytes.Buffer{}
+	p, err := NewPolicyClient(initTestClient(), Policy{
+		MaxPerTransaction: MustParseMoney("1000", "USD"),
+		DailyLimit:        MustParseMoney("1500", "USD"),
+		AllowedCurrencies: []string{"BTC", "USD"},
+	}, nil, log.New(logs, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2014, 5, 12, 10, 0, 0, 0, time.UTC)
+	p.now = func() time.Time { return now }
+
+	// 2 BTC are worth 773.06 USD at the fixture exchange rate
+	params := &TransactionParams{To: "user1@example.com", Amount: MustParseMoney("2", "BTC")}
+	if _, err := p.SendMoney(params); err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "PolicyLimits", "", params.Idem)
+
+	_, err = p.SendMoney(&TransactionParams{To: "user1@example.com", Amount: MustParseMoney("3", "BTC")})
+	var violation *PolicyViolation
+	if !errors.As(err, &violation) {
+		t.Fatalf("PolicyLimits Expected a policy violation but got '%v'", err)
+	}
+	compareString(t, "PolicyLimits", RuleMaxPerTransaction, violation.Rule)
+	compareString(t, "PolicyLimits", "SendMoney", violation.Method)
+	if !strings.Contains(logs.String(), `"to":"user1@example.com"`) {
+		t.Errorf("PolicyLimits Expected the params to be logged but got '%s'", logs)
+	}
+
+	_, err = p.Sell(MustParseMoney("2", "BTC"))
+	if !errors.As(err, &violation) || violation.Rule != RuleDailyLimit {
+		t.Fatalf("PolicyLimits Expected the daily limit to be exceeded but got '%v'", err)
+	}
+
+	_, err = p.SendMoney(&TransactionParams{To: "user1@example.com", Amount: MustParseMoney("1", "EUR")})
+	if !errors.As(err, &violation) || violation.Rule != RuleCurrency {
+		t.Errorf("PolicyLimits Expected EUR to be refused but got '%v'", err)
+	}
+
+	// The same idempotency key is only counted once
+	if _, err := p.SendMoney(&TransactionParams{To: "user1@example.com", Amount: MustParseMoney("700", "USD"), Idem: "key"}); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(25 * time.Hour)
+	if _, err := p.SendMoney(&TransactionParams{To: "user1@example.com", Amount: MustParseMoney("700", "USD"), Idem: "key"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.SendMoney(&TransactionParams{To: "user1@example.com", Amount: MustParseMoney("700", "USD")}); err != nil {
+		t.Errorf("PolicyLimits Expected the spends of the previous day to be ignored but got '%v'", err)
+	}
+}
+
+func TestPolicyRecipients(t *testing.T) {
+	p, err := NewPolicyClient(initTestClient(), Policy{
+		DeniedRecipients: []string{"1BadAddress"},
+		BusinessHours:    &BusinessHours{Start: 9 * time.Hour, End: 17 * time.Hour},
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.now = func() time.Time { return time.Date(2014, 5, 12, 10, 0, 0, 0, time.UTC) }
+
+	_, err = p.SendMoney(&TransactionParams{To: "1BadAddress", Amount: MustParseMoney("1", "BTC")})
+	if !IsPolicyViolation(err) {
+		t.Errorf("PolicyRecipients Expected a denied recipient to be refused but got '%v'", err)
+	}
+	if _, err := p.SendMoney(&TransactionParams{To: "User1@Example.com", Amount: MustParseMoney("1", "BTC")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Saturday
+	p.now = func() time.Time { return time.Date(2014, 5, 17, 10, 0, 0, 0, time.UTC) }
+	_, err = p.SendMoney(&TransactionParams{To: "user1@example.com", Amount: MustParseMoney("1", "BTC")})
+	var violation *PolicyViolation
+	if !errors.As(err, &violation) || violation.Rule != RuleBusinessHours {
+		t.Errorf("PolicyRecipients Expected a send on a Saturday to be refused but got '%v'", err)
+	}
+
+	p, err = NewPolicyClient(initTestClient(), Policy{AllowedRecipients: []string{"user1@example.com"}}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = p.SendMoney(&TransactionParams{To: "user2@example.com", Amount: MustParseMoney("1", "BTC")})
+	if !IsPolicyViolation(err) {
+		t.Errorf("PolicyRecipients Expected a recipient not allowed to be refused but got '%v'", err)
+	}
+
+	_, err = NewPolicyClient(initTestClient(), Policy{
+		MaxPerTransaction: MustParseMoney("1", "BTC"),
+		DailyLimit:        MustParseMoney("1000", "USD"),
+	}, nil, nil)
+	if err == nil {
+		t.Error("PolicyRecipients Expected limits in different currencies to be rejected")
+	}
+}
+
+func TestPolicyFailedCalls(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, "/send_money") {
+			w.WriteHeader(status)
+			w.Write([]byte(`{"success":false,"errors":["Failed"]}`))
+			return
+		}
+		w.Write([]byte(`{"transactions":[]}`))
+	}))
+	defer srv.Close()
+	ledger := NewMemoryLedger()
+	c := ApiKeyClient("key", "secret", WithBaseURL(srv.URL+"/"), WithRetries(NoRetries))
+	p, err := NewPolicyClient(c, Policy{DailyLimit: MustParseMoney("1000", "USD")}, ledger, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The send may have gone through, so it counts towards the limits
+	if _, err := p.SendMoney(&TransactionParams{To: "user1@example.com", Amount: MustParseMoney("700", "USD")}); err == nil {
+		t.Fatal("PolicyFailedCalls Expected an error")
+	}
+	spends, _ := ledger.Since(time.Time{})
+	compareInt(t, "PolicyFailedCalls", 1, int64(len(spends)))
+
+	// A send coinbase refused does not
+	status = http.StatusBadRequest
+	if _, err := p.SendMoney(&TransactionParams{To: "user1@example.com", Amount: MustParseMoney("200", "USD")}); err == nil {
+		t.Fatal("PolicyFailedCalls Expected an error")
+	}
+	spends, _ = ledger.Since(time.Time{})
+	compareInt(t, "PolicyFailedCalls", 1, int64(len(spends)))
+
+	_, err = p.SendMoney(&TransactionParams{To: "user1@example.com", Amount: MustParseMoney("700", "USD")})
+	var violation *PolicyViolation
+	if !errors.As(err, &violation) || violation.Rule != RuleDailyLimit {
+		t.Errorf("PolicyFailedCalls Expected the daily limit to be exceeded but got '%v'", err)
+	}
+}
@@ -0,0 +1,160 @@
+package coinbase
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy configures how requests that fail with a transient error are
+// retried. GET requests are always safe to retry. Requests that move money are
+// only retried when they carry an idempotency key (TransactionParams.Idem) so
+// that coinbase can recognize a repeated request. SendMoney, RequestMoney and
+// the buys and sells generate one (see NewIdempotencyKey). Other requests are
+// never retried
+type RetryPolicy struct {
+	MaxAttempts     int           // Attempts including the first one, 1 disables retries
+	InitialBackoff  time.Duration // Wait before the first retry, doubled for each retry
+	MaxBackoff      time.Duration // Upper bound of the wait between two attempts, including Retry-After
+	Jitter          float64       // Fraction of the wait randomized, between 0 and 1
+	RetryableStatus []int         // HTTP response codes worth retrying
+}
+
+// DefaultRetryPolicy returns the retry policy used by clients unless another
+// one is set with WithRetries. Every call returns a new copy which may be
+// changed freely
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:     3,
+		InitialBackoff:  500 * time.Millisecond,
+		MaxBackoff:      10 * time.Second,
+		Jitter:          0.5,
+		RetryableStatus: []int{429, 500, 502, 503, 504},
+	}
+}
+
+// NoRetries is a retry policy that makes a single attempt for every request
+var NoRetries = RetryPolicy{MaxAttempts: 1}
+
+// idempotentParams is implemented by request parameters which carry an
+// idempotency key, making the request safe to retry when the key is set
+type idempotentParams interface {
+	idempotencyKey() string
+}
+
+// canRetry reports whether a request with the given method and params may be
+// sent more than once
+func canRetry(method string, params interface{}) bool {
+	if method == "GET" {
+		return true
+	}
+	p, ok := params.(idempotentParams)
+	return ok && p.idempotencyKey() != ""
+}
+
+// shouldRetry reports whether the error returned by the given attempt is worth
+// retrying and how long to wait before doing so
+func (p RetryPolicy) shouldRetry(ctx context.Context, err error, attempt int) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts || ctx.Err() != nil {
+		return 0, false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if !p.retryableStatus(apiErr.StatusCode) {
+			return 0, false
+		}
+		if apiErr.retryAfter > 0 {
+			// Waiting longer than MaxBackoff is left to the caller
+			if p.MaxBackoff > 0 && apiErr.retryAfter > p.MaxBackoff {
+				return 0, false
+			}
+			return apiErr.retryAfter, true
+		}
+	}
+	if isPermanent(err) {
+		return 0, false
+	}
+	// Any other error comes from the transport (i.e connection reset, timeout)
+	return p.backoff(attempt), true
+}
+
+// isPermanent reports whether err is a transport error that would happen
+// again on every attempt: a certificate that fails verification, or a URL the
+// client cannot send requests to
+func isPermanent(err error) bool {
+	var unknownAuthority x509.UnknownAuthorityError
+	var invalidCert x509.CertificateInvalidError
+	var hostname x509.HostnameError
+	var verification *tls.CertificateVerificationError
+	var recordHeader tls.RecordHeaderError
+	if errors.As(err, &unknownAuthority) || errors.As(err, &invalidCert) || errors.As(err, &hostname) ||
+		errors.As(err, &verification) || errors.As(err, &recordHeader) {
+		return true
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		u, parseErr := url.Parse(urlErr.URL)
+		return parseErr != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == ""
+	}
+	return false
+}
+
+func (p RetryPolicy) retryableStatus(status int) bool {
+	for _, s := range p.RetryableStatus {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the exponential wait following the given attempt, randomized
+// by Jitter
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	wait := p.InitialBackoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || wait < p.MaxBackoff); i++ {
+		wait *= 2
+	}
+	if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		wait -= time.Duration(p.Jitter * rand.Float64() * float64(wait))
+	}
+	return wait
+}
+
+// parseRetryAfter parses the Retry-After header, given either in seconds or
+// as an HTTP date
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// sleep waits for d or until ctx is done, whichever comes first
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
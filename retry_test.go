@@ -0,0 +1,197 @@
+package coinbase
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts:     3,
+	InitialBackoff:  time.Millisecond,
+	MaxBackoff:      5 * time.Millisecond,
+	RetryableStatus: []int{429, 503},
+}
+
+// Fails with 503 until the given number of attempts was made
+func flakyServer(failures int, attempts *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		*attempts++
+		if *attempts <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"success":true,"amount":"1.00000000","currency":"BTC","transaction":{"id":"abc"}}`))
+	}))
+}
+
+func testRetryClient(url string) Client {
+	return ApiKeyClient("key", "secret", WithBaseURL(url), WithRetries(testRetryPolicy))
+}
+
+func TestRetryGet(t *testing.T) {
+	attempts := 0
+	srv := flakyServer(2, &attempts)
+	defer srv.Close()
+	balance, err := testRetryClient(srv.URL).GetBalance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareInt(t, "RetryGet", 3, int64(attempts))
+	compareString(t, "RetryGet", "1.00000000 BTC", balance.String())
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	attempts := 0
+	srv := flakyServer(5, &attempts)
+	defer srv.Close()
+	_, err := testRetryClient(srv.URL).GetBalance()
+	compareInt(t, "RetryGivesUp", 3, int64(attempts))
+	compareBool(t, "RetryGivesUp", true, hasStatus(err, http.StatusServiceUnavailable))
+}
+
+func TestRetryPostIdem(t *testing.T) {
+	attempts := 0
+	srv := flakyServer(1, &attempts)
+	defer srv.Close()
+	c := ApiKeyClient("key", "secret", WithBaseURL(srv.URL), WithRetries(NoRetries))
+	if _, err := c.SendMoney(&TransactionParams{To: "user@example.com"}); err == nil {
+		t.Error("RetryPostIdem Expected an error without retries")
+	}
+	compareInt(t, "RetryPostIdem", 1, int64(attempts))
+
+	// The failed send is looked up before being resubmitted with the same key
+	attempts = 0
+	data, err := testRetryClient(srv.URL).SendMoney(&TransactionParams{To: "user@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareInt(t, "RetryPostIdem", 3, int64(attempts))
+	compareString(t, "RetryPostIdem", "abc", data.Transaction.Id)
+	compareInt(t, "RetryPostIdem", 36, int64(len(data.Idem)))
+}
+
+// Processes every send but answers the first lost ones with 503, as when the
+// response is lost after a timeout. The sends are listed by GET transactions
+func lossyServer(lost int, sends *int) *httptest.Server {
+	created := []map[string]interface{}{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "GET" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"num_pages": 1, "transactions": created})
+			return
+		}
+		*sends++
+		params := transactionRequestParams{}
+		json.NewDecoder(req.Body).Decode(&params)
+		tx := map[string]interface{}{"id": fmt.Sprintf("tx%d", *sends), "idem": params.Transaction.Idem}
+		created = append(created, map[string]interface{}{"transaction": tx})
+		if *sends <= lost {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "transaction": tx})
+	}))
+}
+
+func TestRetryLooksUpIdem(t *testing.T) {
+	sends := 0
+	srv := lossyServer(1, &sends)
+	defer srv.Close()
+	c := testRetryClient(srv.URL)
+	params := &TransactionParams{To: "user@example.com", Amount: MustParseMoney("1", "BTC")}
+	data, err := c.SendMoney(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareInt(t, "RetryLooksUpIdem", 1, int64(sends))
+	compareString(t, "RetryLooksUpIdem", "tx1", data.Transaction.Id)
+	compareString(t, "RetryLooksUpIdem", data.Idem, data.Transaction.Idem)
+	compareString(t, "RetryLooksUpIdem", "", params.Idem)
+
+	// A send repeated with its key, i.e after a restart, is not submitted again
+	params.Idem = data.Idem
+	data, err = c.SendMoney(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareInt(t, "RetryLooksUpIdem", 1, int64(sends))
+	compareString(t, "RetryLooksUpIdem", "tx1", data.Transaction.Id)
+
+	if _, err := c.FindTransactionByIdem("unknown"); !errors.Is(err, ErrIdemNotFound) {
+		t.Errorf("RetryLooksUpIdem Expected ErrIdemNotFound but got '%v'", err)
+	}
+}
+
+func TestRetryIdemLookupFails(t *testing.T) {
+	lookups, sends := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "GET" {
+			lookups++
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		sends++
+		w.Write([]byte(`{"success":true,"transaction":{"id":"tx1","idem":"key"}}`))
+	}))
+	defer srv.Close()
+	// The lookup is retried, then the send is submitted with its key
+	data, err := testRetryClient(srv.URL).SendMoney(&TransactionParams{To: "user@example.com", Amount: MustParseMoney("1", "BTC"), Idem: "key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareInt(t, "RetryIdemLookupFails", 3, int64(lookups))
+	compareInt(t, "RetryIdemLookupFails", 1, int64(sends))
+	compareString(t, "RetryIdemLookupFails", "tx1", data.Transaction.Id)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	compareInt(t, "RetryBackoff", int64(time.Second), int64(p.backoff(1)))
+	compareInt(t, "RetryBackoff", int64(4*time.Second), int64(p.backoff(3)))
+	compareInt(t, "RetryBackoff", int64(5*time.Second), int64(p.backoff(10)))
+	compareInt(t, "RetryBackoff", int64(7*time.Second), int64(parseRetryAfter("7")))
+}
+
+func TestRetryPermanentErrors(t *testing.T) {
+	attempts := 0
+	untrusted := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		return nil, &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}
+	})}
+	c := ApiKeyClient("key", "secret", WithBaseURL("https://api.example.com/v1/"), WithHTTPClient(untrusted), WithRetries(testRetryPolicy))
+	if _, err := c.GetBalance(); err == nil {
+		t.Error("RetryPermanentErrors Expected a certificate error")
+	}
+	compareInt(t, "RetryPermanentErrors", 1, int64(attempts))
+
+	_, err := ApiKeyClient("key", "secret", WithBaseURL("ftp://api.example.com/v1/"), WithRetries(testRetryPolicy)).GetBalance()
+	compareBool(t, "RetryPermanentErrors", true, isPermanent(err))
+
+	// A policy given to a client cannot be changed afterwards
+	policy := DefaultRetryPolicy()
+	c = ApiKeyClient("key", "secret", WithRetries(policy))
+	policy.RetryableStatus[0] = 200
+	compareInt(t, "RetryPermanentErrors", 429, int64(c.rpc.retry.RetryableStatus[0]))
+	compareInt(t, "RetryPermanentErrors", 429, int64(DefaultRetryPolicy().RetryableStatus[0]))
+}
+
+func TestRetryAfterExceedingMaxBackoff(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+	start := time.Now()
+	_, err := testRetryClient(srv.URL).GetBalance()
+	compareBool(t, "RetryAfterExceedingMaxBackoff", true, IsRateLimited(err))
+	compareInt(t, "RetryAfterExceedingMaxBackoff", 1, int64(attempts))
+	compareBool(t, "RetryAfterExceedingMaxBackoff", true, time.Since(start) < time.Second)
+}
@@ -2,106 +2,181 @@ package coinbase
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"net/http"
-	"os"
-	"strings"
+	"net/url"
 )
 
-// basePath needed for reading mock JSON files in simulateRequest and for
-// referencing ca-coinbase.crt in OAuthService. Must be fixed path because
-// relative path changes depending on where library called
-var basePath string = os.Getenv("GOPATH") + "/src/github.com/fabioberger/coinbase-go"
-
 // Rpc handles the remote procedure call requests
 type rpc struct {
-	auth authenticator
-	mock bool
+	auth        authenticator
+	retry       RetryPolicy
+	userAgent   string
+	version     string // CB-VERSION header of API v2 requests
+	queryParams bool   // Send the params of GET and DELETE requests in the query string, and no body without params
+	err         error  // Set when the client was instantiated with an invalid option
 }
 
 // Request sends a request with params marshaled into a JSON payload in the body
-// The response value is marshaled from JSON into the specified holder struct
-func (r rpc) Request(method string, endpoint string, params interface{}, holder interface{}) error {
+// The response value is marshaled from JSON into the specified holder struct.
+// The request is aborted as soon as ctx is canceled or its deadline expires
+func (r rpc) Request(ctx context.Context, method string, endpoint string, params interface{}, holder interface{}) error {
+	_, err := r.do(ctx, method, endpoint, params, holder)
+	return err
+}
 
-	jsonParams, err := json.Marshal(params)
-	if err != nil {
-		return err
+// do sends a request like Request and returns the description of the request
+// which checkApiErrors reports along with errors found in the response
+func (r rpc) do(ctx context.Context, method string, endpoint string, params interface{}, holder interface{}) (requestInfo, error) {
+	if r.err != nil {
+		return requestInfo{}, r.err
 	}
 
-	request, err := r.createRequest(method, endpoint, jsonParams)
+	var jsonParams []byte
+	if r.queryParams && (method == "GET" || method == "DELETE") {
+		query, err := queryString(params)
+		if err != nil {
+			return requestInfo{}, err
+		}
+		if query != "" {
+			endpoint += "?" + query
+		}
+	} else if params != nil || !r.queryParams {
+		var err error
+		if jsonParams, err = json.Marshal(params); err != nil {
+			return requestInfo{}, err
+		}
+	}
+
+	data, source, err := r.send(ctx, method, endpoint, jsonParams, canRetry(method, params))
 	if err != nil {
-		return err
+		return requestInfo{}, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 { // i.e 204 No Content
+		return source, nil
 	}
+	if err := json.Unmarshal(data, &holder); err != nil {
+		return requestInfo{}, err
+	}
+
+	return source, nil
+}
 
-	var data []byte
-	if r.mock == true { // Mock mode: Replace actual request with expected JSON from file
-		data, err = r.simulateRequest(endpoint, method)
-	} else {
-		data, err = r.executeRequest(request)
+// queryString encodes the fields of params, as they would be marshaled to
+// JSON, into a query string
+func queryString(params interface{}) (string, error) {
+	if params == nil {
+		return "", nil
 	}
+	data, err := json.Marshal(params)
 	if err != nil {
-		return err
+		return "", err
 	}
-	if err := json.Unmarshal(data, &holder); err != nil {
-		return err
+	fields := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		return "", err
+	}
+	query := url.Values{}
+	for k, v := range fields {
+		if v != nil {
+			query.Set(k, fmt.Sprint(v))
+		}
 	}
+	return query.Encode(), nil
+}
 
-	return nil
+// send creates and executes a request, retrying it according to the retry
+// policy when retry is true. A new request is created for every attempt so that
+// it is signed with a fresh nonce
+func (r rpc) send(ctx context.Context, method string, endpoint string, params []byte, retry bool) ([]byte, requestInfo, error) {
+	refreshed := false
+	for attempt := 1; ; attempt++ {
+		request, err := r.createRequest(ctx, method, endpoint, params)
+		if err != nil {
+			return nil, requestInfo{}, err
+		}
+		data, source, err := r.executeRequest(request)
+		if a, ok := r.auth.(tokenRefresher); ok && !refreshed && IsUnauthorized(err) {
+			// The request was rejected before being processed, so it is safe to
+			// resend it once with fresh tokens
+			refreshed = true
+			resend, err := a.refreshAfterUnauthorized(ctx, request)
+			if err != nil {
+				return nil, requestInfo{}, err
+			}
+			if resend {
+				attempt--
+				continue
+			}
+		}
+		if err == nil || !retry {
+			return data, source, err
+		}
+		wait, ok := r.retry.shouldRetry(ctx, err, attempt)
+		if !ok {
+			return nil, requestInfo{}, err
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return nil, requestInfo{}, err
+		}
+	}
 }
 
 // CreateRequest formats a request with all the necessary headers
-func (r rpc) createRequest(method string, endpoint string, params []byte) (*http.Request, error) {
+func (r rpc) createRequest(ctx context.Context, method string, endpoint string, params []byte) (*http.Request, error) {
 
 	endpoint = r.auth.getBaseUrl() + endpoint //BaseUrl depends on Auth type used
 
-	req, err := http.NewRequest(method, endpoint, bytes.NewBuffer(params))
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewBuffer(params))
 	if err != nil {
 		return nil, err
 	}
 
 	// Authenticate the request
-	r.auth.authenticate(req, endpoint, params)
+	if err := r.auth.authenticate(req, endpoint, params); err != nil {
+		return nil, err
+	}
 
-	req.Header.Set("User-Agent", "CoinbaseGo/v1")
+	userAgent := r.userAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
 	req.Header.Set("Content-Type", "application/json")
+	if r.version != "" {
+		req.Header.Set("CB-VERSION", r.version)
+	}
 
 	return req, nil
 }
 
-// executeRequest takes a prepared http.Request and returns the body of the response
-// If the response is not of HTTP Code 200, an error is returned
-func (r rpc) executeRequest(req *http.Request) ([]byte, error) {
+// executeRequest takes a prepared http.Request and returns the body of the
+// response along with a description of the request
+// If the response is not of a 2xx HTTP Code, an *APIError is returned
+func (r rpc) executeRequest(req *http.Request) ([]byte, requestInfo, error) {
 	resp, err := r.auth.getClient().Do(req)
 	if err != nil {
-		return nil, err
+		return nil, requestInfo{}, err
 	}
 	defer resp.Body.Close()
 	buf := new(bytes.Buffer)
-	buf.ReadFrom(resp.Body)
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		return nil, requestInfo{}, err
+	}
 	bytes := buf.Bytes()
-	if resp.StatusCode != 200 {
-		if len(bytes) == 0 { // Log response body for debugging purposes
-			log.Printf("Response body was empty")
-		} else {
-			log.Printf("Response body:\n\t%s\n", bytes)
-		}
-		return nil, fmt.Errorf("%s %s failed. Response code was %s", req.Method, req.URL, resp.Status)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, requestInfo{}, newAPIError(req, resp, bytes)
 	}
-	return bytes, nil
-}
-
-// simulateRequest simulates a request by returning a sample JSON from file
-func (r rpc) simulateRequest(endpoint string, method string) ([]byte, error) {
-	// Test files conform to replacing '/' in endpoint with '_'
-	fileName := strings.Replace(endpoint, "/", "_", -1)
-	// file names also have method type prepended to ensure uniqueness
-	filePath := basePath + "/test_data/" + method + "_" + fileName + ".json"
-	data, err := ioutil.ReadFile(filePath)
-	if err != nil {
-		return nil, err
+	source := requestInfo{
+		method:     req.Method,
+		endpoint:   req.URL.String(),
+		statusCode: resp.StatusCode,
+		requestID:  requestID(resp),
+		body:       bytes,
 	}
-	return data, nil
+	return bytes, source, nil
 }
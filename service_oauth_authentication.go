@@ -3,10 +3,17 @@ package coinbase
 import (
 	"crypto/tls"
 	"crypto/x509"
-	"io/ioutil"
+	_ "embed"
+	"errors"
 	"net/http"
 )
 
+// coinbaseCA is the bundle of certificate authorities trusted to sign the
+// certificates of coinbase, embedded so that no file has to be shipped
+//
+//go:embed ca-coinbase.crt
+var coinbaseCA []byte
+
 // ServiceOAuthAuthentication Struct implements the Authentication interface
 // and takes care of authenticating OAuth RPC requests on behalf of the service
 // (i.e GetTokens())
@@ -15,30 +22,45 @@ type serviceOAuthAuthentication struct {
 	Client  http.Client
 }
 
-// ServiceOAuth instantiates ServiceOAuthAuthentication with the coinbase certificate file
-func serviceOAuth(certFilePath string) (*serviceOAuthAuthentication, error) {
-	// First we read the cert
-	certs := x509.NewCertPool()
-	pemData, err := ioutil.ReadFile(certFilePath)
-	if err != nil {
-		return nil, err
+// ServiceOAuth instantiates ServiceOAuthAuthentication pinning the coinbase
+// certificate authorities, or the pool set with WithCertPool or WithCertPEM
+func serviceOAuth(cfg clientConfig) (*serviceOAuthAuthentication, error) {
+	if cfg.err != nil {
+		return nil, cfg.err
+	}
+	if !canPin(cfg.httpClient) {
+		return nil, errUnpinnable // The coinbase certificate authorities are always pinned
+	}
+	certs := cfg.certPool
+	if certs == nil {
+		var err error
+		if certs, err = coinbaseCertPool(); err != nil {
+			return nil, err
+		}
 	}
-	certs.AppendCertsFromPEM(pemData)
 	mTLSConfig := &tls.Config{
 		RootCAs: certs, //Add the cert as a TLS config
 	}
 	a := serviceOAuthAuthentication{
-		BaseUrl: "https://coinbase.com/",
-		Client: http.Client{
-			Transport: &http.Transport{
-				Dial:            dialTimeout,
-				TLSClientConfig: mTLSConfig,
-			},
-		},
+		BaseUrl: cfg.baseUrlOr(cfg.env.SiteUrl),
+		Client:  newHTTPClient(cfg, mTLSConfig),
 	}
 	return &a, nil
 }
 
+// coinbaseCertPool returns a pool of the embedded coinbase certificate authorities
+func coinbaseCertPool() (*x509.CertPool, error) {
+	return certPoolFromPEM(coinbaseCA)
+}
+
+func certPoolFromPEM(pemData []byte) (*x509.CertPool, error) {
+	certs := x509.NewCertPool()
+	if !certs.AppendCertsFromPEM(pemData) {
+		return nil, errors.New("No certificates could be parsed from the PEM data")
+	}
+	return certs, nil
+}
+
 // Service OAuth authentication requires no additional headers to be sent. The
 // Coinbase Public Certificate is set as a TLS config in the http.Client
 func (a serviceOAuthAuthentication) authenticate(req *http.Request, endpoint string, params []byte) error {
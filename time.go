@@ -0,0 +1,96 @@
+package coinbase
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Time is a timestamp of the response models. It embeds time.Time so that it
+// can be compared and formatted directly, i.e tx.CreatedAt.Before(cutoff).
+// It decodes the formats coinbase returns, and encodes back in the format it
+// was decoded from
+type Time struct {
+	time.Time
+	layout string // Layout the time was decoded from, "" for RFC 3339
+}
+
+// Layout of the times decoded from a Unix time
+const unixLayout = "unix"
+
+// Layouts of the timestamps returned by coinbase, tried in order
+var timeLayouts = []string{
+	time.RFC3339Nano, // 2013-05-09T23:07:08-07:00, with optional fractional seconds
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02T15:04:05.999999999", // No zone, in UTC
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// NewTime instantiates a Time from t
+func NewTime(t time.Time) Time {
+	return Time{Time: t}
+}
+
+// ParseTime parses a timestamp in any of the formats returned by coinbase
+func ParseTime(value string) (Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			if layout == time.RFC3339Nano {
+				layout = ""
+			}
+			return Time{Time: t, layout: layout}, nil
+		}
+	}
+	return Time{}, fmt.Errorf("Invalid timestamp %q", value)
+}
+
+// MarshalJSON encodes the time in the layout it was decoded from, RFC 3339 by
+// default, or as null for the zero Time
+func (t Time) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	layout := t.layout
+	if layout == unixLayout {
+		return []byte(strconv.FormatInt(t.Unix(), 10)), nil
+	}
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+	return json.Marshal(t.Format(layout))
+}
+
+// UnmarshalJSON decodes a timestamp string, a Unix time in seconds, or null
+// and the empty string as the zero Time
+func (t *Time) UnmarshalJSON(data []byte) error {
+	*t = Time{}
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if len(data) > 0 && data[0] != '"' {
+		seconds, err := strconv.ParseInt(string(data), 10, 64)
+		if err != nil {
+			return fmt.Errorf("Invalid timestamp %s", data)
+		}
+		*t = Time{Time: time.Unix(seconds, 0).UTC(), layout: unixLayout}
+		return nil
+	}
+	value := ""
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	if value == "" {
+		return nil
+	}
+	parsed, err := ParseTime(value)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
@@ -0,0 +1,40 @@
+package coinbase
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTimeRoundTrip(t *testing.T) {
+	for _, value := range []string{
+		`"2013-05-09T23:07:08-07:00"`,
+		`"2013-05-09T23:07:08.123Z"`,
+		`"2013-05-09T23:07:08-0700"`,
+		`"2013-05-09 23:07:08 -0700"`,
+		`"2013-05-09 23:07:08"`,
+		`"2013-05-09"`,
+		`1368166028`,
+		`null`,
+	} {
+		parsed := Time{}
+		if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+			t.Errorf("Time Unexpected error parsing %s: %v", value, err)
+			continue
+		}
+		data, err := json.Marshal(parsed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		compareString(t, "Time", value, string(data))
+	}
+
+	parsed := Time{}
+	json.Unmarshal([]byte(`"2013-05-09T23:07:08-07:00"`), &parsed)
+	compareBool(t, "Time", true, parsed.Equal(time.Date(2013, 5, 10, 6, 7, 8, 0, time.UTC)))
+	json.Unmarshal([]byte(`""`), &parsed)
+	compareBool(t, "Time", true, parsed.IsZero())
+	if err := json.Unmarshal([]byte(`"yesterday"`), &parsed); err == nil {
+		t.Error("Time Expected an error parsing an invalid timestamp")
+	}
+}
@@ -0,0 +1,178 @@
+package coinbase
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrTokensNotFound is returned by TokenStore.Load when no tokens are stored
+// for a user
+var ErrTokensNotFound = errors.New("No OAuth tokens are stored for this user")
+
+// TokenStore persists the OAuth tokens of users, keyed by user ID. Stores
+// must be safe for concurrent use
+type TokenStore interface {
+	Load(userId string) (*Tokens, error) // Returns ErrTokensNotFound if absent
+	Save(userId string, tokens *Tokens) error
+	Delete(userId string) error
+}
+
+// StoredOAuthClient instantiates a RefreshingOAuthClient with the tokens of a
+// user loaded from store. Refreshed tokens are saved back to store
+func StoredOAuthClient(userId string, store TokenStore, service *OAuth, opts ...ClientOption) (Client, error) {
+	tokens, err := store.Load(userId)
+	if err != nil {
+		return Client{}, err
+	}
+	c := RefreshingOAuthClient(tokens, service, nil, opts...)
+	c.rpc.auth.(*clientOAuthAuthentication).persist = func(tokens *Tokens) error {
+		return store.Save(userId, tokens)
+	}
+	return c, nil
+}
+
+// MemoryTokenStore is a TokenStore keeping tokens in memory
+type MemoryTokenStore struct {
+	mu     sync.RWMutex
+	tokens map[string]Tokens
+}
+
+// NewMemoryTokenStore instantiates an empty MemoryTokenStore
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{tokens: map[string]Tokens{}}
+}
+
+// Load returns a copy of the tokens stored for userId
+func (s *MemoryTokenStore) Load(userId string) (*Tokens, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tokens, ok := s.tokens[userId]
+	if !ok {
+		return nil, ErrTokensNotFound
+	}
+	return &tokens, nil
+}
+
+// Save stores a copy of tokens for userId
+func (s *MemoryTokenStore) Save(userId string, tokens *Tokens) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[userId] = *tokens
+	return nil
+}
+
+// Delete removes the tokens stored for userId
+func (s *MemoryTokenStore) Delete(userId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, userId)
+	return nil
+}
+
+// FileTokenStore is a TokenStore keeping the tokens of each user in a file of
+// its own, encrypted with AES-GCM. File names are derived from a hash of the
+// user ID so that they do not reveal it
+type FileTokenStore struct {
+	dir  string
+	aead cipher.AEAD
+	mu   sync.Mutex
+}
+
+// NewFileTokenStore instantiates a FileTokenStore saving files in dir, which is
+// created if needed. key must be 16, 24 or 32 bytes long to select AES-128,
+// AES-192 or AES-256
+func NewFileTokenStore(dir string, key []byte) (*FileTokenStore, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileTokenStore{dir: dir, aead: aead}, nil
+}
+
+func (s *FileTokenStore) path(userId string) string {
+	sum := sha256.Sum256([]byte(userId))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".tokens")
+}
+
+// Load decrypts the tokens stored for userId
+func (s *FileTokenStore) Load(userId string) (*Tokens, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := os.ReadFile(s.path(userId))
+	if os.IsNotExist(err) {
+		return nil, ErrTokensNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	size := s.aead.NonceSize()
+	if len(data) < size {
+		return nil, errors.New("The token file is corrupted")
+	}
+	// The user ID is authenticated so that files cannot be swapped between users
+	plaintext, err := s.aead.Open(nil, data[:size], data[size:], []byte(userId))
+	if err != nil {
+		return nil, err
+	}
+	tokens := Tokens{}
+	if err := json.Unmarshal(plaintext, &tokens); err != nil {
+		return nil, err
+	}
+	return &tokens, nil
+}
+
+// Save encrypts tokens and writes them atomically to the file of userId
+func (s *FileTokenStore) Save(userId string, tokens *Tokens) error {
+	plaintext, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	data := s.aead.Seal(nonce, nonce, plaintext, []byte(userId))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tmp, err := os.CreateTemp(s.dir, ".tokens-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(userId))
+}
+
+// Delete removes the file of userId
+func (s *FileTokenStore) Delete(userId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := os.Remove(s.path(userId))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,76 @@
+package coinbase
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testTokenStore(t *testing.T, store TokenStore) {
+	if _, err := store.Load("user1"); err != ErrTokensNotFound {
+		t.Errorf("TokenStore Expected '%v' but got '%v'", ErrTokensNotFound, err)
+	}
+	tokens := &Tokens{AccessToken: "access", RefreshToken: "refresh", ExpireTime: 1234}
+	if err := store.Save("user1", tokens); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := store.Load("user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareBool(t, "TokenStore", true, *loaded == *tokens)
+	if _, err := store.Load("user2"); err != ErrTokensNotFound {
+		t.Errorf("TokenStore Expected '%v' but got '%v'", ErrTokensNotFound, err)
+	}
+	if err := store.Delete("user1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load("user1"); err != ErrTokensNotFound {
+		t.Errorf("TokenStore Expected '%v' but got '%v'", ErrTokensNotFound, err)
+	}
+}
+
+func TestMemoryTokenStore(t *testing.T) {
+	testTokenStore(t, NewMemoryTokenStore())
+}
+
+func TestFileTokenStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileTokenStore(dir, bytes.Repeat([]byte("k"), 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTokenStore(t, store)
+
+	// Tokens must not be readable from disk and cannot be decrypted with another key
+	store.Save("user1", &Tokens{AccessToken: "secret-access-token"})
+	files, _ := filepath.Glob(filepath.Join(dir, "*.tokens"))
+	compareInt(t, "FileTokenStore", 1, int64(len(files)))
+	data, _ := os.ReadFile(files[0])
+	compareBool(t, "FileTokenStore", false, bytes.Contains(data, []byte("secret-access-token")))
+	other, _ := NewFileTokenStore(dir, bytes.Repeat([]byte("x"), 32))
+	if _, err := other.Load("user1"); err == nil {
+		t.Error("FileTokenStore Expected an error decrypting with the wrong key")
+	}
+}
+
+func TestStoredOAuthClient(t *testing.T) {
+	refreshes := 0
+	srv := oauthServer(&refreshes)
+	defer srv.Close()
+
+	store := NewMemoryTokenStore()
+	store.Save("user1", &Tokens{AccessToken: "access0", RefreshToken: "refresh", ExpireTime: time.Now().Unix() - 10})
+	service := &OAuth{Rpc: rpc{auth: &serviceOAuthAuthentication{BaseUrl: srv.URL + "/"}}}
+	c, err := StoredOAuthClient("user1", store, service, WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetBalance(); err != nil {
+		t.Fatal(err)
+	}
+	saved, _ := store.Load("user1")
+	compareString(t, "StoredOAuthClient", "access1", saved.AccessToken)
+}
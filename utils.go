@@ -1,12 +1,84 @@
 package coinbase
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"net"
+	"net/http"
 	"time"
 )
 
-// dialTimeout is used to enforce a timeout for all http requests.
-func dialTimeout(network, addr string) (net.Conn, error) {
-	var timeout = time.Duration(2 * time.Second) //how long to wait when trying to connect to the coinbase
-	return net.DialTimeout(network, addr, timeout)
+const (
+	defaultDialTimeout = 2 * time.Second // how long to wait when trying to connect to the coinbase
+	defaultUserAgent   = "CoinbaseGo/v1"
+	defaultAPIVersion  = "2016-02-18" // CB-VERSION sent by V2Client
+)
+
+// newHTTPClient builds the http.Client used by an authenticator. A client set
+// with WithHTTPClient is used as is, apart from WithRequestTimeout,
+// WithCassette and the pinned certificate authorities. Unless tlsConfig is
+// given, the pool set with WithCertPool is pinned
+func newHTTPClient(cfg clientConfig, tlsConfig *tls.Config) http.Client {
+	if tlsConfig == nil && cfg.certPool != nil {
+		tlsConfig = &tls.Config{RootCAs: cfg.certPool}
+	}
+	var client http.Client
+	if cfg.httpClient != nil {
+		client = *cfg.httpClient
+		if cfg.requestTimeout > 0 {
+			client.Timeout = cfg.requestTimeout
+		}
+		if tlsConfig != nil {
+			client.Transport = pinTransport(client.Transport, tlsConfig.RootCAs)
+		}
+	} else {
+		client = http.Client{
+			Transport: &http.Transport{
+				DialContext:     (&net.Dialer{Timeout: cfg.dialTimeout}).DialContext,
+				Proxy:           cfg.proxy,
+				TLSClientConfig: tlsConfig,
+			},
+			Timeout: cfg.requestTimeout,
+		}
+	}
+	if cfg.cassette != nil {
+		client.Transport = cfg.cassette.Transport(client.Transport)
+	}
+	return client
+}
+
+// canPin reports whether certificate authorities can be pinned on the
+// transport of httpClient, which must be an *http.Transport
+func canPin(httpClient *http.Client) bool {
+	if httpClient == nil {
+		return true
+	}
+	switch httpClient.Transport.(type) {
+	case nil, *http.Transport:
+		return true
+	}
+	return false
+}
+
+// errUnpinnable is returned when certificate authorities are pinned on a client
+// set with WithHTTPClient whose transport is not an *http.Transport
+var errUnpinnable = errors.New("Certificate authorities cannot be pinned on the http.Client set with WithHTTPClient: its Transport is not an *http.Transport")
+
+// pinTransport returns a copy of transport, http.DefaultTransport if nil,
+// trusting only the certificate authorities of pool. canPin must hold
+func pinTransport(transport http.RoundTripper, pool *x509.CertPool) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	t, ok := transport.(*http.Transport)
+	if !ok {
+		return transport
+	}
+	t = t.Clone()
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	t.TLSClientConfig.RootCAs = pool // Clone copied TLSClientConfig
+	return t
 }
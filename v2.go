@@ -0,0 +1,468 @@
+package coinbase
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+)
+
+// V2Client is the struct from which API v2 requests are made. It sits
+// alongside Client so that call sites can be migrated one at a time: both
+// accept the same ClientOptions and share the transport, retry and
+// environment settings. The API v2 version sent in the CB-VERSION header is
+// set with WithAPIVersion
+type V2Client struct {
+	rpc rpc
+	ctx context.Context
+	env Environment
+}
+
+// V2ApiKeyClient instantiates the API v2 client with ApiKey Authentication
+func V2ApiKeyClient(key string, secret string, opts ...ClientOption) V2Client {
+	cfg := newClientConfig(opts)
+	return newV2Client(apiKeyV2Auth(key, secret, cfg), cfg)
+}
+
+// V2OAuthClient instantiates the API v2 client with OAuth Authentication
+func V2OAuthClient(tokens *Tokens, opts ...ClientOption) V2Client {
+	cfg := newClientConfig(opts)
+	auth := clientOAuth(tokens, cfg)
+	auth.BaseUrl = cfg.baseUrlOr(cfg.env.v2BaseUrl())
+	return newV2Client(auth, cfg)
+}
+
+// V2RefreshingOAuthClient is like RefreshingOAuthClient for the API v2
+func V2RefreshingOAuthClient(tokens *Tokens, service *OAuth, onRefresh func(*Tokens), opts ...ClientOption) V2Client {
+	c := V2OAuthClient(tokens, opts...)
+	auth := c.rpc.auth.(*clientOAuthAuthentication)
+	auth.service = service
+	auth.onRefresh = onRefresh
+	return c
+}
+
+func newV2Client(auth authenticator, cfg clientConfig) V2Client {
+	return V2Client{
+		rpc: rpc{
+			auth:        auth,
+			retry:       cfg.retry,
+			userAgent:   cfg.userAgent,
+			version:     cfg.apiVersion,
+			queryParams: true,
+			err:         cfg.err,
+		},
+		env: cfg.env,
+	}
+}
+
+// WithContext returns a copy of the client whose requests are bound to ctx
+func (c V2Client) WithContext(ctx context.Context) V2Client {
+	if ctx == nil {
+		panic("nil context")
+	}
+	c.ctx = ctx
+	return c
+}
+
+// Context returns the context requests made by the client are bound to. It
+// defaults to context.Background()
+func (c V2Client) Context() context.Context {
+	if c.ctx != nil {
+		return c.ctx
+	}
+	return context.Background()
+}
+
+// Environment returns the environment the client talks to
+func (c V2Client) Environment() Environment {
+	return c.env
+}
+
+// v2Response is the envelope of the API v2 responses
+type v2Response struct {
+	Data       json.RawMessage `json:"data"`
+	Pagination *V2Pagination   `json:"pagination"`
+}
+
+// Get sends a GET request with params encoded in the query string and
+// marshals the data of the response into holder. The pagination of list
+// responses is returned
+func (c V2Client) Get(path string, params interface{}, holder interface{}) (*V2Pagination, error) {
+	return c.request("GET", path, params, holder)
+}
+
+// Post sends a POST request and marshals the data of the response into holder
+func (c V2Client) Post(path string, params interface{}, holder interface{}) error {
+	_, err := c.request("POST", path, params, holder)
+	return err
+}
+
+// Put sends a PUT request and marshals the data of the response into holder
+func (c V2Client) Put(path string, params interface{}, holder interface{}) error {
+	_, err := c.request("PUT", path, params, holder)
+	return err
+}
+
+// Delete sends a DELETE request
+func (c V2Client) Delete(path string, params interface{}) error {
+	_, err := c.request("DELETE", path, params, nil)
+	return err
+}
+
+func (c V2Client) request(method string, path string, params interface{}, holder interface{}) (*V2Pagination, error) {
+	resp := v2Response{}
+	if err := c.rpc.Request(c.Context(), method, path, params, &resp); err != nil {
+		return nil, err
+	}
+	if holder != nil && len(resp.Data) > 0 {
+		if err := json.Unmarshal(resp.Data, holder); err != nil {
+			return nil, err
+		}
+	}
+	return resp.Pagination, nil
+}
+
+// listParams avoids sending a nil *V2ListParams as a typed params value
+func listParams(params *V2ListParams) interface{} {
+	if params == nil {
+		return nil
+	}
+	return params
+}
+
+// GetCurrentUser returns the user the client authenticates as
+func (c V2Client) GetCurrentUser() (*V2User, error) {
+	user := V2User{}
+	if _, err := c.Get("user", nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetUser returns the public information of another user
+func (c V2Client) GetUser(id string) (*V2User, error) {
+	user := V2User{}
+	if _, err := c.Get("users/"+url.PathEscape(id), nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdateCurrentUser updates the settings of the user the client authenticates
+// as and returns the updated user
+func (c V2Client) UpdateCurrentUser(params *V2UserParams) (*V2User, error) {
+	user := V2User{}
+	if err := c.Put("user", params, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetAccounts returns a page of the accounts of the user
+func (c V2Client) GetAccounts(params *V2ListParams) ([]V2Account, *V2Pagination, error) {
+	accounts := []V2Account{}
+	page, err := c.Get("accounts", listParams(params), &accounts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return accounts, page, nil
+}
+
+// GetAccount returns an account of the user
+func (c V2Client) GetAccount(id string) (*V2Account, error) {
+	account := V2Account{}
+	if _, err := c.Get(accountPath(id), nil, &account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// CreateAccount creates a new BTC account
+func (c V2Client) CreateAccount(params *V2AccountParams) (*V2Account, error) {
+	account := V2Account{}
+	if err := c.Post("accounts", params, &account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// UpdateAccount renames an account
+func (c V2Client) UpdateAccount(id string, params *V2AccountParams) (*V2Account, error) {
+	account := V2Account{}
+	if err := c.Put(accountPath(id), params, &account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// SetPrimaryAccount makes an account the primary account of the user
+func (c V2Client) SetPrimaryAccount(id string) (*V2Account, error) {
+	account := V2Account{}
+	if err := c.Post(accountPath(id)+"/primary", nil, &account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// DeleteAccount deletes an account. Primary accounts and accounts with a
+// balance cannot be deleted
+func (c V2Client) DeleteAccount(id string) error {
+	return c.Delete(accountPath(id), nil)
+}
+
+// GetAddresses returns a page of the bitcoin addresses of an account
+func (c V2Client) GetAddresses(accountId string, params *V2ListParams) ([]V2Address, *V2Pagination, error) {
+	addresses := []V2Address{}
+	page, err := c.Get(accountPath(accountId)+"/addresses", listParams(params), &addresses)
+	if err != nil {
+		return nil, nil, err
+	}
+	return addresses, page, nil
+}
+
+// GetAddress returns a bitcoin address of an account, by ID or address
+func (c V2Client) GetAddress(accountId string, id string) (*V2Address, error) {
+	address := V2Address{}
+	if _, err := c.Get(accountPath(accountId)+"/addresses/"+url.PathEscape(id), nil, &address); err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+// CreateAddress generates a new bitcoin address for an account
+func (c V2Client) CreateAddress(accountId string, params *V2AddressParams) (*V2Address, error) {
+	address := V2Address{}
+	if err := c.Post(accountPath(accountId)+"/addresses", params, &address); err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+// GetAddressTransactions returns a page of the transactions received by a
+// bitcoin address of an account
+func (c V2Client) GetAddressTransactions(accountId string, id string, params *V2ListParams) ([]V2Transaction, *V2Pagination, error) {
+	txs := []V2Transaction{}
+	page, err := c.Get(accountPath(accountId)+"/addresses/"+url.PathEscape(id)+"/transactions", listParams(params), &txs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return txs, page, nil
+}
+
+// GetTransactions returns a page of the transactions of an account
+func (c V2Client) GetTransactions(accountId string, params *V2ListParams) ([]V2Transaction, *V2Pagination, error) {
+	txs := []V2Transaction{}
+	page, err := c.Get(accountPath(accountId)+"/transactions", listParams(params), &txs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return txs, page, nil
+}
+
+// GetTransaction returns a transaction of an account
+func (c V2Client) GetTransaction(accountId string, id string) (*V2Transaction, error) {
+	tx := V2Transaction{}
+	if _, err := c.Get(transactionPath(accountId, id), nil, &tx); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// SendMoney sends bitcoin from an account to a bitcoin address or an email
+func (c V2Client) SendMoney(accountId string, params *V2TransactionParams) (*V2Transaction, error) {
+	return c.createTransaction(accountId, "send", params)
+}
+
+// TransferMoney transfers bitcoin between two accounts of the user. params.To
+// is the ID of the receiving account
+func (c V2Client) TransferMoney(accountId string, params *V2TransactionParams) (*V2Transaction, error) {
+	return c.createTransaction(accountId, "transfer", params)
+}
+
+// RequestMoney requests bitcoin from an email address into an account
+func (c V2Client) RequestMoney(accountId string, params *V2TransactionParams) (*V2Transaction, error) {
+	return c.createTransaction(accountId, "request", params)
+}
+
+func (c V2Client) createTransaction(accountId string, txType string, params *V2TransactionParams) (*V2Transaction, error) {
+	final := V2TransactionParams{}
+	if params != nil {
+		final = *params
+	}
+	final.Type = txType
+	if txType == "send" && final.Amount.Currency == "BTC" {
+		// Only bitcoin recipients can be checked, other currencies have their own address formats
+		if err := validateRecipient(final.To, c.Environment()); err != nil {
+			return nil, err
+		}
+	}
+	tx := V2Transaction{}
+	if err := c.Post(accountPath(accountId)+"/transactions", &final, &tx); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// CompleteRequest completes a money request sent to the user
+func (c V2Client) CompleteRequest(accountId string, id string) (*V2Transaction, error) {
+	tx := V2Transaction{}
+	if err := c.Post(transactionPath(accountId, id)+"/complete", nil, &tx); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// ResendRequest resends the email of a money request
+func (c V2Client) ResendRequest(accountId string, id string) (*V2Transaction, error) {
+	tx := V2Transaction{}
+	if err := c.Post(transactionPath(accountId, id)+"/resend", nil, &tx); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// CancelRequest cancels a money request
+func (c V2Client) CancelRequest(accountId string, id string) error {
+	return c.Delete(transactionPath(accountId, id), nil)
+}
+
+// GetBuys returns a page of the buys of an account
+func (c V2Client) GetBuys(accountId string, params *V2ListParams) ([]V2Transfer, *V2Pagination, error) {
+	return c.getTransfers(accountId, "buys", params)
+}
+
+// GetBuy returns a buy of an account
+func (c V2Client) GetBuy(accountId string, id string) (*V2Transfer, error) {
+	return c.getTransfer(accountId, "buys", id)
+}
+
+// PlaceBuy buys bitcoin into an account with a payment method
+func (c V2Client) PlaceBuy(accountId string, params *V2TransferParams) (*V2Transfer, error) {
+	return c.createTransfer(accountId, "buys", params)
+}
+
+// CommitBuy completes a buy placed without Commit
+func (c V2Client) CommitBuy(accountId string, id string) (*V2Transfer, error) {
+	return c.commitTransfer(accountId, "buys", id)
+}
+
+// GetSells returns a page of the sells of an account
+func (c V2Client) GetSells(accountId string, params *V2ListParams) ([]V2Transfer, *V2Pagination, error) {
+	return c.getTransfers(accountId, "sells", params)
+}
+
+// GetSell returns a sell of an account
+func (c V2Client) GetSell(accountId string, id string) (*V2Transfer, error) {
+	return c.getTransfer(accountId, "sells", id)
+}
+
+// PlaceSell sells bitcoin from an account into a payment method
+func (c V2Client) PlaceSell(accountId string, params *V2TransferParams) (*V2Transfer, error) {
+	return c.createTransfer(accountId, "sells", params)
+}
+
+// CommitSell completes a sell placed without Commit
+func (c V2Client) CommitSell(accountId string, id string) (*V2Transfer, error) {
+	return c.commitTransfer(accountId, "sells", id)
+}
+
+// GetDeposits returns a page of the deposits into a fiat account
+func (c V2Client) GetDeposits(accountId string, params *V2ListParams) ([]V2Transfer, *V2Pagination, error) {
+	return c.getTransfers(accountId, "deposits", params)
+}
+
+// GetDeposit returns a deposit into a fiat account
+func (c V2Client) GetDeposit(accountId string, id string) (*V2Transfer, error) {
+	return c.getTransfer(accountId, "deposits", id)
+}
+
+// Deposit deposits funds from a payment method into a fiat account
+func (c V2Client) Deposit(accountId string, params *V2TransferParams) (*V2Transfer, error) {
+	return c.createTransfer(accountId, "deposits", params)
+}
+
+// CommitDeposit completes a deposit created without Commit
+func (c V2Client) CommitDeposit(accountId string, id string) (*V2Transfer, error) {
+	return c.commitTransfer(accountId, "deposits", id)
+}
+
+// GetWithdrawals returns a page of the withdrawals from a fiat account
+func (c V2Client) GetWithdrawals(accountId string, params *V2ListParams) ([]V2Transfer, *V2Pagination, error) {
+	return c.getTransfers(accountId, "withdrawals", params)
+}
+
+// GetWithdrawal returns a withdrawal from a fiat account
+func (c V2Client) GetWithdrawal(accountId string, id string) (*V2Transfer, error) {
+	return c.getTransfer(accountId, "withdrawals", id)
+}
+
+// Withdraw withdraws funds from a fiat account into a payment method
+func (c V2Client) Withdraw(accountId string, params *V2TransferParams) (*V2Transfer, error) {
+	return c.createTransfer(accountId, "withdrawals", params)
+}
+
+// CommitWithdrawal completes a withdrawal created without Commit
+func (c V2Client) CommitWithdrawal(accountId string, id string) (*V2Transfer, error) {
+	return c.commitTransfer(accountId, "withdrawals", id)
+}
+
+// getTransfers, getTransfer, createTransfer and commitTransfer implement the
+// buys, sells, deposits and withdrawals endpoints, which only differ by path
+func (c V2Client) getTransfers(accountId string, kind string, params *V2ListParams) ([]V2Transfer, *V2Pagination, error) {
+	transfers := []V2Transfer{}
+	page, err := c.Get(accountPath(accountId)+"/"+kind, listParams(params), &transfers)
+	if err != nil {
+		return nil, nil, err
+	}
+	return transfers, page, nil
+}
+
+func (c V2Client) getTransfer(accountId string, kind string, id string) (*V2Transfer, error) {
+	transfer := V2Transfer{}
+	if _, err := c.Get(accountPath(accountId)+"/"+kind+"/"+url.PathEscape(id), nil, &transfer); err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+func (c V2Client) createTransfer(accountId string, kind string, params *V2TransferParams) (*V2Transfer, error) {
+	transfer := V2Transfer{}
+	if err := c.Post(accountPath(accountId)+"/"+kind, params, &transfer); err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+func (c V2Client) commitTransfer(accountId string, kind string, id string) (*V2Transfer, error) {
+	transfer := V2Transfer{}
+	if err := c.Post(accountPath(accountId)+"/"+kind+"/"+url.PathEscape(id)+"/commit", nil, &transfer); err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// GetPaymentMethods returns a page of the payment methods of the user
+func (c V2Client) GetPaymentMethods(params *V2ListParams) ([]V2PaymentMethod, *V2Pagination, error) {
+	methods := []V2PaymentMethod{}
+	page, err := c.Get("payment-methods", listParams(params), &methods)
+	if err != nil {
+		return nil, nil, err
+	}
+	return methods, page, nil
+}
+
+// GetPaymentMethod returns a payment method of the user
+func (c V2Client) GetPaymentMethod(id string) (*V2PaymentMethod, error) {
+	method := V2PaymentMethod{}
+	if _, err := c.Get("payment-methods/"+url.PathEscape(id), nil, &method); err != nil {
+		return nil, err
+	}
+	return &method, nil
+}
+
+func accountPath(id string) string {
+	return "accounts/" + url.PathEscape(id)
+}
+
+func transactionPath(accountId string, id string) string {
+	return accountPath(accountId) + "/transactions/" + url.PathEscape(id)
+}
@@ -0,0 +1,286 @@
+package coinbase
+
+import (
+	"encoding/json"
+	"net/url"
+)
+
+// V2 params include the parameters of the API v2 requests and the resources
+// returned by V2Client. Amounts are sent as the "amount" and "currency" fields
+// the API v2 expects
+
+// Parameter Struct for the list requests of the API v2, i.e GET /v2/accounts.
+// Lists are paginated with cursors: StartingAfter and EndingBefore hold the ID
+// of the resource the page starts after or ends before (see V2Pagination.Next)
+type V2ListParams struct {
+	Limit         int    `json:"limit,omitempty"` // 25 by default, 100 at most
+	Order         string `json:"order,omitempty"` // "desc" (default) or "asc"
+	StartingAfter string `json:"starting_after,omitempty"`
+	EndingBefore  string `json:"ending_before,omitempty"`
+}
+
+// V2Pagination is the position of a page returned by a list request of the
+// API v2
+type V2Pagination struct {
+	EndingBefore  string `json:"ending_before"`
+	StartingAfter string `json:"starting_after"`
+	Limit         int    `json:"limit"`
+	Order         string `json:"order"`
+	PreviousUri   string `json:"previous_uri"`
+	NextUri       string `json:"next_uri"`
+}
+
+// Next returns the params requesting the page following the one p describes,
+// or nil if it was the last page, i.e
+//
+//	for params := &V2ListParams{}; params != nil; params = page.Next(params) {
+//		accounts, page, err = c.GetAccounts(params)
+//		...
+//	}
+func (p *V2Pagination) Next(params *V2ListParams) *V2ListParams {
+	if p == nil || p.NextUri == "" {
+		return nil
+	}
+	next, err := url.Parse(p.NextUri)
+	if err != nil || next.Query().Get("starting_after") == "" {
+		return nil
+	}
+	following := V2ListParams{}
+	if params != nil {
+		following = *params
+	}
+	following.StartingAfter = next.Query().Get("starting_after")
+	following.EndingBefore = ""
+	return &following
+}
+
+// Parameter Struct for PUT /v2/user Requests
+type V2UserParams struct {
+	Name           string `json:"name,omitempty"`
+	TimeZone       string `json:"time_zone,omitempty"`
+	NativeCurrency string `json:"native_currency,omitempty"`
+}
+
+// Parameter Struct for POST /v2/accounts and PUT /v2/accounts/:id Requests
+type V2AccountParams struct {
+	Name string `json:"name,omitempty"`
+}
+
+// Parameter Struct for POST /v2/accounts/:id/addresses Requests
+type V2AddressParams struct {
+	Name        string `json:"name,omitempty"`
+	CallbackUrl string `json:"callback_url,omitempty"`
+}
+
+// Parameter Struct for POST /v2/accounts/:id/transactions Requests. The type
+// of the transaction is set by SendMoney, TransferMoney and RequestMoney. To
+// is a bitcoin address or email for SendMoney and RequestMoney, and an account
+// ID for TransferMoney
+type V2TransactionParams struct {
+	Type              string `json:"type"`
+	To                string `json:"to"`
+	Amount            Money  `json:"-"`
+	Description       string `json:"description,omitempty"`
+	SkipNotifications bool   `json:"skip_notifications,omitempty"`
+	Fee               Money  `json:"-"` // Must be denominated in BTC
+	Idem              string `json:"idem,omitempty"`
+}
+
+// MarshalJSON encodes the amounts of V2TransactionParams as the decimal
+// strings expected by the API v2, i.e "amount":"0.1","currency":"BTC"
+func (p V2TransactionParams) MarshalJSON() ([]byte, error) {
+	type params V2TransactionParams // Prevents infinite recursion into MarshalJSON
+	final := struct {
+		params
+		Amount   string `json:"amount,omitempty"`
+		Currency string `json:"currency,omitempty"`
+		Fee      string `json:"fee,omitempty"`
+	}{
+		params:   params(p),
+		Currency: p.Amount.Currency,
+	}
+	if p.Amount.Currency != "" {
+		final.Amount = p.Amount.Amount()
+	}
+	if p.Fee.Currency != "" {
+		final.Fee = p.Fee.Amount()
+	}
+	return json.Marshal(final)
+}
+
+func (p *V2TransactionParams) idempotencyKey() string {
+	return p.Idem
+}
+
+// Parameter Struct for POST /v2/accounts/:id/(buys,sells,deposits,withdrawals)
+// Requests. Buys and sells are quoted in BTC or in the currency of the payment
+// method. Unless Commit is set, the transfer has to be committed with the
+// corresponding Commit method. Quote only computes the fees without creating
+// a transfer
+type V2TransferParams struct {
+	Amount        Money  `json:"-"`
+	PaymentMethod string `json:"payment_method,omitempty"`
+	Commit        bool   `json:"commit"`
+	Quote         bool   `json:"quote,omitempty"`
+}
+
+// MarshalJSON encodes the amount of V2TransferParams as the decimal string
+// expected by the API v2
+func (p V2TransferParams) MarshalJSON() ([]byte, error) {
+	type params V2TransferParams // Prevents infinite recursion into MarshalJSON
+	final := struct {
+		params
+		Amount   string `json:"amount,omitempty"`
+		Currency string `json:"currency,omitempty"`
+	}{
+		params:   params(p),
+		Currency: p.Amount.Currency,
+	}
+	if p.Amount.Currency != "" {
+		final.Amount = p.Amount.Amount()
+	}
+	return json.Marshal(final)
+}
+
+// V2Resource is a reference to another resource of the API v2, i.e the
+// recipient of a transaction. Email and Address are set for recipients
+// outside of coinbase
+type V2Resource struct {
+	Id           string `json:"id,omitempty"`
+	Resource     string `json:"resource,omitempty"`
+	ResourcePath string `json:"resource_path,omitempty"`
+	Email        string `json:"email,omitempty"`
+	Address      string `json:"address,omitempty"`
+}
+
+// The API v2 representation of a user
+type V2User struct {
+	Id              string `json:"id,omitempty"`
+	Name            string `json:"name,omitempty"`
+	Username        string `json:"username,omitempty"`
+	ProfileLocation string `json:"profile_location,omitempty"`
+	ProfileBio      string `json:"profile_bio,omitempty"`
+	ProfileUrl      string `json:"profile_url,omitempty"`
+	AvatarUrl       string `json:"avatar_url,omitempty"`
+	Email           string `json:"email,omitempty"`
+	TimeZone        string `json:"time_zone,omitempty"`
+	NativeCurrency  string `json:"native_currency,omitempty"`
+	BitcoinUnit     string `json:"bitcoin_unit,omitempty"`
+	CreatedAt       Time   `json:"created_at,omitempty"`
+	Resource        string `json:"resource,omitempty"`
+	ResourcePath    string `json:"resource_path,omitempty"`
+}
+
+// V2Currency is the currency of an account. Versions of the API v2 prior to
+// 2016-05-16 return its code only, i.e "BTC", later versions an object
+type V2Currency struct {
+	Code string `json:"code"`
+	Name string `json:"name,omitempty"`
+}
+
+// UnmarshalJSON decodes both currency formats
+func (c *V2Currency) UnmarshalJSON(data []byte) error {
+	code := ""
+	if err := json.Unmarshal(data, &code); err == nil {
+		*c = V2Currency{Code: code}
+		return nil
+	}
+	type currency V2Currency // Prevents infinite recursion into UnmarshalJSON
+	return json.Unmarshal(data, (*currency)(c))
+}
+
+// The API v2 representation of an account, i.e a BTC wallet or a USD wallet
+type V2Account struct {
+	Id            string     `json:"id,omitempty"`
+	Name          string     `json:"name,omitempty"`
+	Primary       bool       `json:"primary,omitempty"`
+	Type          string     `json:"type,omitempty"`
+	Currency      V2Currency `json:"currency,omitempty"`
+	Balance       Money      `json:"balance,omitempty"`
+	NativeBalance Money      `json:"native_balance,omitempty"`
+	CreatedAt     Time       `json:"created_at,omitempty"`
+	UpdatedAt     Time       `json:"updated_at,omitempty"`
+	Resource      string     `json:"resource,omitempty"`
+	ResourcePath  string     `json:"resource_path,omitempty"`
+}
+
+// The API v2 representation of a bitcoin address of an account
+type V2Address struct {
+	Id           string `json:"id,omitempty"`
+	Address      string `json:"address,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Network      string `json:"network,omitempty"`
+	CallbackUrl  string `json:"callback_url,omitempty"`
+	CreatedAt    Time   `json:"created_at,omitempty"`
+	UpdatedAt    Time   `json:"updated_at,omitempty"`
+	Resource     string `json:"resource,omitempty"`
+	ResourcePath string `json:"resource_path,omitempty"`
+}
+
+// The API v2 representation of a transaction, i.e a send, a request or the
+// transaction of a buy
+type V2Transaction struct {
+	Id           string            `json:"id,omitempty"`
+	Type         string            `json:"type,omitempty"`
+	Status       string            `json:"status,omitempty"`
+	Amount       Money             `json:"amount,omitempty"`
+	NativeAmount Money             `json:"native_amount,omitempty"`
+	Description  string            `json:"description,omitempty"`
+	Idem         string            `json:"idem,omitempty"`
+	Network      *V2Network        `json:"network,omitempty"`
+	To           *V2Resource       `json:"to,omitempty"`
+	From         *V2Resource       `json:"from,omitempty"`
+	Buy          *V2Resource       `json:"buy,omitempty"`
+	Sell         *V2Resource       `json:"sell,omitempty"`
+	Details      map[string]string `json:"details,omitempty"`
+	CreatedAt    Time              `json:"created_at,omitempty"`
+	UpdatedAt    Time              `json:"updated_at,omitempty"`
+	Resource     string            `json:"resource,omitempty"`
+	ResourcePath string            `json:"resource_path,omitempty"`
+}
+
+// The bitcoin network status of a V2Transaction
+type V2Network struct {
+	Status string `json:"status,omitempty"`
+	Hash   string `json:"hash,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+// The API v2 representation of a buy, a sell, a deposit or a withdrawal
+type V2Transfer struct {
+	Id            string      `json:"id,omitempty"`
+	Status        string      `json:"status,omitempty"`
+	PaymentMethod *V2Resource `json:"payment_method,omitempty"`
+	Transaction   *V2Resource `json:"transaction,omitempty"`
+	Amount        Money       `json:"amount,omitempty"`
+	Total         Money       `json:"total,omitempty"`
+	Subtotal      Money       `json:"subtotal,omitempty"`
+	Fee           Money       `json:"fee,omitempty"`
+	Committed     bool        `json:"committed,omitempty"`
+	Instant       bool        `json:"instant,omitempty"`
+	PayoutAt      Time        `json:"payout_at,omitempty"`
+	CreatedAt     Time        `json:"created_at,omitempty"`
+	UpdatedAt     Time        `json:"updated_at,omitempty"`
+	Resource      string      `json:"resource,omitempty"`
+	ResourcePath  string      `json:"resource_path,omitempty"`
+}
+
+// The API v2 representation of a payment method, i.e a bank account
+type V2PaymentMethod struct {
+	Id            string `json:"id,omitempty"`
+	Type          string `json:"type,omitempty"`
+	Name          string `json:"name,omitempty"`
+	Currency      string `json:"currency,omitempty"`
+	PrimaryBuy    bool   `json:"primary_buy,omitempty"`
+	PrimarySell   bool   `json:"primary_sell,omitempty"`
+	AllowBuy      bool   `json:"allow_buy,omitempty"`
+	AllowSell     bool   `json:"allow_sell,omitempty"`
+	AllowDeposit  bool   `json:"allow_deposit,omitempty"`
+	AllowWithdraw bool   `json:"allow_withdraw,omitempty"`
+	InstantBuy    bool   `json:"instant_buy,omitempty"`
+	InstantSell   bool   `json:"instant_sell,omitempty"`
+	CreatedAt     Time   `json:"created_at,omitempty"`
+	UpdatedAt     Time   `json:"updated_at,omitempty"`
+	Resource      string `json:"resource,omitempty"`
+	ResourcePath  string `json:"resource_path,omitempty"`
+}
@@ -0,0 +1,288 @@
+package coinbase
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"testing"
+
+	"github.com/fabioberger/coinbase-go/coinbasetest"
+)
+
+// Initialize the API v2 client against the fake coinbase server
+func initV2TestClient() V2Client {
+	return V2ApiKeyClient(testServer.Key, testServer.Secret, WithBaseURL(testServer.V2BaseURL()))
+}
+
+func TestV2MockGetCurrentUser(t *testing.T) {
+	c := initV2TestClient()
+	user, err := c.GetCurrentUser()
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareString(t, "V2GetCurrentUser", "9da7a204-544e-5fd1-9a12-61176c5d4cd8", user.Id)
+	compareString(t, "V2GetCurrentUser", "USD", user.NativeCurrency)
+
+	req := testServer.LastRequest()
+	compareInt(t, "V2GetCurrentUser", 2, int64(req.Version))
+	compareString(t, "V2GetCurrentUser", "user", req.Path)
+	compareString(t, "V2GetCurrentUser", defaultAPIVersion, req.Header.Get("CB-VERSION"))
+	compareString(t, "V2GetCurrentUser", testServer.Key, req.Header.Get("CB-ACCESS-KEY"))
+}
+
+func TestV2MockGetAccounts(t *testing.T) {
+	c := initV2TestClient()
+	accounts, page, err := c.GetAccounts(&V2ListParams{Limit: 2, Order: "asc"})
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareInt(t, "V2GetAccounts", 2, int64(len(accounts)))
+	compareString(t, "V2GetAccounts", "39.59000000 BTC", accounts[0].Balance.String())
+	compareString(t, "V2GetAccounts", "BTC", accounts[0].Currency.Code)
+	compareString(t, "V2GetAccounts", "fiat", accounts[1].Type)
+
+	req := testServer.LastRequest()
+	compareString(t, "V2GetAccounts", "2", req.Query.Get("limit"))
+	compareString(t, "V2GetAccounts", "asc", req.Query.Get("order"))
+	compareInt(t, "V2GetAccounts", 0, int64(len(req.Body)))
+
+	next := page.Next(&V2ListParams{Limit: 2, Order: "asc"})
+	if next == nil {
+		t.Fatal("V2GetAccounts Expected a next page")
+	}
+	compareString(t, "V2GetAccounts", accounts[1].Id, next.StartingAfter)
+	compareInt(t, "V2GetAccounts", 2, int64(next.Limit))
+	if _, _, err := c.GetAccounts(next); err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "V2GetAccounts", accounts[1].Id, testServer.LastRequest().Query.Get("starting_after"))
+}
+
+func TestV2MockGetAccountsOtherCurrencies(t *testing.T) {
+	s := coinbasetest.NewServer()
+	defer s.Close()
+	s.Respond("GET", "/v2/accounts", 200, `{"data":[
+		{"id":"eth","currency":"ETH","balance":{"amount":"1.23456789","currency":"ETH"},"native_balance":{"amount":"2469.13","currency":"USD"}},
+		{"id":"ltc","currency":"LTC","balance":{"amount":"0.12345678","currency":"LTC"},"native_balance":{"amount":"9.87","currency":"USD"}},
+		{"id":"new","currency":"XYZ","balance":{"amount":"150.123456789","currency":"XYZ"},"native_balance":{"amount":"1.50","currency":"USD"}}
+	]}`)
+	c := V2ApiKeyClient(s.Key, s.Secret, WithBaseURL(s.V2BaseURL()))
+	accounts, _, err := c.GetAccounts(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareInt(t, "V2GetAccountsOtherCurrencies", 3, int64(len(accounts)))
+	compareString(t, "V2GetAccountsOtherCurrencies", "1.234567890000000000 ETH", accounts[0].Balance.String())
+	compareString(t, "V2GetAccountsOtherCurrencies", "0.12345678 LTC", accounts[1].Balance.String())
+	// Currencies unknown to the library keep the decimal places returned
+	compareString(t, "V2GetAccountsOtherCurrencies", "150.123456789 XYZ", accounts[2].Balance.String())
+	compareString(t, "V2GetAccountsOtherCurrencies", "1.50 USD", accounts[2].NativeBalance.String())
+}
+
+func TestV2MockAccounts(t *testing.T) {
+	c := initV2TestClient()
+	account, err := c.CreateAccount(&V2AccountParams{Name: "New wallet"})
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareString(t, "V2CreateAccount", "New wallet", account.Name)
+	compareString(t, "V2CreateAccount", `{"name":"New wallet"}`, string(testServer.LastRequest().Body))
+
+	account, err = c.UpdateAccount(account.Id, &V2AccountParams{Name: "New account name"})
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareString(t, "V2UpdateAccount", "New account name", account.Name)
+
+	account, err = c.SetPrimaryAccount(account.Id)
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareBool(t, "V2SetPrimaryAccount", true, account.Primary)
+	compareInt(t, "V2SetPrimaryAccount", 0, int64(len(testServer.LastRequest().Body)))
+
+	if err := c.DeleteAccount(account.Id); err != nil {
+		t.Errorf("V2DeleteAccount Expected no error but got '%v'", err)
+	}
+}
+
+func TestV2MockAddresses(t *testing.T) {
+	c := initV2TestClient()
+	address, err := c.CreateAddress("ID", &V2AddressParams{Name: "One off payment"})
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareString(t, "V2CreateAddress", "mswUGcPHp1YnkLCgF1TtoryqSc5E9Q8xFa", address.Address)
+
+	addresses, _, err := c.GetAddresses("ID", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareInt(t, "V2GetAddresses", 2, int64(len(addresses)))
+
+	txs, page, err := c.GetAddressTransactions("ID", address.Id, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareString(t, "V2GetAddressTransactions", "0.00100000 BTC", txs[0].Amount.String())
+	if page.Next(nil) != nil {
+		t.Error("V2GetAddressTransactions Expected no next page")
+	}
+}
+
+func TestV2MockSendMoney(t *testing.T) {
+	c := initV2TestClient()
+	tx, err := c.SendMoney("ID", &V2TransactionParams{
+		To:          "1AUJ8z5RuHRTqD1eikyfUUetzGmdWLGkpT",
+		Amount:      MustParseMoney("0.1", "BTC"),
+		Description: "Payout",
+		Idem:        "9316dd16-0c05",
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareString(t, "V2SendMoney", "-0.10000000 BTC", tx.Amount.String())
+	compareString(t, "V2SendMoney", "1AUJ8z5RuHRTqD1eikyfUUetzGmdWLGkpT", tx.To.Address)
+	compareString(t, "V2SendMoney", "unconfirmed", tx.Network.Status)
+
+	body := map[string]interface{}{}
+	if err := json.Unmarshal(testServer.LastRequest().Body, &body); err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "V2SendMoney", "send", body["type"].(string))
+	compareString(t, "V2SendMoney", "0.10000000", body["amount"].(string))
+	compareString(t, "V2SendMoney", "BTC", body["currency"].(string))
+	compareString(t, "V2SendMoney", "9316dd16-0c05", body["idem"].(string))
+}
+
+func TestV2MockSendMoneyRecipients(t *testing.T) {
+	c := initV2TestClient()
+	_, err := c.SendMoney("ID", &V2TransactionParams{
+		To:     "0x742d35Cc6634C0532925a3b844Bc454e4438f44e",
+		Amount: MustParseMoney("0.1", "ETH"),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	body := map[string]interface{}{}
+	if err := json.Unmarshal(testServer.LastRequest().Body, &body); err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "V2SendMoneyRecipients", "0x742d35Cc6634C0532925a3b844Bc454e4438f44e", body["to"].(string))
+	compareString(t, "V2SendMoneyRecipients", "ETH", body["currency"].(string))
+
+	before := len(testServer.Requests())
+	_, err = c.SendMoney("ID", &V2TransactionParams{
+		To:     "1AUJ8z5RuHRTqD1eikyfUUetzGmdWLGkpU",
+		Amount: MustParseMoney("0.1", "BTC"),
+	})
+	if !errors.Is(err, ErrInvalidRecipient) {
+		t.Errorf("V2SendMoneyRecipients Expected ErrInvalidRecipient but got '%v'", err)
+	}
+	compareInt(t, "V2SendMoneyRecipients", int64(before), int64(len(testServer.Requests())))
+}
+
+func TestV2MockRequests(t *testing.T) {
+	c := initV2TestClient()
+	txs, _, err := c.GetTransactions("ID", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareString(t, "V2GetTransactions", "request", txs[1].Type)
+	compareString(t, "V2GetTransactions", "user2@example.com", txs[1].To.Email)
+
+	tx, err := c.CompleteRequest("ID", txs[0].Id)
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareString(t, "V2CompleteRequest", "completed", tx.Status)
+	if _, err := c.ResendRequest("ID", txs[1].Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CancelRequest("ID", txs[1].Id); err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "V2CancelRequest", "DELETE", testServer.LastRequest().Method)
+}
+
+func TestV2MockTransfers(t *testing.T) {
+	c := initV2TestClient()
+	buy, err := c.PlaceBuy("ID", &V2TransferParams{Amount: MustParseMoney("10", "BTC"), PaymentMethod: "PM"})
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareString(t, "V2PlaceBuy", "created", buy.Status)
+	compareString(t, "V2PlaceBuy", `{"payment_method":"PM","commit":false,"amount":"10.00000000","currency":"BTC"}`, string(testServer.LastRequest().Body))
+	buy, err = c.CommitBuy("ID", buy.Id)
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareString(t, "V2CommitBuy", "101.01 USD", buy.Total.String())
+
+	sells, _, err := c.GetSells("ID", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareString(t, "V2GetSells", "99.00 USD", sells[0].Total.String())
+
+	deposit, err := c.Deposit("ID", &V2TransferParams{Amount: MustParseMoney("10", "USD"), PaymentMethod: "PM", Commit: true})
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareString(t, "V2Deposit", "10.00 USD", deposit.Amount.String())
+
+	withdrawal, err := c.GetWithdrawal("ID", "ID")
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareString(t, "V2GetWithdrawal", "withdrawal", withdrawal.Resource)
+	compareString(t, "V2GetWithdrawal", "83562370-3e5c-51db-87da-752af5ab9559", withdrawal.PaymentMethod.Id)
+}
+
+func TestV2MockPaymentMethods(t *testing.T) {
+	c := initV2TestClient()
+	methods, _, err := c.GetPaymentMethods(nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	compareInt(t, "V2GetPaymentMethods", 2, int64(len(methods)))
+	compareString(t, "V2GetPaymentMethods", "ach_bank_account", methods[0].Type)
+	compareBool(t, "V2GetPaymentMethods", true, methods[1].InstantBuy)
+}
+
+func TestV2MockErrors(t *testing.T) {
+	c := V2ApiKeyClient(testServer.Key, "wrong-secret", WithBaseURL(testServer.V2BaseURL()), WithRetries(NoRetries))
+	_, err := c.GetCurrentUser()
+	compareBool(t, "V2Errors", true, IsUnauthorized(err))
+	compareBool(t, "V2Errors", true, HasCode(err, "authentication_error"))
+
+	testServer.Respond("GET", "/v2/accounts/missing", 404, `{"errors":[{"id":"not_found","message":"Not found"}]}`)
+	_, err = initV2TestClient().GetAccount("missing")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("V2Errors Expected *APIError but got '%v'", err)
+	}
+	compareString(t, "V2Errors", "Not found", apiErr.Errors[0])
+	compareString(t, "V2Errors", "not_found", apiErr.Codes[0])
+	compareBool(t, "V2Errors", true, IsNotFound(err))
+}
+
+func TestV2CurrencyFormats(t *testing.T) {
+	account := V2Account{}
+	if err := json.Unmarshal([]byte(`{"currency":{"code":"ETH","name":"Ethereum"}}`), &account); err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "V2CurrencyFormats", "Ethereum", account.Currency.Name)
+	if err := json.Unmarshal([]byte(`{"currency":"BTC"}`), &account); err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "V2CurrencyFormats", "BTC", account.Currency.Code)
+}
+
+func TestV2EnvironmentBaseUrl(t *testing.T) {
+	c := V2ApiKeyClient("key", "secret", WithEnvironment(Sandbox))
+	compareString(t, "V2EnvironmentBaseUrl", "https://api.sandbox.coinbase.com/v2/", c.rpc.auth.getBaseUrl())
+	custom := CustomEnvironment("http://localhost:3000/api/v1", "http://localhost:3000")
+	c = V2OAuthClient(&Tokens{}, WithEnvironment(custom))
+	compareString(t, "V2EnvironmentBaseUrl", "http://localhost:3000/api/v2/", c.rpc.auth.getBaseUrl())
+}